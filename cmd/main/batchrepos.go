@@ -0,0 +1,106 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/claucambra/commit-analysis-tool/pkg/logread"
+)
+
+// Repository to clone and analyse in a batch. The batch file holds a JSON array whose entries are
+// either clone urls or objects with a "url" and any ReadOptions fields, e.g.
+//
+//	["https://example.com/a.git", {"url": "https://example.com/b.git", "refs": ["main"],
+//	 "since": "2018-01-01T00:00:00Z", "until": "2020-01-31", "include_paths": ["drivers/gpu/"]}]
+//
+// Options not set in an entry are taken from the command line. Times are dates or RFC 3339 times
+// like those of -since and -until, with dates in "until" standing for the whole day.
+type batchRepo struct {
+	Url         string
+	ReadOptions logread.ReadOptions
+}
+
+func parseBatchRepos(batchJsonBytes []byte, defaultReadOptions logread.ReadOptions) ([]batchRepo, error) {
+	var entries []json.RawMessage
+	if err := json.Unmarshal(batchJsonBytes, &entries); err != nil {
+		return nil, err
+	}
+
+	repos := make([]batchRepo, len(entries))
+	for i, entry := range entries {
+		repos[i].ReadOptions = defaultReadOptions
+
+		if err := json.Unmarshal(entry, &repos[i].Url); err == nil {
+			continue
+		}
+
+		var entryUrl struct {
+			Url string `json:"url"`
+		}
+
+		if err := json.Unmarshal(entry, &entryUrl); err != nil {
+			return nil, err
+		} else if entryUrl.Url == "" {
+			return nil, errors.New("batch entry without url: " + string(entry))
+		}
+
+		repos[i].Url = entryUrl.Url
+
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(entry, &fields); err != nil {
+			return nil, err
+		}
+
+		// Times are parsed like the -since and -until flags, so both read the same commits
+		since, until := timeFlag{Time: repos[i].ReadOptions.Since}, timeFlag{Time: repos[i].ReadOptions.Until, endOfDay: true}
+		for key, flag := range map[string]*timeFlag{"since": &since, "until": &until} {
+			value, ok := fields[key]
+			if !ok {
+				continue
+			}
+
+			delete(fields, key)
+
+			var timeString string
+			if err := json.Unmarshal(value, &timeString); err != nil {
+				return nil, fmt.Errorf("batch entry %s %s: %w", key, value, err)
+			} else if err := flag.Set(timeString); err != nil {
+				return nil, fmt.Errorf("batch entry %s %s: %w", key, value, err)
+			}
+		}
+
+		otherFields, err := json.Marshal(fields)
+		if err != nil {
+			return nil, err
+		}
+
+		// Only overwrites the options present in the entry
+		if err := json.Unmarshal(otherFields, &repos[i].ReadOptions); err != nil {
+			return nil, err
+		}
+
+		repos[i].ReadOptions.Since = since.Time
+		repos[i].ReadOptions.Until = until.Time
+	}
+
+	return repos, nil
+}
+
+// Name of the clone, database and reports of a batch repository in the clone path, e.g.
+// https://code.videolan.org/videolan/vlc.git -> vlc. Unlike the name the repository is ingested
+// under (see common.RepoNameFromUrl), it has no slashes, and it stays the same so later batch runs
+// find the clones and databases of earlier ones.
+func batchRepoFileName(url string) string {
+	trimmedUrl := strings.TrimRight(url, "/")
+	splitUrl := strings.FieldsFunc(trimmedUrl, func(r rune) bool {
+		return r == '/' || r == ':'
+	})
+
+	if len(splitUrl) == 0 {
+		return ""
+	}
+
+	return strings.TrimSuffix(splitUrl[len(splitUrl)-1], ".git")
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/claucambra/commit-analysis-tool/pkg/common"
+	"github.com/claucambra/commit-analysis-tool/pkg/logread"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+func runTestGit(t *testing.T, repoPath string, args ...string) {
+	args = append([]string{"-C", repoPath, "-c", "user.name=Claudio Cambra", "-c", "user.email=developer@claudiocambra.com"}, args...)
+	if out, err := exec.Command("git", args...).CombinedOutput(); err != nil {
+		t.Fatalf("Error running git %v: %s\n%s", args, err, out)
+	}
+}
+
+func TestParseBatchRepos(t *testing.T) {
+	defaultSince := time.Date(2015, 1, 1, 0, 0, 0, 0, time.UTC)
+	defaultOptions := logread.ReadOptions{Since: defaultSince, Authors: []string{"@videolan\\.org>$"}}
+
+	batchJson := `["https://code.videolan.org/videolan/vlc.git",
+		{"url": "https://code.videolan.org/videolan/libvlc.git", "since": "2018-01-01", "until": "2020-01-31", "refs": ["master"]},
+		{"url": "https://code.videolan.org/videolan/dav1d.git", "until": "2020-01-31T12:00:00Z"}]`
+
+	repos, err := parseBatchRepos([]byte(batchJson), defaultOptions)
+	if err != nil {
+		t.Fatalf("Error parsing batch repositories: %s", err)
+	}
+
+	expectedRepos := []batchRepo{
+		{"https://code.videolan.org/videolan/vlc.git", defaultOptions},
+		{"https://code.videolan.org/videolan/libvlc.git", logread.ReadOptions{
+			Refs:    []string{"master"},
+			Since:   time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC),
+			Until:   time.Date(2020, 1, 31, 23, 59, 59, 0, time.UTC),
+			Authors: defaultOptions.Authors,
+		}},
+		{"https://code.videolan.org/videolan/dav1d.git", logread.ReadOptions{
+			Since:   defaultSince,
+			Until:   time.Date(2020, 1, 31, 12, 0, 0, 0, time.UTC),
+			Authors: defaultOptions.Authors,
+		}},
+	}
+
+	if !cmp.Equal(expectedRepos, repos, cmpopts.IgnoreFields(logread.ReadOptions{}, "OnReject")) {
+		t.Fatalf("Unexpected batch repositories: %s", cmp.Diff(expectedRepos, repos, cmpopts.IgnoreFields(logread.ReadOptions{}, "OnReject")))
+	}
+
+	if _, err := parseBatchRepos([]byte(`[{"url": "https://code.videolan.org/videolan/vlc.git", "until": "31/01/2020"}]`), defaultOptions); err == nil {
+		t.Fatalf("Expected an error parsing a batch entry with an invalid time")
+	}
+}
+
+func TestBatchRepoFileName(t *testing.T) {
+	urls := map[string]string{
+		"https://code.videolan.org/videolan/vlc.git":     "vlc",
+		"git@github.com:claucambra/commit-analysis-tool": "commit-analysis-tool",
+		"/srv/git/vlc.git/":                              "vlc",
+	}
+
+	for url, expectedName := range urls {
+		if name := batchRepoFileName(url); name != expectedName {
+			t.Fatalf("Unexpected file name %q of %s, expected %q", name, url, expectedName)
+		}
+	}
+}
+
+// Later batch runs pull the clones of earlier ones and only ingest the commits added since
+func TestBatchCloneReusesClone(t *testing.T) {
+	ctx := context.Background()
+
+	originPath := filepath.Join(t.TempDir(), "vlc.git")
+	if err := os.Mkdir(originPath, 0755); err != nil {
+		t.Fatalf("Could not create origin repository: %s", err)
+	}
+
+	runTestGit(t, originPath, "init", "--quiet")
+	runTestGit(t, originPath, "commit", "--quiet", "--allow-empty", "-m", "Add readme")
+
+	clonePath := t.TempDir()
+	batchRun := func() (int, string) {
+		clonedPaths, repoNames := cloneRepos([]string{originPath}, clonePath)
+		if clonedPaths[0] != filepath.Join(clonePath, "vlc") {
+			t.Fatalf("Unexpected clone path %s", clonedPaths[0])
+		}
+
+		ingestDbPath := filepath.Join(clonePath, repoNames[0]+".db")
+		sqlb := newSql(ctx, ingestDbPath)
+		defer sqlb.Close()
+
+		source := newCommitSource(logread.GitCommitSourceKind, clonedPaths[0])
+		ingestRepoCommits(ctx, ingestDbPath, source, "", sqlb, false, logread.ReadOptions{})
+
+		repos, err := sqlb.Repositories(ctx)
+		if err != nil || len(repos) != 1 {
+			t.Fatalf("Unexpected repositories %+v: %v", repos, err)
+		}
+
+		commits, err := sqlb.Commits(ctx, "")
+		if err != nil {
+			t.Fatalf("Error reading commits: %s", err)
+		}
+
+		return len(commits), repos[0].Name
+	}
+
+	if numCommits, _ := batchRun(); numCommits != 1 {
+		t.Fatalf("Unexpected number of commits %d after the first batch run, expected 1", numCommits)
+	}
+
+	runTestGit(t, originPath, "commit", "--quiet", "--allow-empty", "-m", "Add main function")
+
+	numCommits, repoName := batchRun()
+	if numCommits != 2 {
+		t.Fatalf("Unexpected number of commits %d after the second batch run, expected 2", numCommits)
+	} else if expectedName := common.RepoNameFromUrl(originPath); repoName != expectedName {
+		t.Fatalf("Unexpected repository name %q in the database, expected %q", repoName, expectedName)
+	}
+}
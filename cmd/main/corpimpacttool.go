@@ -2,6 +2,7 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"encoding/csv"
 	"encoding/json"
 	"flag"
@@ -10,44 +11,126 @@ import (
 	"log"
 	"os"
 	"os/exec"
+	"os/signal"
 	"path/filepath"
-	"strings"
+	"time"
 
 	"github.com/claucambra/commit-analysis-tool/internal/db"
+	"github.com/claucambra/commit-analysis-tool/pkg/common"
 	"github.com/claucambra/commit-analysis-tool/pkg/logread"
+	"github.com/claucambra/commit-analysis-tool/pkg/statistics/authorgroups"
 	"github.com/claucambra/commit-analysis-tool/pkg/statistics/authorgroups/corpimpact"
 )
 
 func main() {
+	// Interrupting stops ingests and reports, rolling back the ingest in progress
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		migrateCommand(ctx, os.Args[2:])
+		return
+	} else if len(os.Args) > 1 && os.Args[1] == "search" {
+		searchCommand(ctx, os.Args[2:])
+		return
+	} else if len(os.Args) > 1 && os.Args[1] == "retention" {
+		retentionCommand(ctx, os.Args[2:])
+		return
+	}
+
 	var (
-		batchRead            = flag.String("batch-read", "", "path to file of git clone urls to analyse")
+		batchRead            = flag.String("batch-read", "", "path to JSON file of git clone urls (or objects with a url and read options) to analyse")
 		clonePath            = flag.String("clone-path", "", "path to store cloned repositories in")
 		ingestDbPath         = flag.String("ingest-db-path", "", "path to database file")
 		readDbPath           = flag.String("read-db-path", "", "path to database file")
 		repoPath             = flag.String("repo-path", "", "path to git repository")
+		logPath              = flag.String("log-path", "", "path to a log saved with the command from -print-git-log-command to ingest instead of a repository, or - to read it from stdin")
+		printGitLogCommand   = flag.Bool("print-git-log-command", false, "print the git log command that saves a log which can be ingested with -log-path")
+		commitSource         = flag.String("commit-source", logread.GitCommitSourceKind, fmt.Sprintf("how to read commits, one of %v (%s reads the file at -log-path)", logread.CommitSourceKinds, logread.LogFileCommitSourceKind))
 		domainGroupsFilePath = flag.String("domain-groups-file-path", "", "file containing email domain groups")
+		fullIngest           = flag.Bool("full-ingest", false, "read the full history instead of only commits added since the last ingest")
+		creditCoAuthors      = flag.Bool("credit-co-authors", false, "share changes of co-authored commits between the author and co-authors in reports")
+		activityKind         = flag.String("activity", authorgroups.ContinuousActivityKind, fmt.Sprintf("how long authors count as active in survival reports, one of %v", authorgroups.ActivityKinds))
+		activityGapMonths    = flag.Int("activity-gap-months", 0, "months without commits a continuously active author can skip")
+		activityWindowMonths = flag.Int("activity-window-months", 3, "months of each window authors must commit enough in to stay active with -activity window")
+		activityMinCommits   = flag.Int("activity-min-commits", 1, "commits authors must make in each window to stay active with -activity window")
+		censorMonths         = flag.Int("censor-months", authorgroups.DefaultCensorMonths, "consider authors active this many months or fewer before the last commit, or within the activity gap or window, as still active")
+		includeMerges        = flag.Bool("include-merges", false, "also ingest merge commits and their parents")
+		firstParent          = flag.Bool("first-parent", false, "only follow the first parent of merge commits when ingesting")
+		lenient              = flag.Bool("lenient", false, "skip commits that cannot be parsed instead of stopping the ingest")
+		rejectsPath          = flag.String("rejects-path", "", "file to write commits skipped in lenient mode to, with the reason (implies -lenient)")
+		repoName             = flag.String("repo-name", "", "name to ingest the repository under (defaults to the host and path of its origin url, e.g. github.com/owner/name), or the repository to report on when reading a database")
+		refs                 stringsFlag
+		includePaths         stringsFlag
+		excludePaths         stringsFlag
+		authors              stringsFlag
+		since                timeFlag
+		until                = timeFlag{endOfDay: true}
 	)
 
+	flag.Var(&refs, "ref", "ref or revision range to read, e.g. main or v1.0..v2.0 (repeatable, defaults to all branches)")
+	flag.Var(&includePaths, "include-path", "only read changes to this path or pattern (repeatable)")
+	flag.Var(&excludePaths, "exclude-path", "do not read changes to this path or pattern (repeatable)")
+	flag.Var(&authors, "author", "only read commits whose author (\"Name <email>\") matches this regular expression (repeatable)")
+	flag.Var(&since, "since", "only read commits committed at or after this date (2006-01-02) or time (RFC 3339)")
+	flag.Var(&until, "until", "only read commits committed at or before this date (2006-01-02, all of it) or time (RFC 3339)")
+
 	flag.Parse()
 
-	if *batchRead != "" {
+	readOptions := logread.ReadOptions{
+		IncludeMerges: *includeMerges,
+		FirstParent:   *firstParent,
+		Refs:          refs,
+		Since:         since.Time,
+		Until:         until.Time,
+		IncludePaths:  includePaths,
+		ExcludePaths:  excludePaths,
+		Authors:       authors,
+		Lenient:       *lenient || *rejectsPath != "",
+	}
+
+	activity := newActivityDefinition(*activityKind, authorgroups.ActivityOptions{
+		GapMonths:        *activityGapMonths,
+		WindowMonths:     *activityWindowMonths,
+		MinWindowCommits: *activityMinCommits,
+	})
+
+	if *rejectsPath != "" {
+		rejectsFile := openRejectsFile(*rejectsPath, &readOptions)
+		defer rejectsFile.Close()
+	}
+
+	if *printGitLogCommand {
+
+		log.Println("Run this command in the repository and save its output to a file, e.g. by appending > repo.log")
+		fmt.Println(logread.GitLogCommandLine(readOptions))
+
+	} else if *batchRead != "" {
 
 		if *clonePath == "" {
 			log.Fatalf("Received empty clone path, don't know where to store cloned repos")
+		} else if *commitSource == logread.LogFileCommitSourceKind {
+			log.Fatalf("Cloned repositories cannot be read as saved logs")
 		} else if *domainGroupsFilePath == "" {
 			log.Println("WARNING: No valid domain groupings file has been provided")
 		}
 
-		batchCloneAndRead(*batchRead, *clonePath, *domainGroupsFilePath)
+		batchCloneAndRead(ctx, *batchRead, *clonePath, *domainGroupsFilePath, *commitSource, *fullIngest, readOptions, *creditCoAuthors, activity, *censorMonths)
 
 	} else if *ingestDbPath != "" {
 
-		if *repoPath == "" {
-			log.Fatalf("Cannot ingest git repository commits to a database file without a path for said file.")
+		sourceKind, sourcePath := *commitSource, *repoPath
+		if *logPath != "" || sourceKind == logread.LogFileCommitSourceKind {
+			sourceKind, sourcePath = logread.LogFileCommitSourceKind, *logPath
+		}
+
+		if sourcePath == "" {
+			log.Fatalf("Cannot ingest commits to a database file without a repository or log path.")
 		}
 
-		sqlb := newSql(*ingestDbPath)
-		ingestRepoCommits(*ingestDbPath, *repoPath, sqlb)
+		source := newCommitSource(sourceKind, sourcePath)
+		sqlb := newSql(ctx, *ingestDbPath)
+		ingestRepoCommits(ctx, *ingestDbPath, source, *repoName, sqlb, *fullIngest, readOptions)
 		sqlb.Close()
 
 	} else if *readDbPath != "" && *domainGroupsFilePath != "" {
@@ -56,8 +139,8 @@ func main() {
 			log.Println("WARNING: No valid domain groupings file has been provided")
 		}
 
-		sqlb := newSql(*readDbPath)
-		report := generateCorpReport(*readDbPath, *domainGroupsFilePath, sqlb)
+		sqlb := newSql(ctx, *readDbPath)
+		report := generateCorpReport(ctx, *readDbPath, *domainGroupsFilePath, sqlb, *repoName, *creditCoAuthors, activity, *censorMonths)
 		sqlb.Close()
 
 		fmt.Printf("%+v", report)
@@ -69,9 +152,9 @@ func main() {
 	}
 }
 
-func newSql(dbpath string) *db.SQLiteBackend {
+func newSql(ctx context.Context, dbpath string) *db.SQLiteBackend {
 	sqlb := new(db.SQLiteBackend)
-	err := sqlb.Open(dbpath)
+	err := sqlb.Open(ctx, dbpath)
 	if err != nil {
 		log.Fatalf("Error opening sqlite database, received error: %s", err)
 	}
@@ -79,24 +162,145 @@ func newSql(dbpath string) *db.SQLiteBackend {
 	return sqlb
 }
 
-func ingestRepoCommits(ingestDbPath string, repoPath string, sqlb *db.SQLiteBackend) {
-	err := sqlb.Setup()
+// Writes commits skipped in lenient mode to the rejects file
+func openRejectsFile(rejectsPath string, readOptions *logread.ReadOptions) *os.File {
+	rejectsFile, err := os.Create(rejectsPath)
+	if err != nil {
+		log.Fatalf("Could not create rejects file: %s", err)
+	}
+
+	rejectsWriter := logread.NewRejectsWriter(rejectsFile)
+	readOptions.OnReject = func(parseErr *logread.ParseError) {
+		if err := rejectsWriter.Write(parseErr); err != nil {
+			log.Printf("Could not write rejected commit %s to rejects file: %s", parseErr.CommitId, err)
+		}
+	}
+
+	return rejectsFile
+}
+
+func newActivityDefinition(kind string, options authorgroups.ActivityOptions) authorgroups.ActivityDefinition {
+	activity, err := authorgroups.NewActivityDefinition(kind, options)
+	if err != nil {
+		log.Fatalf("Error setting up activity definition: %s", err)
+	}
+
+	return activity
+}
+
+func newCommitSource(kind string, path string) logread.CommitSource {
+	source, err := logread.NewCommitSource(kind, path)
+	if err != nil {
+		log.Fatalf("Error opening commit source at %s: %s", path, err)
+	}
+
+	return source
+}
+
+func ingestRepoCommits(ctx context.Context, ingestDbPath string, source logread.CommitSource, repoName string, sqlb *db.SQLiteBackend, fullIngest bool, readOptions logread.ReadOptions) {
+	repo, err := source.Repository()
+	if err != nil {
+		log.Fatalf("Error reading repository: %s", err)
+	}
+
+	// Forks usually share their name with the upstream repository, so allow telling them apart
+	if repoName != "" {
+		repo.Name = repoName
+	} else if repo.Name == "" {
+		log.Fatalf("Could not tell the name of the repository, set one with -repo-name")
+	}
+
+	log.Printf("Ingesting commits of repository %s.", repo.Name)
+
+	// Read the tips before git log runs so no commits fall between this ingest and the next
+	currentTips, err := source.RefTips()
 	if err != nil {
-		log.Fatalf("Error setting up sqlite database, received error: %s", err)
-		os.Exit(0)
+		log.Fatalf("Error reading refs of %s: %s", repo.Name, err)
 	}
 
-	commits, err := logread.ReadCommits(repoPath)
+	excludedCommits := []string{}
+	if !fullIngest {
+		excludedCommits = previouslyIngestedCommits(ctx, repo.Name, source, sqlb)
+	}
+
+	commits, err := source.Commits(excludedCommits, readOptions)
 	if err != nil {
-		log.Fatalf("Error reading commits at %s: %s", repoPath, err)
+		log.Fatalf("Error reading commits of %s: %s", repo.Name, err)
+	}
+
+	commits.SetRepoName(repo.Name)
+
+	// Commits, ref tips and the repository record are added together, so an interrupted ingest
+	// leaves the database as it was and can simply be run again
+	ingest, err := sqlb.BeginIngest(ctx)
+	if err != nil {
+		commits.Close()
+		log.Fatalf("Error starting ingest of %s: %s", repo.Name, err)
 	}
 
 	log.Println("Starting commit ingest.")
-	sqlb.AddCommits(commits)
-	log.Println("Finished ingesting commits!")
+	numIngested, err := ingest.IngestCommits(ctx, commits, func(progress db.IngestProgress) {
+		if !progress.Done {
+			log.Printf("Read %d commits, added %d (%s).", progress.NumRead, progress.NumAdded, progress.Elapsed.Round(time.Second))
+		}
+	})
+	if err != nil {
+		commits.Close()
+		ingest.Rollback()
+		log.Fatalf("Error ingesting commits of %s: %s", repo.Name, err)
+	}
+
+	err = ingest.SetIngestedRefTips(ctx, repo.Name, currentTips)
+	if err != nil {
+		ingest.Rollback()
+		log.Fatalf("Error recording ingested refs for %s: %s", repo.Name, err)
+	}
+
+	ingestTime := time.Now().Unix()
+	repo.FirstIngestTime = ingestTime
+	repo.LastIngestTime = ingestTime
+
+	err = ingest.AddRepository(ctx, repo)
+	if err != nil {
+		ingest.Rollback()
+		log.Fatalf("Error recording repository %s: %s", repo.Name, err)
+	}
+
+	err = ingest.Commit()
+	if err != nil {
+		log.Fatalf("Error saving commits of %s: %s", repo.Name, err)
+	}
+
+	log.Printf("Finished ingesting commits! Added %d new commits.", numIngested)
+}
+
+// Tips recorded by the last ingest of the repository that still exist in it
+func previouslyIngestedCommits(ctx context.Context, repoName string, source logread.CommitSource, sqlb *db.SQLiteBackend) []string {
+	ingestedTips, err := sqlb.IngestedRefTips(ctx, repoName)
+	if err != nil {
+		log.Fatalf("Error reading previously ingested refs: %s", err)
+	}
+
+	ingestedCommits := []string{}
+	for _, commitId := range ingestedTips {
+		if found, _ := common.SliceContains(ingestedCommits, commitId); !found {
+			ingestedCommits = append(ingestedCommits, commitId)
+		}
+	}
+
+	existingCommits, err := source.ExistingCommits(ingestedCommits)
+	if err != nil {
+		log.Fatalf("Error checking previously ingested refs: %s", err)
+	}
+
+	if len(existingCommits) > 0 {
+		log.Printf("Only reading commits added since the last ingest (%d known ref tips).", len(existingCommits))
+	}
+
+	return existingCommits
 }
 
-func generateCorpReport(readDbPath string, domainGroupsFilePath string, sqlb *db.SQLiteBackend) *corpimpact.CorporateReport {
+func generateCorpReport(ctx context.Context, readDbPath string, domainGroupsFilePath string, sqlb *db.SQLiteBackend, repoName string, creditCoAuthors bool, activity authorgroups.ActivityDefinition, censorMonths int) *corpimpact.CorporateReport {
 	groupsJsonBytes, err := os.ReadFile(domainGroupsFilePath)
 	if err != nil {
 		log.Fatalf("Error opening domain groups json file: %s", err)
@@ -110,8 +314,13 @@ func generateCorpReport(readDbPath string, domainGroupsFilePath string, sqlb *db
 		sqlb.Close()
 	}
 
-	corpReport := corpimpact.NewCorporateReport(groups, sqlb, "Corporate")
-	corpReport.Generate()
+	corpReport := corpimpact.NewCorporateReport(groups, sqlb, "Corporate", repoName)
+	corpReport.CreditCoAuthors = creditCoAuthors
+	corpReport.SurvivalActivity = activity
+	corpReport.SurvivalCensorMonths = censorMonths
+	if err := corpReport.Generate(ctx); err != nil {
+		log.Fatalf("Error generating corporate report: %s", err)
+	}
 
 	return corpReport
 }
@@ -123,9 +332,7 @@ func cloneRepos(urls []string, clonePath string) ([]string, []string) {
 	for i, url := range urls {
 		log.Printf("About to clone git repository: %s", url)
 
-		splitUrl := strings.Split(url, "/")
-		repoNameDotGit := splitUrl[len(splitUrl)-1]
-		repoName := strings.TrimSuffix(repoNameDotGit, ".git")
+		repoName := batchRepoFileName(url)
 		repoNames[i] = repoName
 
 		fullClonePath := filepath.Join(clonePath, repoName)
@@ -163,18 +370,22 @@ func cloneRepos(urls []string, clonePath string) ([]string, []string) {
 	return fullClonedPaths, repoNames
 }
 
-func batchCloneAndRead(urlsJsonFile string, clonePath string, domainGroupsFilePath string) {
+func batchCloneAndRead(ctx context.Context, urlsJsonFile string, clonePath string, domainGroupsFilePath string, commitSource string, fullIngest bool, readOptions logread.ReadOptions, creditCoAuthors bool, activity authorgroups.ActivityDefinition, censorMonths int) {
 	urlsJsonBytes, err := os.ReadFile(urlsJsonFile)
 	if err != nil {
 		log.Fatalf("Error opening batch fetch urls JSON file: %s", err)
 	}
 
-	var urls []string
-	err = json.Unmarshal(urlsJsonBytes, &urls)
+	batchRepos, err := parseBatchRepos(urlsJsonBytes, readOptions)
 	if err != nil {
 		log.Fatal("Error during Unmarshal(): ", err)
 	}
 
+	urls := make([]string, len(batchRepos))
+	for i, batchRepo := range batchRepos {
+		urls[i] = batchRepo.Url
+	}
+
 	fullCsvPath := filepath.Join(clonePath, "corpreport.csv")
 	fullCsvFile, err := os.Create(fullCsvPath)
 	if err != nil {
@@ -193,14 +404,15 @@ func batchCloneAndRead(urlsJsonFile string, clonePath string, domainGroupsFilePa
 
 		ingestDbPath := filepath.Join(clonePath, repoName+".db")
 
-		sqlb := newSql(ingestDbPath)
+		sqlb := newSql(ctx, ingestDbPath)
 
 		log.Printf("Beginning commit ingest at %s", ingestDbPath)
-		ingestRepoCommits(ingestDbPath, clonedRepoPath, sqlb)
+		source := newCommitSource(commitSource, clonedRepoPath)
+		ingestRepoCommits(ctx, ingestDbPath, source, "", sqlb, fullIngest, batchRepos[i].ReadOptions)
 		log.Printf("Commit ingest for %s now complete.", repoName)
 
 		log.Printf("Beginning corporate impact analysis.")
-		report := generateCorpReport(ingestDbPath, domainGroupsFilePath, sqlb)
+		report := generateCorpReport(ctx, ingestDbPath, domainGroupsFilePath, sqlb, "", creditCoAuthors, activity, censorMonths)
 
 		sqlb.Close()
 
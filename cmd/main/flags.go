@@ -0,0 +1,46 @@
+package main
+
+import (
+	"strings"
+	"time"
+)
+
+// Flag that can be given several times, collecting every value
+type stringsFlag []string
+
+func (sf *stringsFlag) String() string {
+	return strings.Join(*sf, ",")
+}
+
+func (sf *stringsFlag) Set(value string) error {
+	*sf = append(*sf, value)
+	return nil
+}
+
+// Flag holding a date ("2006-01-02", in UTC) or time (RFC 3339). A date stands for its start, or
+// for its last second with endOfDay, so that upper bounds include the whole day.
+type timeFlag struct {
+	time.Time
+
+	endOfDay bool
+}
+
+func (tf *timeFlag) String() string {
+	if tf.IsZero() {
+		return ""
+	}
+
+	return tf.Format(time.RFC3339)
+}
+
+func (tf *timeFlag) Set(value string) error {
+	parsedTime, err := time.Parse("2006-01-02", value)
+	if err == nil && tf.endOfDay {
+		parsedTime = parsedTime.AddDate(0, 0, 1).Add(-time.Second)
+	} else if err != nil {
+		parsedTime, err = time.Parse(time.RFC3339, value)
+	}
+
+	tf.Time = parsedTime
+	return err
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+
+	"github.com/claucambra/commit-analysis-tool/internal/db"
+)
+
+// Runs the migrate command, which brings a database to the latest schema version. Databases are
+// also migrated whenever they are opened; this allows checking what would change first.
+func migrateCommand(ctx context.Context, args []string) {
+	flags := flag.NewFlagSet("migrate", flag.ExitOnError)
+	var (
+		dbPath = flags.String("db-path", "", "path to database file")
+		dryRun = flags.Bool("dry-run", false, "only list the migrations that would be applied")
+	)
+
+	flags.Parse(args)
+
+	if *dbPath == "" {
+		log.Fatalf("Cannot migrate without a database path.")
+	}
+
+	sqlb := new(db.SQLiteBackend)
+	if err := sqlb.OpenUnmigrated(*dbPath); err != nil {
+		log.Fatalf("Error opening sqlite database, received error: %s", err)
+	}
+
+	defer sqlb.Close()
+
+	version, err := sqlb.SchemaVersion(ctx)
+	if err != nil {
+		log.Fatalf("Error reading schema version: %s", err)
+	}
+
+	pending, err := sqlb.PendingMigrations(ctx)
+	if err != nil {
+		log.Fatalf("Error reading pending migrations: %s", err)
+	}
+
+	fmt.Printf("Schema version %d, latest is %d.\n", version, db.LatestSchemaVersion())
+
+	if len(pending) == 0 {
+		return
+	} else if *dryRun {
+		fmt.Println("Would apply:")
+		for _, migration := range pending {
+			fmt.Printf("  %d: %s\n", migration.Version, migration.Description)
+		}
+
+		return
+	}
+
+	applied, err := sqlb.Migrate(ctx)
+	if err != nil {
+		log.Fatalf("Error migrating database after applying %d migrations: %s", len(applied), err)
+	}
+
+	fmt.Printf("Applied %d migrations.\n", len(applied))
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/claucambra/commit-analysis-tool/pkg/statistics/authorgroups"
+)
+
+// Runs the retention command, which fits a Cox proportional hazards model of how long the authors
+// of a database stay active and lists the hazard ratio of each covariate, e.g. whether authors of
+// a group of domains are likelier to leave than others with as many commits
+func retentionCommand(ctx context.Context, args []string) {
+	flags := flag.NewFlagSet("retention", flag.ExitOnError)
+	var (
+		dbPath               = flags.String("db-path", "", "path to database file")
+		repoName             = flags.String("repo-name", "", "only model authors of this repository, instead of taking each author's main repository into account")
+		domainGroupsFilePath = flags.String("domain-groups-file-path", "", "file containing email domain groups")
+		groupName            = flags.String("group", "Corporate", "domain group whose authors' hazard is compared to other authors'")
+		censorMonths         = flags.Int("censor-months", authorgroups.DefaultCensorMonths, "consider authors active this many months or fewer before the last commit, or within the activity gap or window, as still active")
+		activityKind         = flags.String("activity", authorgroups.ContinuousActivityKind, fmt.Sprintf("how long authors count as active, one of %v", authorgroups.ActivityKinds))
+		activityGapMonths    = flags.Int("activity-gap-months", 0, "months without commits a continuously active author can skip")
+		activityWindowMonths = flags.Int("activity-window-months", 3, "months of each window authors must commit enough in to stay active with -activity window")
+		activityMinCommits   = flags.Int("activity-min-commits", 1, "commits authors must make in each window to stay active with -activity window")
+	)
+
+	flags.Parse(args)
+
+	if *dbPath == "" {
+		log.Fatalf("Cannot model retention without a database path.")
+	} else if *domainGroupsFilePath == "" {
+		log.Fatalf("Cannot model retention without a domain groups file.")
+	}
+
+	activity := newActivityDefinition(*activityKind, authorgroups.ActivityOptions{
+		GapMonths:        *activityGapMonths,
+		WindowMonths:     *activityWindowMonths,
+		MinWindowCommits: *activityMinCommits,
+	})
+
+	groupsJsonBytes, err := os.ReadFile(*domainGroupsFilePath)
+	if err != nil {
+		log.Fatalf("Error opening domain groups json file: %s", err)
+	}
+
+	var groups map[string][]string
+	if err := json.Unmarshal(groupsJsonBytes, &groups); err != nil {
+		log.Fatalf("Error parsing domain groups json file: %s", err)
+	}
+
+	sqlb := newSql(ctx, *dbPath)
+	defer sqlb.Close()
+
+	report := authorgroups.NewRetentionReport(groups, sqlb, *groupName, *repoName)
+	report.Activity = activity
+	report.CensorMonths = *censorMonths
+	if err := report.Generate(ctx); err != nil {
+		log.Fatalf("Error generating retention report: %s", err)
+	}
+
+	model := report.Model
+	formatFloat := func(value float64) string { return strconv.FormatFloat(value, 'g', 4, 64) }
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "COVARIATE\tHAZARD RATIO\tLOWER\tUPPER\tP\tPH CHISQ\tPH P")
+
+	for _, covariate := range model.Covariates {
+		fmt.Fprintf(writer, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			covariate.Name,
+			formatFloat(covariate.HazardRatio),
+			formatFloat(covariate.Lower),
+			formatFloat(covariate.Upper),
+			formatFloat(covariate.PValue),
+			formatFloat(covariate.ProportionalHazardsChiSquare),
+			formatFloat(covariate.ProportionalHazardsPValue))
+	}
+
+	fmt.Fprintf(writer, "GLOBAL\t\t\t\t\t%s\t%s\n",
+		formatFloat(model.ProportionalHazardsChiSquare),
+		formatFloat(model.ProportionalHazardsPValue))
+	writer.Flush()
+
+	log.Printf("Fitted %d authors, %d of whom left, likelihood ratio chi-square %s with p-value %s.",
+		model.NumObservations,
+		model.NumEvents,
+		formatFloat(model.LikelihoodRatioChiSquare),
+		formatFloat(model.LikelihoodRatioPValue))
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/claucambra/commit-analysis-tool/pkg/common"
+	"github.com/claucambra/commit-analysis-tool/pkg/statistics/authorgroups"
+)
+
+// Commit orders selectable with the search command's -order flag
+var searchOrders = map[string]common.CommitOrder{
+	"ingest":         common.OrderByIngest,
+	"author-time":    common.OrderByAuthorTime,
+	"committer-time": common.OrderByCommitterTime,
+	"line-changes":   common.OrderByLineChanges,
+}
+
+// Runs the search command, which lists the commits of a database whose subject or body match a
+// full-text query, e.g. search -db-path commits.db 'subject:crash OR "memory leak" NOT test*'.
+// Flags narrow the commits down further; without a query all commits passing them are listed.
+func searchCommand(ctx context.Context, args []string) {
+	flags := flag.NewFlagSet("search", flag.ExitOnError)
+	var (
+		dbPath               = flags.String("db-path", "", "path to database file")
+		repoName             = flags.String("repo-name", "", "only search commits of this repository")
+		domainGroupsFilePath = flags.String("domain-groups-file-path", "", "file containing email domain groups to list the groups of authors")
+		rebuildIndex         = flags.Bool("rebuild-index", false, "recreate the search index before searching, e.g. after building with -tags sqlite_fts5")
+		authorEmail          = flags.String("author-email", "", "only list commits by this author email")
+		authorDomain         = flags.String("author-domain", "", "only list commits by authors of this email domain")
+		includeSubdomains    = flags.Bool("include-subdomains", false, "also match subdomains of -author-domain")
+		minLineChanges       = flags.Int("min-line-changes", -1, "only list commits inserting and deleting at least this many lines")
+		maxLineChanges       = flags.Int("max-line-changes", -1, "only list commits inserting and deleting at most this many lines")
+		order                = flags.String("order", "ingest", "order of the commits, one of ingest, author-time, committer-time or line-changes")
+		descending           = flags.Bool("descending", false, "list commits in descending order")
+		limit                = flags.Int("limit", 0, "maximum number of commits to list (0 for all)")
+		offset               = flags.Int("offset", 0, "number of commits to skip")
+		since                timeFlag
+		until                = timeFlag{endOfDay: true}
+	)
+
+	flags.Var(&since, "since", "only list commits authored at or after this date (2006-01-02) or time (RFC 3339)")
+	flags.Var(&until, "until", "only list commits authored at or before this date (2006-01-02, all of it) or time (RFC 3339)")
+	flags.Parse(args)
+
+	commitOrder, ok := searchOrders[*order]
+	if *dbPath == "" {
+		log.Fatalf("Cannot search without a database path.")
+	} else if !ok {
+		log.Fatalf("Unknown order %s.", *order)
+	}
+
+	query := common.NewCommitQuery().
+		WithRepo(*repoName).
+		WithText(strings.Join(flags.Args(), " ")).
+		WithAuthorEmail(*authorEmail).
+		WithAuthorDomain(*authorDomain, *includeSubdomains).
+		WithAuthorTimeRange(since.Time, until.Time).
+		WithLineChangesRange(*minLineChanges, *maxLineChanges).
+		WithOrder(commitOrder, *descending).
+		WithOffset(*offset).
+		WithLimit(*limit)
+
+	groups := map[string][]string{}
+	if *domainGroupsFilePath != "" {
+		groupsJsonBytes, err := os.ReadFile(*domainGroupsFilePath)
+		if err != nil {
+			log.Fatalf("Error opening domain groups json file: %s", err)
+		}
+
+		if err := json.Unmarshal(groupsJsonBytes, &groups); err != nil {
+			log.Fatalf("Error parsing domain groups json file: %s", err)
+		}
+	}
+
+	sqlb := newSql(ctx, *dbPath)
+	defer sqlb.Close()
+
+	if *rebuildIndex {
+		if err := sqlb.RebuildSearchIndex(ctx); err != nil {
+			log.Fatalf("Error rebuilding search index: %s", err)
+		}
+
+		module, _ := sqlb.SearchModule(ctx)
+		log.Printf("Rebuilt search index with %s.", module)
+
+		if query.Text == "" {
+			return
+		}
+	}
+
+	results, err := authorgroups.Search(ctx, sqlb, groups, query)
+	if err != nil {
+		log.Fatalf("Error searching commits: %s", err)
+	}
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "COMMIT\tDATE\tREPO\tAUTHOR\tGROUPS\tSUBJECT")
+
+	for _, result := range results {
+		commit := result.Commit
+		shortId := commit.Id
+		if len(shortId) > 10 {
+			shortId = shortId[:10]
+		}
+
+		fmt.Fprintf(writer, "%s\t%s\t%s\t%s <%s>\t%s\t%s\n",
+			shortId,
+			time.Unix(commit.AuthorTime, 0).UTC().Format("2006-01-02"),
+			commit.RepoName,
+			commit.Author.Name,
+			commit.Author.Email,
+			strings.Join(result.Groups, ","),
+			commit.Subject)
+	}
+
+	writer.Flush()
+	log.Printf("Found %d matching commits.", len(results))
+}
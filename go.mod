@@ -2,11 +2,33 @@ module github.com/claucambra/commit-analysis-tool
 
 go 1.19
 
-require github.com/mattn/go-sqlite3 v1.14.16
+require (
+	github.com/go-git/go-git/v5 v5.7.0
+	github.com/google/go-cmp v0.5.9
+	github.com/mattn/go-sqlite3 v1.14.16
+	github.com/sashabaranov/go-openai v1.9.5
+	golang.org/x/exp v0.0.0-20230522175609-2e198f4a06a1
+	gonum.org/v1/gonum v0.13.0
+)
 
 require (
-	github.com/google/go-cmp v0.5.9 // indirect
-	github.com/sashabaranov/go-openai v1.9.5 // indirect
-	golang.org/x/exp v0.0.0-20230522175609-2e198f4a06a1 // indirect
-	gonum.org/v1/gonum v0.13.0 // indirect
+	github.com/Microsoft/go-winio v0.5.2 // indirect
+	github.com/ProtonMail/go-crypto v0.0.0-20230518184743-7afd39499903 // indirect
+	github.com/acomagu/bufpipe v1.0.4 // indirect
+	github.com/cloudflare/circl v1.3.3 // indirect
+	github.com/emirpasic/gods v1.18.1 // indirect
+	github.com/go-git/gcfg v1.5.1-0.20230307220236-3a3c6141e376 // indirect
+	github.com/go-git/go-billy/v5 v5.4.1 // indirect
+	github.com/golang/groupcache v0.0.0-20210331224755-41bb18bfe9da // indirect
+	github.com/imdario/mergo v0.3.15 // indirect
+	github.com/jbenet/go-context v0.0.0-20150711004518-d14ea06fba99 // indirect
+	github.com/kevinburke/ssh_config v1.2.0 // indirect
+	github.com/pjbgf/sha1cd v0.3.0 // indirect
+	github.com/sergi/go-diff v1.1.0 // indirect
+	github.com/skeema/knownhosts v1.1.1 // indirect
+	github.com/xanzy/ssh-agent v0.3.3 // indirect
+	golang.org/x/crypto v0.9.0 // indirect
+	golang.org/x/net v0.10.0 // indirect
+	golang.org/x/sys v0.8.0 // indirect
+	gopkg.in/warnings.v0 v0.1.2 // indirect
 )
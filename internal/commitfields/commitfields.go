@@ -0,0 +1,245 @@
+package commitfields
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/claucambra/commit-analysis-tool/pkg/common"
+)
+
+// A piece of commit data: how git prints it, how it is parsed into a Commit and where it is
+// stored. The pretty format string, the log parser and the columns commits are inserted into and
+// scanned from are built from Fields. The commits table itself is not: its schema comes from the
+// database migrations, so a stored field also needs a migration creating its column.
+type Field struct {
+	// Identifies the field, e.g. in parse errors
+	Name string
+	// git pretty format placeholder printing the field, empty if it is not read from the
+	// pretty format (e.g. change counts, which are read from the stat lines)
+	Placeholder string
+	// Column of the commits table storing the field, empty if it is not stored there (e.g.
+	// parents, which have their own table)
+	Column string
+	// Whether logs may lack the field. Optional fields come last in the pretty format, so that
+	// logs written before they were added can still be parsed.
+	Optional bool
+
+	// Sets the field of the commit from the value git printed for the placeholder
+	Parse func(commit *common.Commit, value string) error
+	// Pointer to the commit's field, to read or scan the column into
+	Pointer func(commit *common.Commit) any
+	// Value of a column derived from other fields, set instead of Pointer. Derived columns are
+	// stored for queries to use but are not read back into commits.
+	Value func(commit *common.Commit) any
+}
+
+// All commit fields, in the order of the pretty format and of the columns selected from the
+// commits table
+var Fields = []*Field{
+	{
+		Name:        "id",
+		Placeholder: "%H",
+		Column:      "id",
+		Parse:       func(commit *common.Commit, value string) error { commit.Id = value; return nil },
+		Pointer:     func(commit *common.Commit) any { return &commit.Id },
+	},
+	{
+		Name:        "author time",
+		Placeholder: "%aD",
+		Column:      "author_time",
+		Parse:       func(commit *common.Commit, value string) error { return parseTime(&commit.AuthorTime, value) },
+		Pointer:     func(commit *common.Commit) any { return &commit.AuthorTime },
+	},
+	{
+		Name:        "author name",
+		Placeholder: "%aN",
+		Column:      "author_name",
+		Parse:       func(commit *common.Commit, value string) error { commit.Author.Name = value; return nil },
+		Pointer:     func(commit *common.Commit) any { return &commit.Author.Name },
+	},
+	{
+		Name:        "author email",
+		Placeholder: "%aE",
+		Column:      "author_email",
+		Parse:       func(commit *common.Commit, value string) error { commit.Author.Email = value; return nil },
+		Pointer:     func(commit *common.Commit) any { return &commit.Author.Email },
+	},
+	{
+		Name:        "committer time",
+		Placeholder: "%cD",
+		Column:      "committer_time",
+		Parse:       func(commit *common.Commit, value string) error { return parseTime(&commit.CommitterTime, value) },
+		Pointer:     func(commit *common.Commit) any { return &commit.CommitterTime },
+	},
+	{
+		Name:        "committer name",
+		Placeholder: "%cN",
+		Column:      "committer_name",
+		Parse:       func(commit *common.Commit, value string) error { commit.Committer.Name = value; return nil },
+		Pointer:     func(commit *common.Commit) any { return &commit.Committer.Name },
+	},
+	{
+		Name:        "committer email",
+		Placeholder: "%cE",
+		Column:      "committer_email",
+		Parse:       func(commit *common.Commit, value string) error { commit.Committer.Email = value; return nil },
+		Pointer:     func(commit *common.Commit) any { return &commit.Committer.Email },
+	},
+	{
+		Name:        "subject",
+		Placeholder: "%s",
+		Column:      "subject",
+		Parse:       func(commit *common.Commit, value string) error { commit.Subject = value; return nil },
+		Pointer:     func(commit *common.Commit) any { return &commit.Subject },
+	},
+	{
+		Name:        "body",
+		Placeholder: "%b",
+		Column:      "body",
+		Parse:       func(commit *common.Commit, value string) error { commit.Body = value; return nil },
+		Pointer:     func(commit *common.Commit) any { return &commit.Body },
+	},
+	{
+		Name:        "parents",
+		Placeholder: "%P",
+		Optional:    true,
+		Parse:       parseParentIds,
+	},
+	{
+		Name:    "insertions",
+		Column:  "num_insertions",
+		Pointer: func(commit *common.Commit) any { return &commit.NumInsertions },
+	},
+	{
+		Name:    "deletions",
+		Column:  "num_deletions",
+		Pointer: func(commit *common.Commit) any { return &commit.NumDeletions },
+	},
+	{
+		Name:    "files changed",
+		Column:  "num_files_changed",
+		Pointer: func(commit *common.Commit) any { return &commit.NumFilesChanged },
+	},
+	{
+		Name:   "author domain",
+		Column: "author_domain",
+		Value:  func(commit *common.Commit) any { return commit.Author.Domain() },
+	},
+	{
+		Name:   "committer domain",
+		Column: "committer_domain",
+		Value:  func(commit *common.Commit) any { return commit.Committer.Domain() },
+	},
+}
+
+// Fields printed by the pretty format, in order
+func PrettyFormatFields() []*Field {
+	fields := []*Field{}
+	for _, field := range Fields {
+		if field.Placeholder != "" {
+			fields = append(fields, field)
+		}
+	}
+
+	return fields
+}
+
+// Fields stored in the commits table, in column order
+func ColumnFields() []*Field {
+	fields := []*Field{}
+	for _, field := range Fields {
+		if field.Column != "" {
+			fields = append(fields, field)
+		}
+	}
+
+	return fields
+}
+
+// Fields stored in the commits table that are read back into commits, i.e. not derived
+func ReadColumnFields() []*Field {
+	fields := []*Field{}
+	for _, field := range ColumnFields() {
+		if field.Pointer != nil {
+			fields = append(fields, field)
+		}
+	}
+
+	return fields
+}
+
+func qualifiedColumns(fields []*Field, qualifier string) []string {
+	columns := []string{}
+	for _, field := range fields {
+		if qualifier != "" {
+			columns = append(columns, qualifier+"."+field.Column)
+		} else {
+			columns = append(columns, field.Column)
+		}
+	}
+
+	return columns
+}
+
+// Columns of the commits table, optionally qualified with a table name or alias, to insert into
+func Columns(qualifier string) []string {
+	return qualifiedColumns(ColumnFields(), qualifier)
+}
+
+// Columns of the commits table that are read back into commits, to select
+func ReadColumns(qualifier string) []string {
+	return qualifiedColumns(ReadColumnFields(), qualifier)
+}
+
+// Pointers to the commit's fields in column order, to scan a row of ReadColumns into
+func ColumnPointers(commit *common.Commit) []any {
+	pointers := []any{}
+	for _, field := range ReadColumnFields() {
+		pointers = append(pointers, field.Pointer(commit))
+	}
+
+	return pointers
+}
+
+// Values of the commit's fields in column order, to insert into Columns
+func ColumnValues(commit *common.Commit) []any {
+	values := []any{}
+	for _, field := range ColumnFields() {
+		if field.Value != nil {
+			values = append(values, field.Value(commit))
+		} else {
+			values = append(values, reflect.ValueOf(field.Pointer(commit)).Elem().Interface())
+		}
+	}
+
+	return values
+}
+
+func parseTime(target *int64, value string) error {
+	parsedTime, err := time.Parse(common.TimeFormat, value)
+	if err != nil {
+		return err
+	}
+
+	*target = parsedTime.Unix()
+	return nil
+}
+
+var parentIdsRegex = regexp.MustCompile(`^[0-9a-f]*( [0-9a-f]+)*$`)
+
+// Root commits have no parents, so this leaves ParentIds nil rather than holding an empty hash
+func parseParentIds(commit *common.Commit, value string) error {
+	if !parentIdsRegex.MatchString(value) {
+		return fmt.Errorf("unexpected parent hashes: %q", value)
+	}
+
+	parentIds := strings.Fields(value)
+	if len(parentIds) > 0 {
+		commit.ParentIds = parentIds
+	}
+
+	return nil
+}
@@ -0,0 +1,100 @@
+package commitfields
+
+import (
+	"testing"
+
+	"github.com/claucambra/commit-analysis-tool/pkg/common"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestFields(t *testing.T) {
+	placeholders := map[string]bool{}
+	columns := map[string]bool{}
+	optionalSeen := false
+
+	for _, field := range Fields {
+		if field.Placeholder != "" {
+			if placeholders[field.Placeholder] {
+				t.Fatalf("Placeholder %s used by several fields", field.Placeholder)
+			} else if field.Parse == nil {
+				t.Fatalf("Field %s has a placeholder but no parser", field.Name)
+			} else if optionalSeen && !field.Optional {
+				t.Fatalf("Required field %s comes after an optional field in the pretty format", field.Name)
+			}
+
+			placeholders[field.Placeholder] = true
+			optionalSeen = optionalSeen || field.Optional
+		}
+
+		if field.Column != "" {
+			if columns[field.Column] {
+				t.Fatalf("Column %s used by several fields", field.Column)
+			} else if (field.Pointer == nil) == (field.Value == nil) {
+				t.Fatalf("Field %s has a column but not either a pointer or a derived value", field.Name)
+			}
+
+			columns[field.Column] = true
+		}
+	}
+}
+
+func TestColumnValues(t *testing.T) {
+	commit := &common.Commit{Id: "4610c5caa1b48f113ee87f48aeace2846a474957"}
+
+	for _, field := range PrettyFormatFields() {
+		value := ""
+		switch field.Placeholder {
+		case "%aD", "%cD":
+			value = "Sun, 4 Jun 2023 16:35:34 +0800"
+		case "%H", "%P":
+			value = "4610c5caa1b48f113ee87f48aeace2846a474957"
+		default:
+			value = field.Name
+		}
+
+		if err := field.Parse(commit, value); err != nil {
+			t.Fatalf("Could not parse %s: %s", field.Name, err)
+		}
+	}
+
+	commit.NumInsertions = 5
+	commit.Author.Email = "author@Example.org"
+
+	expectedValues := []any{
+		"4610c5caa1b48f113ee87f48aeace2846a474957",
+		int64(1685867734),
+		"author name",
+		"author@Example.org",
+		int64(1685867734),
+		"committer name",
+		"committer email",
+		"subject",
+		"body",
+		5,
+		0,
+		0,
+		"example.org",
+		"",
+	}
+
+	if values := ColumnValues(commit); !cmp.Equal(expectedValues, values) {
+		t.Fatalf("Unexpected column values: %s", cmp.Diff(expectedValues, values))
+	}
+
+	scannedCommit := new(common.Commit)
+	for i, pointer := range ColumnPointers(scannedCommit) {
+		switch target := pointer.(type) {
+		case *string:
+			*target = expectedValues[i].(string)
+		case *int64:
+			*target = expectedValues[i].(int64)
+		case *int:
+			*target = expectedValues[i].(int)
+		}
+	}
+
+	commit.ParentIds = nil
+	if !cmp.Equal(commit, scannedCommit) {
+		t.Fatalf("Scanned commit does not equal parsed commit: %s", cmp.Diff(commit, scannedCommit))
+	}
+}
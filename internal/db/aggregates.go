@@ -0,0 +1,129 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/claucambra/commit-analysis-tool/pkg/common"
+)
+
+const yearExpression = "CAST(strftime('%Y', commits.author_time, 'unixepoch') AS INT)"
+const monthExpression = "CAST(strftime('%m', commits.author_time, 'unixepoch') AS INT)"
+
+// Totals of the repository's commits (or of all repositories' commits if repoName is empty)
+// grouped by the dimensions, summed up by SQL. See common.AggregateCommits for the meaning of the
+// dimensions and the order of the aggregates.
+func (sqlb *SQLiteBackend) Aggregates(ctx context.Context, repoName string, dimensions ...common.AggregateDimension) ([]*common.Aggregate, error) {
+	return sqlb.aggregates(ctx, repoName, "", nil, dimensions...)
+}
+
+// Aggregates of the commits also matching the condition, if not empty
+func (sqlb *SQLiteBackend) aggregates(ctx context.Context, repoName string, condition string, conditionArgs []any, dimensions ...common.AggregateDimension) ([]*common.Aggregate, error) {
+	byDimension := map[common.AggregateDimension]bool{}
+	for _, dimension := range dimensions {
+		byDimension[dimension] = true
+	}
+
+	// Expressions grouped by and the aggregate fields they are scanned into
+	groupExpressions := []string{}
+	groupTargets := []func(*common.Aggregate) any{}
+	addedExpressions := map[string]bool{}
+
+	addGroup := func(expression string, target func(*common.Aggregate) any) {
+		if addedExpressions[expression] {
+			return
+		}
+
+		addedExpressions[expression] = true
+		groupExpressions = append(groupExpressions, expression)
+		groupTargets = append(groupTargets, target)
+	}
+
+	for _, dimension := range dimensions {
+		switch dimension {
+		case common.AggregateByDomain:
+			addGroup("commits.author_domain", func(aggregate *common.Aggregate) any { return &aggregate.Domain })
+		case common.AggregateByAuthor:
+			addGroup("commits.author_email", func(aggregate *common.Aggregate) any { return &aggregate.AuthorEmail })
+		case common.AggregateByYear:
+			addGroup(yearExpression, func(aggregate *common.Aggregate) any { return &aggregate.Year })
+		case common.AggregateByMonth:
+			addGroup(yearExpression, func(aggregate *common.Aggregate) any { return &aggregate.Year })
+			addGroup(monthExpression, func(aggregate *common.Aggregate) any { return &aggregate.Month })
+		case common.AggregateByRepo:
+			addGroup("repo_commits.repo_name", func(aggregate *common.Aggregate) any { return &aggregate.RepoName })
+		}
+	}
+
+	selectExpressions := append(append([]string{}, groupExpressions...),
+		"COUNT(*)",
+		"COUNT(DISTINCT commits.author_email)",
+		"COALESCE(SUM(commits.num_insertions), 0)",
+		"COALESCE(SUM(commits.num_deletions), 0)")
+
+	stmt := "SELECT " + strings.Join(selectExpressions, ", ") + " FROM commits"
+	args := []any{}
+
+	// Commits are counted once for each repository they are part of when grouping by repository
+	if byDimension[common.AggregateByRepo] {
+		stmt += " JOIN repo_commits ON repo_commits.commit_id = commits.id"
+		if repoName != "" {
+			stmt += " WHERE repo_commits.repo_name = ?"
+			args = append(args, repoName)
+		} else {
+			stmt += " WHERE 1 = 1"
+		}
+	} else {
+		repoCondition, repoArgs := RepoCondition(repoName)
+		stmt += " WHERE " + repoCondition
+		args = append(args, repoArgs...)
+	}
+
+	if condition != "" {
+		stmt += " AND (" + condition + ")"
+		args = append(args, conditionArgs...)
+	}
+
+	if len(groupExpressions) > 0 {
+		groupBy := strings.Join(groupExpressions, ", ")
+		stmt += " GROUP BY " + groupBy + " ORDER BY " + groupBy
+	}
+
+	rows, err := sqlb.Db.QueryContext(ctx, stmt, args...)
+	if err != nil {
+		return nil, fmt.Errorf("retrieving commit aggregates: %w", err)
+	}
+
+	defer rows.Close()
+
+	aggregates := []*common.Aggregate{}
+	for rows.Next() {
+		aggregate, err := scanAggregate(rows, groupTargets)
+		if err != nil {
+			return nil, fmt.Errorf("reading commit aggregate: %w", err)
+		}
+
+		aggregates = append(aggregates, aggregate)
+	}
+
+	return aggregates, rows.Err()
+}
+
+func scanAggregate(rows *sql.Rows, groupTargets []func(*common.Aggregate) any) (*common.Aggregate, error) {
+	aggregate := &common.Aggregate{}
+
+	pointers := []any{}
+	for _, target := range groupTargets {
+		pointers = append(pointers, target(aggregate))
+	}
+
+	pointers = append(pointers,
+		&aggregate.NumCommits,
+		&aggregate.NumAuthors,
+		&aggregate.NumInsertions,
+		&aggregate.NumDeletions)
+
+	return aggregate, rows.Scan(pointers...)
+}
@@ -0,0 +1,414 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/claucambra/commit-analysis-tool/internal/commitfields"
+	"github.com/claucambra/commit-analysis-tool/pkg/common"
+)
+
+// Number of commits read between progress reports
+const ingestProgressInterval = 1000
+
+// Executes statements on the database or within a transaction
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// Progress of an ingest, reported every ingestProgressInterval commits and once done
+type IngestProgress struct {
+	NumRead  int
+	NumAdded int
+	Elapsed  time.Duration
+	Done     bool
+}
+
+// Ingest of a repository that is applied atomically: nothing it adds is visible until Commit is
+// called, and nothing is kept if it is rolled back or the process stops before then. Statements
+// are prepared once for the whole ingest.
+type Ingest struct {
+	tx     *sql.Tx
+	writer *commitWriter
+}
+
+// Begins an ingest. Cancelling the context rolls back the ingest's transaction.
+func (sqlb *SQLiteBackend) BeginIngest(ctx context.Context) (*Ingest, error) {
+	tx, err := sqlb.Db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("beginning ingest transaction: %w", err)
+	}
+
+	// Commits left out of the search index would silently be missing from every later search, even
+	// from builds that can use the index, so commits are not ingested without it
+	if err := searchIndexUsable(ctx, tx); err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("cannot keep the search index up to date: %w", err)
+	}
+
+	writer, err := newCommitWriter(ctx, tx)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	return &Ingest{tx: tx, writer: writer}, nil
+}
+
+// Adds commits as they are produced by the iterator. Commits already in the database are not
+// added again, but are recorded as part of the commit's repository if they were ingested from
+// another one. Returns the number of commits added to the repository. progress, if not nil, is
+// called regularly while commits are read. Stops with the context's error once it is cancelled.
+func (ingest *Ingest) IngestCommits(ctx context.Context, commits common.CommitIterator, progress func(IngestProgress)) (int, error) {
+	startTime := time.Now()
+	numRead := 0
+	numAdded := 0
+
+	for commits.Next() {
+		if err := ctx.Err(); err != nil {
+			return numAdded, err
+		}
+
+		commit := commits.Commit()
+		numRead++
+
+		exists, err := ingest.writer.hasCommit(ctx, commit.Id)
+		if err != nil {
+			return numAdded, err
+		} else if exists {
+			added, err := ingest.writer.addRepoCommit(ctx, commit.RepoName, commit.Id)
+			if err != nil {
+				return numAdded, err
+			} else if added {
+				numAdded++
+			}
+		} else if err := ingest.writer.addCommit(ctx, commit); err != nil {
+			return numAdded, err
+		} else {
+			numAdded++
+		}
+
+		if progress != nil && numRead%ingestProgressInterval == 0 {
+			progress(IngestProgress{NumRead: numRead, NumAdded: numAdded, Elapsed: time.Since(startTime)})
+		}
+	}
+
+	if err := commits.Err(); err != nil {
+		return numAdded, fmt.Errorf("reading commits to ingest: %w", err)
+	}
+
+	if progress != nil {
+		progress(IngestProgress{NumRead: numRead, NumAdded: numAdded, Elapsed: time.Since(startTime), Done: true})
+	}
+
+	return numAdded, nil
+}
+
+// See SQLiteBackend.SetIngestedRefTips
+func (ingest *Ingest) SetIngestedRefTips(ctx context.Context, repoName string, tips map[string]string) error {
+	return setIngestedRefTips(ctx, ingest.tx, repoName, tips)
+}
+
+// See SQLiteBackend.AddRepository
+func (ingest *Ingest) AddRepository(ctx context.Context, repo *common.Repository) error {
+	return addRepository(ctx, ingest.tx, repo)
+}
+
+// Makes everything added by the ingest visible
+func (ingest *Ingest) Commit() error {
+	ingest.writer.close()
+
+	if err := ingest.tx.Commit(); err != nil {
+		return fmt.Errorf("committing ingest transaction: %w", err)
+	}
+
+	return nil
+}
+
+// Discards everything added by the ingest
+func (ingest *Ingest) Rollback() error {
+	ingest.writer.close()
+	return ingest.tx.Rollback()
+}
+
+// Prepared statements adding commits and their details within a transaction
+type commitWriter struct {
+	hasCommitStmt      *sql.Stmt
+	insertCommitStmt   *sql.Stmt
+	insertRepoStmt     *sql.Stmt
+	deleteFilesStmt    *sql.Stmt
+	insertFileStmt     *sql.Stmt
+	deleteTrailersStmt *sql.Stmt
+	insertTrailerStmt  *sql.Stmt
+	deleteParentsStmt  *sql.Stmt
+	insertParentStmt   *sql.Stmt
+	deleteSearchStmt   *sql.Stmt
+	insertSearchStmt   *sql.Stmt
+}
+
+// Statement to prepare into a field of commitWriter
+type writerStatement struct {
+	stmt   **sql.Stmt
+	source string
+}
+
+const insertRepoCommitStmt = "INSERT INTO repo_commits (repo_name, commit_id) VALUES (?1, ?2)"
+
+func insertCommitStmt() string {
+	columns := commitfields.Columns("")
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(columns)), ", ")
+	return "INSERT INTO commits (" + strings.Join(columns, ", ") + ") VALUES (" + placeholders + ")"
+}
+
+func newCommitWriter(ctx context.Context, tx *sql.Tx) (*commitWriter, error) {
+	cw := &commitWriter{}
+	statements := []writerStatement{
+		{&cw.hasCommitStmt, "SELECT EXISTS (SELECT 1 FROM commits WHERE id = ?)"},
+		{&cw.insertCommitStmt, insertCommitStmt()},
+		{&cw.insertRepoStmt, insertRepoCommitStmt},
+		{&cw.deleteFilesStmt, "DELETE FROM commit_files WHERE commit_id = ?"},
+		{&cw.insertFileStmt, `INSERT INTO commit_files (
+			commit_id,
+			path,
+			previous_path,
+			extension,
+			num_insertions,
+			num_deletions,
+			binary
+		) VALUES (?1, ?2, ?3, ?4, ?5, ?6, ?7)`},
+		{&cw.deleteTrailersStmt, "DELETE FROM commit_trailers WHERE commit_id = ?"},
+		{&cw.insertTrailerStmt, `INSERT INTO commit_trailers (
+			commit_id,
+			kind,
+			key,
+			value,
+			person_name,
+			person_email
+		) VALUES (?1, ?2, ?3, ?4, ?5, ?6)`},
+		{&cw.deleteParentsStmt, "DELETE FROM commit_parents WHERE commit_id = ?"},
+		{&cw.insertParentStmt, "INSERT INTO commit_parents (commit_id, parent_id, parent_index) VALUES (?1, ?2, ?3)"},
+		// Replacing a commit gives it a new rowid, so its search entry is replaced along with it
+		{&cw.deleteSearchStmt, "DELETE FROM " + searchTable + " WHERE rowid = (SELECT rowid FROM commits WHERE id = ?)"},
+		{&cw.insertSearchStmt, "INSERT INTO " + searchTable + " (rowid, subject, body) SELECT rowid, subject, body FROM commits WHERE id = ?"},
+	}
+
+	for _, statement := range statements {
+		prepared, err := tx.PrepareContext(ctx, statement.source)
+		if err != nil {
+			cw.close()
+			return nil, fmt.Errorf("preparing ingest statement: %w", err)
+		}
+
+		*statement.stmt = prepared
+	}
+
+	return cw, nil
+}
+
+func (cw *commitWriter) close() {
+	for _, stmt := range []*sql.Stmt{
+		cw.hasCommitStmt,
+		cw.insertCommitStmt,
+		cw.insertRepoStmt,
+		cw.deleteFilesStmt,
+		cw.insertFileStmt,
+		cw.deleteTrailersStmt,
+		cw.insertTrailerStmt,
+		cw.deleteParentsStmt,
+		cw.insertParentStmt,
+		cw.deleteSearchStmt,
+		cw.insertSearchStmt,
+	} {
+		if stmt != nil {
+			stmt.Close()
+		}
+	}
+}
+
+func (cw *commitWriter) hasCommit(ctx context.Context, commitId string) (bool, error) {
+	var exists bool
+	if err := cw.hasCommitStmt.QueryRowContext(ctx, commitId).Scan(&exists); err != nil {
+		return false, fmt.Errorf("checking for commit %s: %w", commitId, err)
+	}
+
+	return exists, nil
+}
+
+func (cw *commitWriter) addCommit(ctx context.Context, commit *common.Commit) error {
+	if commit == nil {
+		return errors.New("received a nil commit, won't add to db")
+	}
+
+	if _, err := cw.deleteSearchStmt.ExecContext(ctx, commit.Id); err != nil {
+		return fmt.Errorf("removing commit %s from search index: %w", commit.Id, err)
+	}
+
+	_, err := cw.insertCommitStmt.ExecContext(ctx, commitfields.ColumnValues(commit)...)
+	if err != nil {
+		return fmt.Errorf("adding commit %s: %w", commit.Id, err)
+	}
+
+	if _, err := cw.insertSearchStmt.ExecContext(ctx, commit.Id); err != nil {
+		return fmt.Errorf("adding commit %s to search index: %w", commit.Id, err)
+	}
+
+	if _, err = cw.addRepoCommit(ctx, commit.RepoName, commit.Id); err != nil {
+		return err
+	}
+
+	if err = cw.addFileChanges(ctx, commit); err != nil {
+		return err
+	}
+
+	if err = cw.addTrailers(ctx, commit); err != nil {
+		return err
+	}
+
+	return cw.addParents(ctx, commit)
+}
+
+func (cw *commitWriter) addRepoCommit(ctx context.Context, repoName string, commitId string) (bool, error) {
+	return addRepoCommit(ctx, execStmt{cw.insertRepoStmt}, repoName, commitId)
+}
+
+func (cw *commitWriter) addFileChanges(ctx context.Context, commit *common.Commit) error {
+	// Commits are replaced when added again, so make sure their files are too
+	_, err := cw.deleteFilesStmt.ExecContext(ctx, commit.Id)
+	if err != nil {
+		return fmt.Errorf("clearing file changes of commit %s: %w", commit.Id, err)
+	}
+
+	for _, fileChange := range commit.FileChanges {
+		_, err := cw.insertFileStmt.ExecContext(ctx,
+			commit.Id,
+			fileChange.Path,
+			fileChange.PreviousPath,
+			fileChange.Extension(),
+			fileChange.NumInsertions,
+			fileChange.NumDeletions,
+			fileChange.Binary)
+
+		if err != nil {
+			return fmt.Errorf("adding file change of commit %s: %w", commit.Id, err)
+		}
+	}
+
+	return nil
+}
+
+func (cw *commitWriter) addTrailers(ctx context.Context, commit *common.Commit) error {
+	_, err := cw.deleteTrailersStmt.ExecContext(ctx, commit.Id)
+	if err != nil {
+		return fmt.Errorf("clearing trailers of commit %s: %w", commit.Id, err)
+	}
+
+	for _, trailer := range commit.Trailers {
+		_, err := cw.insertTrailerStmt.ExecContext(ctx,
+			commit.Id,
+			trailer.Kind,
+			trailer.Key,
+			trailer.Value,
+			trailer.Person.Name,
+			trailer.Person.Email)
+
+		if err != nil {
+			return fmt.Errorf("adding trailer of commit %s: %w", commit.Id, err)
+		}
+	}
+
+	return nil
+}
+
+func (cw *commitWriter) addParents(ctx context.Context, commit *common.Commit) error {
+	_, err := cw.deleteParentsStmt.ExecContext(ctx, commit.Id)
+	if err != nil {
+		return fmt.Errorf("clearing parents of commit %s: %w", commit.Id, err)
+	}
+
+	for i, parentId := range commit.ParentIds {
+		_, err := cw.insertParentStmt.ExecContext(ctx, commit.Id, parentId, i)
+		if err != nil {
+			return fmt.Errorf("adding parent of commit %s: %w", commit.Id, err)
+		}
+	}
+
+	return nil
+}
+
+// Runs a prepared statement wherever an execer is expected, ignoring the query
+type execStmt struct {
+	stmt *sql.Stmt
+}
+
+func (es execStmt) ExecContext(ctx context.Context, _ string, args ...any) (sql.Result, error) {
+	return es.stmt.ExecContext(ctx, args...)
+}
+
+func addRepoCommit(ctx context.Context, db execer, repoName string, commitId string) (bool, error) {
+	if repoName == "" {
+		return false, nil
+	}
+
+	result, err := db.ExecContext(ctx, insertRepoCommitStmt, repoName, commitId)
+	if err != nil {
+		return false, fmt.Errorf("adding commit %s to repository %s: %w", commitId, repoName, err)
+	}
+
+	numAffected, err := result.RowsAffected()
+	return numAffected > 0, err
+}
+
+func setIngestedRefTips(ctx context.Context, db execer, repoName string, tips map[string]string) error {
+	_, err := db.ExecContext(ctx, "DELETE FROM ingested_refs WHERE repo_name = ?", repoName)
+	if err != nil {
+		return fmt.Errorf("clearing ingested refs of %s: %w", repoName, err)
+	}
+
+	stmt := "INSERT INTO ingested_refs (repo_name, ref, commit_id) VALUES (?1, ?2, ?3)"
+	for ref, commitId := range tips {
+		_, err := db.ExecContext(ctx, stmt, repoName, ref, commitId)
+		if err != nil {
+			return fmt.Errorf("recording ingested ref %s: %w", ref, err)
+		}
+	}
+
+	return nil
+}
+
+func addRepository(ctx context.Context, db execer, repo *common.Repository) error {
+	if repo == nil {
+		return errors.New("received a nil repository, won't add to db")
+	}
+
+	stmt := `INSERT INTO repos (
+			name,
+			origin_url,
+			local_path,
+			default_branch,
+			first_ingest_time,
+			last_ingest_time
+		) VALUES (?1, ?2, ?3, ?4, ?5, ?6)
+		ON CONFLICT (name) DO UPDATE SET
+			origin_url = excluded.origin_url,
+			local_path = excluded.local_path,
+			default_branch = excluded.default_branch,
+			last_ingest_time = excluded.last_ingest_time`
+
+	_, err := db.ExecContext(ctx, stmt,
+		repo.Name,
+		repo.OriginUrl,
+		repo.LocalPath,
+		repo.DefaultBranch,
+		repo.FirstIngestTime,
+		repo.LastIngestTime)
+
+	if err != nil {
+		return fmt.Errorf("adding repository %s: %w", repo.Name, err)
+	}
+
+	return nil
+}
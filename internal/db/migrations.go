@@ -0,0 +1,456 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/claucambra/commit-analysis-tool/pkg/common"
+)
+
+// Change to the database schema. Migrations are applied in order of version, each in its own
+// transaction, and recorded in the schema_version table. They are the only source of the schema:
+// the commits table is not built from commitfields.Fields, whose columns must match the ones the
+// migrations create. Once released, a migration must not be changed: schema changes, including
+// columns for new fields in commitfields.Fields, need a new migration at the end of migrations.
+type Migration struct {
+	Version     int
+	Description string
+
+	migrate func(ctx context.Context, tx *sql.Tx) error
+}
+
+func execMigration(stmt string) func(ctx context.Context, tx *sql.Tx) error {
+	return func(ctx context.Context, tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, stmt)
+		return err
+	}
+}
+
+// Databases created before schema versions were recorded are treated as being at version 1, so
+// the migrations after it need to cope with tables that already exist
+var migrations = []*Migration{
+	{
+		Version:     1,
+		Description: "create commits table",
+		migrate: execMigration(`CREATE TABLE IF NOT EXISTS commits (
+			id TEXT PRIMARY KEY ON CONFLICT REPLACE,
+			repo_name TEXT NOT NULL,
+			author_name TEXT,
+			author_email TEXT,
+			author_time INT,
+			committer_name TEXT,
+			committer_email TEXT,
+			committer_time INT,
+			num_insertions INT,
+			num_deletions INT,
+			num_files_changed INT,
+			subject TEXT,
+			body TEXT);
+		CREATE INDEX IF NOT EXISTS index_repo_name ON commits (repo_name);
+		CREATE INDEX IF NOT EXISTS index_author_name ON commits (author_name);
+		CREATE INDEX IF NOT EXISTS index_author_email ON commits (author_email);
+		CREATE INDEX IF NOT EXISTS index_author_time ON commits (author_time);
+		CREATE INDEX IF NOT EXISTS index_committer_name ON commits (committer_name);
+		CREATE INDEX IF NOT EXISTS index_committer_email ON commits (committer_email);
+		CREATE INDEX IF NOT EXISTS index_committer_time ON commits (committer_time);
+		CREATE INDEX IF NOT EXISTS index_num_insertions ON commits (num_insertions);
+		CREATE INDEX IF NOT EXISTS index_num_deletions ON commits (num_deletions);
+		CREATE INDEX IF NOT EXISTS index_num_files_changed ON commits (num_files_changed);
+		CREATE INDEX IF NOT EXISTS index_subject ON commits (subject);
+		CREATE INDEX IF NOT EXISTS index_body ON commits (body);`),
+	},
+	{
+		Version:     2,
+		Description: "create commit_files table",
+		migrate: execMigration(`CREATE TABLE IF NOT EXISTS commit_files (
+			commit_id TEXT NOT NULL,
+			path TEXT NOT NULL,
+			previous_path TEXT,
+			extension TEXT,
+			num_insertions INT,
+			num_deletions INT,
+			binary INT);
+		CREATE INDEX IF NOT EXISTS index_commit_files_commit_id ON commit_files (commit_id);
+		CREATE INDEX IF NOT EXISTS index_commit_files_path ON commit_files (path);
+		CREATE INDEX IF NOT EXISTS index_commit_files_extension ON commit_files (extension);`),
+	},
+	{
+		Version:     3,
+		Description: "create repos and ingested_refs tables",
+		migrate: execMigration(`CREATE TABLE IF NOT EXISTS repos (
+			name TEXT PRIMARY KEY,
+			origin_url TEXT,
+			local_path TEXT,
+			default_branch TEXT,
+			first_ingest_time INT,
+			last_ingest_time INT);
+		CREATE TABLE IF NOT EXISTS ingested_refs (
+			repo_name TEXT NOT NULL,
+			ref TEXT NOT NULL,
+			commit_id TEXT NOT NULL,
+			PRIMARY KEY (repo_name, ref) ON CONFLICT REPLACE);`),
+	},
+	{
+		Version:     4,
+		Description: "move repository names of commits to repo_commits table",
+		migrate:     migrateRepoCommits,
+	},
+	{
+		Version:     5,
+		Description: "create commit_trailers table",
+		migrate: execMigration(`CREATE TABLE IF NOT EXISTS commit_trailers (
+			commit_id TEXT NOT NULL,
+			kind TEXT NOT NULL,
+			key TEXT NOT NULL,
+			value TEXT,
+			person_name TEXT,
+			person_email TEXT);
+		CREATE INDEX IF NOT EXISTS index_commit_trailers_commit_id ON commit_trailers (commit_id);
+		CREATE INDEX IF NOT EXISTS index_commit_trailers_kind ON commit_trailers (kind);
+		CREATE INDEX IF NOT EXISTS index_commit_trailers_person_email ON commit_trailers (person_email);`),
+	},
+	{
+		Version:     6,
+		Description: "create commit_parents table",
+		migrate: execMigration(`CREATE TABLE IF NOT EXISTS commit_parents (
+			commit_id TEXT NOT NULL,
+			parent_id TEXT NOT NULL,
+			parent_index INT NOT NULL,
+			PRIMARY KEY (commit_id, parent_index) ON CONFLICT REPLACE);
+		CREATE INDEX IF NOT EXISTS index_commit_parents_parent_id ON commit_parents (parent_id);`),
+	},
+	{
+		Version:     7,
+		Description: "add author_domain and committer_domain columns to commits",
+		migrate:     migrateCommitDomains,
+	},
+	{
+		Version:     8,
+		Description: "replace subject and body indexes with a full-text search index",
+		migrate:     migrateSearchIndex,
+	},
+	{
+		Version:     9,
+		Description: "name repositories after the host and path of their origin url",
+		migrate:     migrateRepoNames,
+	},
+}
+
+// Commits used to name the one repository they were ingested from in a repo_name column. A commit
+// can be part of several repositories, which repo_commits records.
+func migrateRepoCommits(ctx context.Context, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS repo_commits (
+			repo_name TEXT NOT NULL,
+			commit_id TEXT NOT NULL,
+			PRIMARY KEY (repo_name, commit_id) ON CONFLICT IGNORE);
+		CREATE INDEX IF NOT EXISTS index_repo_commits_commit_id ON repo_commits (commit_id);`)
+	if err != nil {
+		return err
+	}
+
+	hasRepoName, err := columnExists(ctx, tx, "commits", "repo_name")
+	if err != nil || !hasRepoName {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `INSERT INTO repo_commits (repo_name, commit_id)
+			SELECT repo_name, id FROM commits WHERE repo_name != '' ORDER BY rowid;
+		DROP INDEX IF EXISTS index_repo_name;
+		ALTER TABLE commits DROP COLUMN repo_name;`)
+	return err
+}
+
+// Domains are stored lower-cased so domain queries can match them exactly, using an index
+func migrateCommitDomains(ctx context.Context, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, `ALTER TABLE commits ADD COLUMN author_domain TEXT NOT NULL DEFAULT '';
+		ALTER TABLE commits ADD COLUMN committer_domain TEXT NOT NULL DEFAULT '';`)
+	if err != nil {
+		return err
+	}
+
+	for _, column := range []string{"author", "committer"} {
+		rows, err := tx.QueryContext(ctx, "SELECT DISTINCT "+column+"_email FROM commits")
+		if err != nil {
+			return err
+		}
+
+		emails := []string{}
+		for rows.Next() {
+			var email string
+			if err := rows.Scan(&email); err != nil {
+				rows.Close()
+				return err
+			}
+
+			emails = append(emails, email)
+		}
+
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		stmt := "UPDATE commits SET " + column + "_domain = ? WHERE " + column + "_email = ?"
+		for _, email := range emails {
+			if _, err := tx.ExecContext(ctx, stmt, common.EmailDomain(email), email); err != nil {
+				return err
+			}
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, `CREATE INDEX IF NOT EXISTS index_commits_author_domain ON commits (author_domain);
+		CREATE INDEX IF NOT EXISTS index_commits_committer_domain ON commits (committer_domain);`)
+	return err
+}
+
+// B-tree indexes do not help with searching for words within subjects and bodies
+func migrateSearchIndex(ctx context.Context, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, `DROP INDEX IF EXISTS index_subject;
+		DROP INDEX IF EXISTS index_body;`)
+	if err != nil {
+		return err
+	}
+
+	return createSearchIndex(ctx, tx)
+}
+
+// Repositories used to be named after the last component of their origin url, so repositories of
+// the same name on different hosts or of different owners were merged into one. Repositories
+// whose new name is already taken, e.g. clones of the same origin ingested under different names,
+// are merged into the repository of that name.
+func migrateRepoNames(ctx context.Context, tx *sql.Tx) error {
+	rows, err := tx.QueryContext(ctx, "SELECT name, origin_url FROM repos WHERE origin_url != ''")
+	if err != nil {
+		return err
+	}
+
+	renames := map[string]string{}
+	for rows.Next() {
+		var name, originUrl string
+		if err := rows.Scan(&name, &originUrl); err != nil {
+			rows.Close()
+			return err
+		}
+
+		if newName := common.RepoNameFromUrl(originUrl); newName != "" && newName != name {
+			renames[name] = newName
+		}
+	}
+
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, name := range common.SortedMapKeys(renames) {
+		if err := renameRepo(ctx, tx, name, renames[name]); err != nil {
+			return fmt.Errorf("renaming repository %s to %s: %w", name, renames[name], err)
+		}
+	}
+
+	return nil
+}
+
+// Moves the commits and ingested refs of a repository to the new name, merging them with those of
+// a repository already named so. Refs ingested under both names keep the new name's tips, which at
+// worst makes the next ingest read commits that are already in the database again.
+func renameRepo(ctx context.Context, tx *sql.Tx, name string, newName string) error {
+	rows, err := tx.QueryContext(ctx, "SELECT 1 FROM repos WHERE name = ?", newName)
+	if err != nil {
+		return err
+	}
+
+	newNameTaken := rows.Next()
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	// Inserted under the new name and deleted, as updating the name would leave rows that are
+	// already there under the new name behind
+	movedTables := []struct{ name, columns string }{
+		{"repo_commits", "commit_id"},
+		{"ingested_refs", "ref, commit_id"},
+	}
+
+	for _, table := range movedTables {
+		stmt := "INSERT OR IGNORE INTO " + table.name + " (repo_name, " + table.columns + ") SELECT ?, " + table.columns +
+			" FROM " + table.name + " WHERE repo_name = ?"
+		if _, err := tx.ExecContext(ctx, stmt, newName, name); err != nil {
+			return err
+		} else if _, err := tx.ExecContext(ctx, "DELETE FROM "+table.name+" WHERE repo_name = ?", name); err != nil {
+			return err
+		}
+	}
+
+	if !newNameTaken {
+		_, err = tx.ExecContext(ctx, "UPDATE repos SET name = ? WHERE name = ?", newName, name)
+		return err
+	}
+
+	log.Printf("Merging repository %s into %s, which has the same origin url", name, newName)
+
+	// The merged repository was first ingested when either was, and last ingested when either was
+	_, err = tx.ExecContext(ctx, `UPDATE repos SET
+			first_ingest_time = min(first_ingest_time, (SELECT first_ingest_time FROM repos WHERE name = ?2)),
+			last_ingest_time = max(last_ingest_time, (SELECT last_ingest_time FROM repos WHERE name = ?2))
+			WHERE name = ?1`, newName, name)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, "DELETE FROM repos WHERE name = ?", name)
+	return err
+}
+
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+func columnExists(ctx context.Context, db queryer, table string, column string) (bool, error) {
+	rows, err := db.QueryContext(ctx, "SELECT name FROM pragma_table_info(?)", table)
+	if err != nil {
+		return false, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return false, err
+		} else if name == column {
+			return true, nil
+		}
+	}
+
+	return false, rows.Err()
+}
+
+func tableExists(ctx context.Context, db queryer, table string) (bool, error) {
+	rows, err := db.QueryContext(ctx, "SELECT 1 FROM sqlite_master WHERE type = 'table' AND name = ?", table)
+	if err != nil {
+		return false, err
+	}
+
+	defer rows.Close()
+	return rows.Next(), rows.Err()
+}
+
+// Latest schema version, which Migrate brings databases to
+func LatestSchemaVersion() int {
+	return migrations[len(migrations)-1].Version
+}
+
+// Version of the database's schema, 0 for an empty database. Databases created before versions
+// were recorded are at version 1.
+func (sqlb *SQLiteBackend) SchemaVersion(ctx context.Context) (int, error) {
+	hasVersions, err := tableExists(ctx, sqlb.Db, "schema_version")
+	if err != nil {
+		return 0, err
+	} else if hasVersions {
+		version := 0
+		err := sqlb.Db.QueryRowContext(ctx, "SELECT COALESCE(MAX(version), 0) FROM schema_version").Scan(&version)
+		if err != nil {
+			return 0, fmt.Errorf("reading schema version: %w", err)
+		}
+
+		return version, nil
+	}
+
+	hasCommits, err := tableExists(ctx, sqlb.Db, "commits")
+	if err != nil || !hasCommits {
+		return 0, err
+	}
+
+	return 1, nil
+}
+
+// Migrations that Migrate would apply, in order
+func (sqlb *SQLiteBackend) PendingMigrations(ctx context.Context) ([]*Migration, error) {
+	version, err := sqlb.SchemaVersion(ctx)
+	if err != nil {
+		return nil, err
+	} else if version > LatestSchemaVersion() {
+		return nil, fmt.Errorf("database schema version %d is newer than the latest known version %d", version, LatestSchemaVersion())
+	}
+
+	pending := []*Migration{}
+	for _, migration := range migrations {
+		if migration.Version > version {
+			pending = append(pending, migration)
+		}
+	}
+
+	return pending, nil
+}
+
+// Applies the pending migrations, returning the ones that were applied
+func (sqlb *SQLiteBackend) Migrate(ctx context.Context) ([]*Migration, error) {
+	version, err := sqlb.SchemaVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	pending, err := sqlb.PendingMigrations(ctx)
+	if err != nil || len(pending) == 0 {
+		return nil, err
+	}
+
+	_, err = sqlb.Db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_version (
+			version INT PRIMARY KEY,
+			description TEXT,
+			applied_time INT)`)
+	if err != nil {
+		return nil, fmt.Errorf("creating schema_version table: %w", err)
+	}
+
+	// Record the version of databases created before versions were recorded
+	if version > 0 {
+		_, err := sqlb.Db.ExecContext(ctx, "INSERT OR IGNORE INTO schema_version VALUES (?, ?, ?)",
+			version, "detected existing database", time.Now().Unix())
+		if err != nil {
+			return nil, fmt.Errorf("recording existing schema version: %w", err)
+		}
+	}
+
+	applied := []*Migration{}
+	for _, migration := range pending {
+		if err := sqlb.applyMigration(ctx, migration); err != nil {
+			return applied, fmt.Errorf("migration to schema version %d (%s) failed: %w", migration.Version, migration.Description, err)
+		}
+
+		// New databases go through every migration, which is not worth mentioning
+		if version > 0 {
+			log.Printf("Migrated database to schema version %d: %s", migration.Version, migration.Description)
+		}
+
+		applied = append(applied, migration)
+	}
+
+	return applied, nil
+}
+
+func (sqlb *SQLiteBackend) applyMigration(ctx context.Context, migration *Migration) error {
+	tx, err := sqlb.Db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	if err := migration.migrate(ctx, tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, "INSERT INTO schema_version VALUES (?, ?, ?)",
+		migration.Version, migration.Description, time.Now().Unix())
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
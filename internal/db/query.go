@@ -0,0 +1,249 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/claucambra/commit-analysis-tool/pkg/common"
+)
+
+// Expressions commit orders sort by. Commits with equal values are ordered by rowid, which is also
+// what pages continue from.
+var commitOrderExpressions = map[common.CommitOrder]string{
+	common.OrderByIngest:        "commits.rowid",
+	common.OrderByAuthorTime:    "commits.author_time",
+	common.OrderByCommitterTime: "commits.committer_time",
+	common.OrderByLineChanges:   "(commits.num_insertions + commits.num_deletions)",
+}
+
+// Condition matching the commits that pass the query's filters other than the repository and the
+// text
+func commitQueryCondition(query *common.CommitQuery) (string, []any) {
+	conditions := []string{}
+	args := []any{}
+
+	addCondition := func(condition string, conditionArgs ...any) {
+		conditions = append(conditions, "("+condition+")")
+		args = append(args, conditionArgs...)
+	}
+
+	if query.AuthorEmail != "" {
+		addCondition("commits.author_email = ?", query.AuthorEmail)
+	}
+
+	if query.CommitterEmail != "" {
+		addCondition("commits.committer_email = ?", query.CommitterEmail)
+	}
+
+	if query.AuthorDomain != "" {
+		domainCondition, domainArgs := DomainCondition("author_domain", query.AuthorDomain, query.IncludeSubdomains)
+		addCondition(domainCondition, domainArgs...)
+	}
+
+	if query.CommitterDomain != "" {
+		domainCondition, domainArgs := DomainCondition("committer_domain", query.CommitterDomain, query.IncludeSubdomains)
+		addCondition(domainCondition, domainArgs...)
+	}
+
+	if !query.Since.IsZero() {
+		addCondition("commits.author_time >= ?", query.Since.Unix())
+	}
+
+	if !query.Until.IsZero() {
+		addCondition("commits.author_time <= ?", query.Until.Unix())
+	}
+
+	if query.MinLineChanges != nil {
+		addCondition("commits.num_insertions + commits.num_deletions >= ?", *query.MinLineChanges)
+	}
+
+	if query.MaxLineChanges != nil {
+		addCondition("commits.num_insertions + commits.num_deletions <= ?", *query.MaxLineChanges)
+	}
+
+	return strings.Join(conditions, " AND "), args
+}
+
+// Condition matching the commits whose subject or body match the full-text query. Matches are
+// looked up again for every page, so in ingest order the lookup starts after the previous page.
+func textCondition(text string, afterRowid int64, comparison string) (string, []any) {
+	if afterRowid == 0 {
+		return "commits.rowid IN (SELECT rowid FROM " + searchTable + " WHERE " + searchTable + " MATCH ?)", []any{text}
+	}
+
+	return "commits.rowid IN (SELECT rowid FROM " + searchTable + " WHERE " + searchTable + " MATCH ? AND rowid " + comparison + " ?)", []any{text, afterRowid}
+}
+
+// Commits matching the query, read a page at a time. Each page is read with its own statement
+// continuing after the last commit of the previous page, so commits added or replaced while
+// iterating may be skipped or returned twice. Pages are read with the context, so iterating stops
+// with its error once it is cancelled.
+func (sqlb *SQLiteBackend) QueryCommits(ctx context.Context, query *common.CommitQuery) (common.CommitIterator, error) {
+	if query.Text != "" {
+		if err := searchIndexUsable(ctx, sqlb.Db); err != nil {
+			return nil, err
+		}
+	}
+
+	condition, args := commitQueryCondition(query)
+	queryCopy := *query
+
+	return &commitPager{
+		ctx:       ctx,
+		sqlb:      sqlb,
+		query:     &queryCopy,
+		condition: condition,
+		args:      args,
+		remaining: query.Limit,
+		index:     -1,
+	}, nil
+}
+
+// CommitIterator reading the commits of a query a page at a time
+type commitPager struct {
+	ctx       context.Context
+	sqlb      *SQLiteBackend
+	query     *common.CommitQuery
+	condition string
+	args      []any
+
+	page  []*common.Commit
+	index int
+	// Order key and rowid of the last commit read, which the next page continues after
+	lastKey   int64
+	lastRowid int64
+	// Commits left to read before reaching the limit, if the query has one
+	remaining int
+	started   bool
+	done      bool
+	err       error
+}
+
+func (pager *commitPager) Next() bool {
+	if pager.index+1 < len(pager.page) {
+		pager.index++
+		return true
+	} else if pager.done || pager.err != nil {
+		pager.page = nil
+		return false
+	}
+
+	if err := pager.readPage(); err != nil {
+		pager.err = fmt.Errorf("reading page of queried commits: %w", err)
+		pager.page = nil
+		return false
+	}
+
+	pager.index = 0
+	return len(pager.page) > 0
+}
+
+func (pager *commitPager) Commit() *common.Commit {
+	if pager.index < 0 || pager.index >= len(pager.page) {
+		return nil
+	}
+
+	return pager.page[pager.index]
+}
+
+func (pager *commitPager) Err() error {
+	return pager.err
+}
+
+func (pager *commitPager) readPage() error {
+	pageSize := pager.query.PageSizeOrDefault()
+	if pager.query.Limit > 0 && pager.remaining < pageSize {
+		pageSize = pager.remaining
+	}
+
+	orderExpression := commitOrderExpressions[pager.query.Order]
+	if orderExpression == "" {
+		orderExpression = commitOrderExpressions[common.OrderByIngest]
+	}
+
+	direction, comparison := "ASC", ">"
+	if pager.query.Descending {
+		direction, comparison = "DESC", "<"
+	}
+
+	conditions := []string{}
+	args := append([]any{}, pager.args...)
+
+	if pager.condition != "" {
+		conditions = append(conditions, pager.condition)
+	}
+
+	if pager.query.Text != "" {
+		var afterRowid int64
+		if pager.started && pager.query.Order == common.OrderByIngest {
+			afterRowid = pager.lastRowid
+		}
+
+		condition, conditionArgs := textCondition(pager.query.Text, afterRowid, comparison)
+		conditions = append(conditions, condition)
+		args = append(args, conditionArgs...)
+	}
+
+	if pager.started {
+		pageCondition := "(" + orderExpression + ", commits.rowid) " + comparison + " (?, ?)"
+		pageArgs := []any{pager.lastKey, pager.lastRowid}
+
+		if pager.query.Order == common.OrderByIngest {
+			pageCondition = "commits.rowid " + comparison + " ?"
+			pageArgs = []any{pager.lastRowid}
+		}
+
+		conditions = append(conditions, pageCondition)
+		args = append(args, pageArgs...)
+	}
+
+	stmt, stmtArgs := commitsSelect(pager.query.RepoName, "commits.rowid", strings.Join(conditions, " AND "), args...)
+	stmt += " ORDER BY " + orderExpression + " " + direction + ", commits.rowid " + direction + " LIMIT ?"
+	stmtArgs = append(stmtArgs, pageSize)
+
+	if !pager.started && pager.query.Offset > 0 {
+		stmt += " OFFSET ?"
+		stmtArgs = append(stmtArgs, pager.query.Offset)
+	}
+
+	rows, err := pager.sqlb.Db.QueryContext(pager.ctx, stmt, stmtArgs...)
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	page := []*common.Commit{}
+	for rows.Next() {
+		commit := new(common.Commit)
+		if err := rows.Scan(append(commitScanPointers(commit), &pager.lastRowid)...); err != nil {
+			return err
+		}
+
+		pager.lastKey = pager.query.Order.Key(commit)
+		page = append(page, commit)
+	}
+
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	pager.started = true
+	pager.page = page
+	pager.remaining -= len(page)
+	pager.done = len(page) < pageSize || (pager.query.Limit > 0 && pager.remaining <= 0)
+
+	if !pager.query.WithDetails || len(page) == 0 {
+		return nil
+	}
+
+	commitIds := []any{}
+	for _, commit := range page {
+		commitIds = append(commitIds, commit.Id)
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(commitIds)), ", ")
+	return pager.sqlb.attachCommitDetails(pager.ctx, page, placeholders, commitIds...)
+}
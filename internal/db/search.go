@@ -0,0 +1,157 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+
+	"github.com/claucambra/commit-analysis-tool/pkg/common"
+)
+
+// Full-text search modules the search index can use. go-sqlite3 only includes FTS5 when built with
+// the sqlite_fts5 tag, so FTS4 is used otherwise. Builds without the tag refuse to ingest into or
+// search a database whose index uses FTS5, rather than leave the index missing their commits.
+const (
+	FTS5SearchModule = "fts5"
+	FTS4SearchModule = "fts4"
+)
+
+// Virtual table indexing commit subjects and bodies, sharing its rowids with the commits table
+const searchTable = "commits_fts"
+
+// SQLite cannot use, or even drop, FTS5 tables without the FTS5 module
+var errFTS5Unavailable = errors.New("search index uses FTS5, which this build lacks: build with -tags sqlite_fts5")
+
+var searchModuleRegex = regexp.MustCompile(`(?i)USING\s+(fts[345])`)
+
+// Best full-text search module this build of SQLite supports
+func availableSearchModule(ctx context.Context, db queryer) (string, error) {
+	rows, err := db.QueryContext(ctx, "SELECT sqlite_compileoption_used('ENABLE_FTS5')")
+	if err != nil {
+		return "", err
+	}
+
+	defer rows.Close()
+
+	hasFTS5 := false
+	if rows.Next() {
+		if err := rows.Scan(&hasFTS5); err != nil {
+			return "", err
+		}
+	}
+
+	if hasFTS5 {
+		return FTS5SearchModule, rows.Err()
+	}
+
+	return FTS4SearchModule, rows.Err()
+}
+
+// Module the search index was created with, empty if there is none
+func searchIndexModule(ctx context.Context, db queryer) (string, error) {
+	rows, err := db.QueryContext(ctx, "SELECT sql FROM sqlite_master WHERE type = 'table' AND name = ?", searchTable)
+	if err != nil {
+		return "", err
+	}
+
+	defer rows.Close()
+
+	if !rows.Next() {
+		return "", rows.Err()
+	}
+
+	var stmt string
+	if err := rows.Scan(&stmt); err != nil {
+		return "", err
+	}
+
+	if match := searchModuleRegex.FindStringSubmatch(stmt); match != nil {
+		return match[1], nil
+	}
+
+	return "", fmt.Errorf("unexpected search index definition: %s", stmt)
+}
+
+// Error if the search index cannot be used by this build, e.g. when it was created with FTS5 and
+// this build lacks it
+func searchIndexUsable(ctx context.Context, db queryer) error {
+	module, err := searchIndexModule(ctx, db)
+	if err != nil {
+		return err
+	} else if module == "" {
+		return errors.New("database has no search index, migrate it first")
+	}
+
+	availableModule, err := availableSearchModule(ctx, db)
+	if err != nil {
+		return err
+	} else if module == FTS5SearchModule && availableModule != FTS5SearchModule {
+		return errFTS5Unavailable
+	}
+
+	return nil
+}
+
+// Creates the search index with the best available module and indexes the existing commits
+func createSearchIndex(ctx context.Context, tx *sql.Tx) error {
+	module, err := availableSearchModule(ctx, tx)
+	if err != nil {
+		return err
+	} else if module != FTS5SearchModule {
+		log.Printf("WARNING: Creating the search index with %s, build with -tags sqlite_fts5 to use FTS5", module)
+	}
+
+	_, err = tx.ExecContext(ctx, "CREATE VIRTUAL TABLE "+searchTable+" USING "+module+"(subject, body)")
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, "INSERT INTO "+searchTable+" (rowid, subject, body) SELECT rowid, subject, body FROM commits")
+	return err
+}
+
+// Module of the search index, empty if there is none
+func (sqlb *SQLiteBackend) SearchModule(ctx context.Context) (string, error) {
+	return searchIndexModule(ctx, sqlb.Db)
+}
+
+// Recreates the search index with the best module this build supports, e.g. to switch to FTS5 after
+// building with the sqlite_fts5 tag
+func (sqlb *SQLiteBackend) RebuildSearchIndex(ctx context.Context) error {
+	if err := searchIndexUsable(ctx, sqlb.Db); errors.Is(err, errFTS5Unavailable) {
+		return err
+	}
+
+	tx, err := sqlb.Db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("beginning search index rebuild: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, "DROP TABLE IF EXISTS "+searchTable)
+	if err == nil {
+		err = createSearchIndex(ctx, tx)
+	}
+
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("rebuilding search index: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// Commits whose subject or body match the full-text query, without their file changes, trailers
+// or parents. Queries use SQLite's full-text query syntax: terms, "quoted phrases", prefix* terms,
+// AND, OR and NOT, parentheses, and subject: or body: to search a single column. FTS5 requires an
+// explicit AND next to parentheses, and FTS4 only applies column filters to single terms.
+func (sqlb *SQLiteBackend) SearchCommits(ctx context.Context, query string, repoName string) ([]*common.Commit, error) {
+	commits, err := sqlb.QueryCommits(ctx, common.NewCommitQuery().WithRepo(repoName).WithText(query))
+	if err != nil {
+		return nil, err
+	}
+
+	return common.CollectCommits(commits)
+}
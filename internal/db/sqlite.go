@@ -1,10 +1,13 @@
 package db
 
 import (
+	"context"
 	"database/sql"
 	"errors"
-	"log"
+	"fmt"
+	"strings"
 
+	"github.com/claucambra/commit-analysis-tool/internal/commitfields"
 	"github.com/claucambra/commit-analysis-tool/pkg/common"
 	_ "github.com/mattn/go-sqlite3"
 )
@@ -17,246 +20,683 @@ func (sqlb *SQLiteBackend) DB() *sql.DB {
 	return sqlb.Db
 }
 
-func (sqlb *SQLiteBackend) Open(path string) error {
-	db, err := sql.Open("sqlite3", path)
+// Opens the database at the path, creating it if needed, and migrates it to the latest schema
+func (sqlb *SQLiteBackend) Open(ctx context.Context, path string) error {
+	err := sqlb.OpenUnmigrated(path)
 	if err != nil {
-		log.Fatalf("Could not open sqlite database: %s", err)
 		return err
 	}
 
+	if _, err = sqlb.Migrate(ctx); err != nil {
+		sqlb.Db.Close()
+		return fmt.Errorf("migrating sqlite database: %w", err)
+	}
+
+	return nil
+}
+
+// Opens the database at the path without migrating it, e.g. to inspect pending migrations
+func (sqlb *SQLiteBackend) OpenUnmigrated(path string) error {
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return fmt.Errorf("opening sqlite database: %w", err)
+	}
+
 	sqlb.Db = db
 
-	return err
+	return nil
 }
 
 func (sqlb *SQLiteBackend) Close() error {
-	err := sqlb.Db.Close()
-	if err != nil {
-		log.Fatalf("Could not close sqlite database: %s", err)
-	}
-
-	return err
-}
-
-func (sqlb *SQLiteBackend) Setup() error {
-	stmt := `CREATE TABLE IF NOT EXISTS commits (
-			id TEXT PRIMARY KEY ON CONFLICT REPLACE,
-			repo_name TEXT NOT NULL,
-			author_name TEXT,
-			author_email TEXT,
-			author_time INT,
-			committer_name TEXT,
-			committer_email TEXT,
-			committer_time INT,
-			num_insertions INT,
-			num_deletions INT,
-			num_files_changed INT,
-			subject TEXT,
-			body TEXT);
-		CREATE INDEX IF NOT EXISTS index_repo_name ON commits (repo_name);
-		CREATE INDEX IF NOT EXISTS index_author_name ON commits (author_name);
-		CREATE INDEX IF NOT EXISTS index_author_email ON commits (author_email);
-		CREATE INDEX IF NOT EXISTS index_author_time ON commits (author_time);
-		CREATE INDEX IF NOT EXISTS index_committer_name ON commits (committer_name);
-		CREATE INDEX IF NOT EXISTS index_committer_email ON commits (committer_email);
-		CREATE INDEX IF NOT EXISTS index_committer_time ON commits (committer_time);
-		CREATE INDEX IF NOT EXISTS index_num_insertions ON commits (num_insertions);
-		CREATE INDEX IF NOT EXISTS index_num_deletions ON commits (num_deletions);
-		CREATE INDEX IF NOT EXISTS index_num_files_changed ON commits (num_files_changed);
-		CREATE INDEX IF NOT EXISTS index_subject ON commits (subject);
-		CREATE INDEX IF NOT EXISTS index_body ON commits (body);`
-
-	_, err := sqlb.Db.Exec(stmt)
-	if err != nil {
-		log.Fatalf("Setup failed, received error during table creation: %s", err)
-		return err
+	if err := sqlb.Db.Close(); err != nil {
+		return fmt.Errorf("closing sqlite database: %w", err)
 	}
 
 	return nil
 }
 
-func (sqlb *SQLiteBackend) AddCommit(commit *common.Commit) error {
-	if commit == nil {
-		return errors.New("received a nil commit, won't add to db")
-	}
-
-	stmt := `INSERT INTO commits (
-			id,
-			repo_name,
-			author_name,
-			author_email,
-			author_time,
-			committer_name,
-			committer_email,
-			committer_time,
-			num_insertions,
-			num_deletions,
-			num_files_changed,
-			subject,
-			body
-		) VALUES (?1, ?2, ?3, ?4, ?5, ?6, ?7, ?8, ?9, ?10, ?11, ?12, ?13)`
-
-	_, err := sqlb.Db.Exec(stmt,
-		commit.Id,
-		commit.RepoName,
-		commit.Author.Name,
-		commit.Author.Email,
-		commit.AuthorTime,
-		commit.Committer.Name,
-		commit.Committer.Email,
-		commit.CommitterTime,
-		commit.NumInsertions,
-		commit.NumDeletions,
-		commit.NumFilesChanged,
-		commit.Subject,
-		commit.Body)
-
-	if err != nil {
-		log.Printf("Encountered error adding commit: %s", err)
-		return err
+// Brings the schema up to date. Open already does so, this is kept for databases opened with
+// OpenUnmigrated.
+func (sqlb *SQLiteBackend) Setup(ctx context.Context) error {
+	if _, err := sqlb.Migrate(ctx); err != nil {
+		return fmt.Errorf("setting up sqlite database: %w", err)
 	}
 
 	return nil
 }
 
-func (sqlb *SQLiteBackend) AddCommits(commits []*common.Commit) error {
-	for _, commit := range commits {
-		err := sqlb.AddCommit(commit)
+// Adds or replaces a commit in its own transaction
+func (sqlb *SQLiteBackend) AddCommit(ctx context.Context, commit *common.Commit) error {
+	return sqlb.AddCommits(ctx, []*common.Commit{commit})
+}
 
-		if err != nil {
-			log.Fatalf("Error adding commit: %s", err)
+// Records that a commit is part of a repository's history. A commit can be part of several
+// repositories, e.g. forks or mirrors. Returns false if the membership was already recorded.
+func (sqlb *SQLiteBackend) AddRepoCommit(ctx context.Context, repoName string, commitId string) (bool, error) {
+	return addRepoCommit(ctx, sqlb.Db, repoName, commitId)
+}
+
+// Adds or replaces the commits in one transaction, so either all or none of them are added
+func (sqlb *SQLiteBackend) AddCommits(ctx context.Context, commits []*common.Commit) error {
+	ingest, err := sqlb.BeginIngest(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, commit := range commits {
+		if err := ingest.writer.addCommit(ctx, commit); err != nil {
+			ingest.Rollback()
 			return err
 		}
 	}
 
-	return nil
+	return ingest.Commit()
 }
 
-func (sqlb *SQLiteBackend) ScanRowInRowsToCommits(rows *sql.Rows) *common.Commit {
-	commit := new(common.Commit)
+// Adds commits as they are produced by the iterator, so memory use does not grow with the number
+// of commits. Commits already in the database are not added again, but are recorded as part of the
+// commit's repository if they were ingested from another one. Returns the number of commits added
+// to the repository. The commits are added in one transaction, see Ingest.
+func (sqlb *SQLiteBackend) IngestCommits(ctx context.Context, commits common.CommitIterator) (int, error) {
+	ingest, err := sqlb.BeginIngest(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	numAdded, err := ingest.IngestCommits(ctx, commits, nil)
+	if err != nil {
+		ingest.Rollback()
+		return 0, err
+	}
 
-	rows.Scan(
-		&commit.Id,
-		&commit.RepoName,
-		&commit.Author.Name,
-		&commit.Author.Email,
-		&commit.AuthorTime,
-		&commit.Committer.Name,
-		&commit.Committer.Email,
-		&commit.CommitterTime,
-		&commit.NumInsertions,
-		&commit.NumDeletions,
-		&commit.NumFilesChanged,
-		&commit.Subject,
-		&commit.Body,
+	return numAdded, ingest.Commit()
+}
+
+func (sqlb *SQLiteBackend) HasCommit(ctx context.Context, commitId string) (bool, error) {
+	var exists bool
+	err := sqlb.Db.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM commits WHERE id = ?)", commitId).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("checking for commit %s: %w", commitId, err)
+	}
+
+	return exists, nil
+}
+
+// Ref tips (ref name to commit hash) of a repository as of its last completed ingest
+func (sqlb *SQLiteBackend) IngestedRefTips(ctx context.Context, repoName string) (map[string]string, error) {
+	rows, err := sqlb.Db.QueryContext(ctx, "SELECT ref, commit_id FROM ingested_refs WHERE repo_name = ?", repoName)
+	if err != nil {
+		return nil, fmt.Errorf("retrieving ingested refs of %s: %w", repoName, err)
+	}
+
+	defer rows.Close()
+
+	tips := map[string]string{}
+	for rows.Next() {
+		var ref, commitId string
+		if err := rows.Scan(&ref, &commitId); err != nil {
+			return nil, fmt.Errorf("reading ingested ref: %w", err)
+		}
+
+		tips[ref] = commitId
+	}
+
+	return tips, rows.Err()
+}
+
+// Replaces the recorded ref tips of a repository, to be called once an ingest has completed
+func (sqlb *SQLiteBackend) SetIngestedRefTips(ctx context.Context, repoName string, tips map[string]string) error {
+	return setIngestedRefTips(ctx, sqlb.Db, repoName, tips)
+}
+
+// Adds or updates a repository. The first ingest time is only recorded when the repository is new.
+func (sqlb *SQLiteBackend) AddRepository(ctx context.Context, repo *common.Repository) error {
+	return addRepository(ctx, sqlb.Db, repo)
+}
+
+// Columns in the order of scanRowInRowsToRepository
+const repoColumns = "name, origin_url, local_path, default_branch, first_ingest_time, last_ingest_time"
+
+func (sqlb *SQLiteBackend) scanRowInRowsToRepository(rows *sql.Rows) (*common.Repository, error) {
+	repo := new(common.Repository)
+
+	err := rows.Scan(
+		&repo.Name,
+		&repo.OriginUrl,
+		&repo.LocalPath,
+		&repo.DefaultBranch,
+		&repo.FirstIngestTime,
+		&repo.LastIngestTime,
 	)
+	if err != nil {
+		return nil, fmt.Errorf("reading repository: %w", err)
+	}
 
-	return commit
+	return repo, nil
 }
 
-func (sqlb *SQLiteBackend) Commit(commitId string) (*common.Commit, error) {
-	stmt := "SELECT * FROM commits WHERE id = ?"
+func (sqlb *SQLiteBackend) Repository(ctx context.Context, repoName string) (*common.Repository, error) {
+	rows, err := sqlb.Db.QueryContext(ctx, "SELECT "+repoColumns+" FROM repos WHERE name = ?", repoName)
+	if err != nil {
+		return nil, fmt.Errorf("retrieving repository %s: %w", repoName, err)
+	}
+
+	defer rows.Close()
 
-	accStmt, err := sqlb.Db.Prepare(stmt)
+	if !rows.Next() {
+		return nil, rows.Err()
+	}
+
+	return sqlb.scanRowInRowsToRepository(rows)
+}
+
+func (sqlb *SQLiteBackend) Repositories(ctx context.Context) ([]*common.Repository, error) {
+	rows, err := sqlb.Db.QueryContext(ctx, "SELECT "+repoColumns+" FROM repos ORDER BY name")
 	if err != nil {
-		log.Fatalf("Encountered error preparing commit retrieval statement: %s", err)
-		return nil, err
+		return nil, fmt.Errorf("retrieving repositories: %w", err)
+	}
+
+	defer rows.Close()
+
+	repos := []*common.Repository{}
+	for rows.Next() {
+		repo, err := sqlb.scanRowInRowsToRepository(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		repos = append(repos, repo)
+	}
+
+	return repos, rows.Err()
+}
+
+// Condition restricting commits to the named repository, or matching commits of all repositories
+// when repoName is empty
+func RepoCondition(repoName string) (string, []any) {
+	if repoName == "" {
+		return "1 = 1", nil
+	}
+
+	return "commits.id IN (SELECT commit_id FROM repo_commits WHERE repo_name = ?)", []any{repoName}
+}
+
+// Builds a statement selecting commits in the column order of ScanRowInRowsToCommits (the
+// repository name, then the columns of commitfields.Fields), restricted
+// to the named repository (if not empty) and to an optional extra condition. Without a repository
+// a commit shared by several repositories is returned once, with the first repository it was
+// ingested from as its repository name.
+func CommitsSelect(repoName string, condition string, conditionArgs ...any) (string, []any) {
+	stmt, args := commitsSelect(repoName, "", condition, conditionArgs...)
+	return stmt + " ORDER BY commits.rowid", args
+}
+
+// CommitsSelect without ordering, selecting the extra columns (if not empty) after the commit's
+func commitsSelect(repoName string, extraColumns string, condition string, conditionArgs ...any) (string, []any) {
+	repoColumn := `COALESCE((SELECT repo_name FROM repo_commits
+		WHERE commit_id = commits.id ORDER BY rowid LIMIT 1), '')`
+	args := []any{}
+
+	if repoName != "" {
+		repoColumn = "CAST(? AS TEXT)"
+		args = append(args, repoName)
+	}
+
+	repoCondition, repoArgs := RepoCondition(repoName)
+	args = append(args, repoArgs...)
+
+	columns := repoColumn + ", " + strings.Join(commitfields.ReadColumns("commits"), ", ")
+	if extraColumns != "" {
+		columns += ", " + extraColumns
+	}
+
+	stmt := "SELECT " + columns + " FROM commits WHERE " + repoCondition
+
+	if condition != "" {
+		stmt += " AND (" + condition + ")"
+		args = append(args, conditionArgs...)
+	}
+
+	return stmt, args
+}
+
+// Number of commits in the named repository, or in all repositories if repoName is empty.
+// With countPerRepo, commits shared by several repositories are counted once for each of them.
+func (sqlb *SQLiteBackend) CommitCount(ctx context.Context, repoName string, countPerRepo bool) (int, error) {
+	var stmt string
+	var args []any
+
+	if countPerRepo {
+		stmt = "SELECT COUNT(*) FROM repo_commits"
+		if repoName != "" {
+			stmt += " WHERE repo_name = ?"
+			args = append(args, repoName)
+		}
+	} else {
+		repoCondition, repoArgs := RepoCondition(repoName)
+		stmt = "SELECT COUNT(*) FROM commits WHERE " + repoCondition
+		args = repoArgs
+	}
+
+	count := 0
+	err := sqlb.Db.QueryRowContext(ctx, stmt, args...).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("counting commits: %w", err)
+	}
+
+	return count, nil
+}
+
+// Names of the repositories a commit is part of, in the order they were ingested
+func (sqlb *SQLiteBackend) CommitRepos(ctx context.Context, commitId string) ([]string, error) {
+	rows, err := sqlb.Db.QueryContext(ctx, "SELECT repo_name FROM repo_commits WHERE commit_id = ? ORDER BY rowid", commitId)
+	if err != nil {
+		return nil, fmt.Errorf("retrieving repositories of commit %s: %w", commitId, err)
 	}
 
-	defer accStmt.Close()
+	defer rows.Close()
 
+	repoNames := []string{}
+	for rows.Next() {
+		var repoName string
+		if err := rows.Scan(&repoName); err != nil {
+			return nil, fmt.Errorf("reading repository of commit %s: %w", commitId, err)
+		}
+
+		repoNames = append(repoNames, repoName)
+	}
+
+	return repoNames, rows.Err()
+}
+
+func commitScanPointers(commit *common.Commit) []any {
+	return append([]any{&commit.RepoName}, commitfields.ColumnPointers(commit)...)
+}
+
+func (sqlb *SQLiteBackend) ScanRowInRowsToCommits(rows *sql.Rows) (*common.Commit, error) {
 	commit := new(common.Commit)
-	accStmt.QueryRow(commitId).Scan(
-		&commit.Id,
-		&commit.RepoName,
-		&commit.Author.Name,
-		&commit.Author.Email,
-		&commit.AuthorTime,
-		&commit.Committer.Name,
-		&commit.Committer.Email,
-		&commit.CommitterTime,
-		&commit.NumInsertions,
-		&commit.NumDeletions,
-		&commit.NumFilesChanged,
-		&commit.Subject,
-		&commit.Body,
-	)
+	if err := rows.Scan(commitScanPointers(commit)...); err != nil {
+		return nil, fmt.Errorf("reading commit: %w", err)
+	}
 
 	return commit, nil
 }
 
-func (sqlb *SQLiteBackend) Commits() ([]*common.Commit, error) {
-	stmt := "SELECT * FROM commits"
-	accStmt, err := sqlb.Db.Prepare(stmt)
+// Reads all commits of the rows, closing them
+func (sqlb *SQLiteBackend) scanCommits(rows *sql.Rows) ([]*common.Commit, error) {
+	defer rows.Close()
+
+	commits := []*common.Commit{}
+	for rows.Next() {
+		commit, err := sqlb.ScanRowInRowsToCommits(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		commits = append(commits, commit)
+	}
+
+	return commits, rows.Err()
+}
+
+// The commit with its details, or nil if there is no such commit
+func (sqlb *SQLiteBackend) Commit(ctx context.Context, commitId string) (*common.Commit, error) {
+	stmt, args := CommitsSelect("", "commits.id = ?", commitId)
+
+	commit := new(common.Commit)
+	err := sqlb.Db.QueryRowContext(ctx, stmt, args...).Scan(commitScanPointers(commit)...)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("retrieving commit %s: %w", commitId, err)
+	}
+
+	err = sqlb.attachCommitDetails(ctx, []*common.Commit{commit}, "?", commitId)
 	if err != nil {
-		log.Fatalf("Encountered error preparing commits retrieval statement: %s", err)
 		return nil, err
 	}
 
-	defer accStmt.Close()
+	return commit, nil
+}
+
+func (sqlb *SQLiteBackend) Commits(ctx context.Context, repoName string) ([]*common.Commit, error) {
+	return sqlb.collectQueriedCommits(ctx, common.NewCommitQuery().WithRepo(repoName).WithCommitDetails())
+}
 
-	rows, err := accStmt.Query()
+func (sqlb *SQLiteBackend) collectQueriedCommits(ctx context.Context, query *common.CommitQuery) ([]*common.Commit, error) {
+	commits, err := sqlb.QueryCommits(ctx, query)
 	if err != nil {
-		log.Fatalf("Error retrieving rows: %s", err)
 		return nil, err
 	}
 
-	commits := make([]*common.Commit, 0)
+	return common.CollectCommits(commits)
+}
+
+func (sqlb *SQLiteBackend) Authors(ctx context.Context, repoName string) ([]string, error) {
+	repoCondition, repoArgs := RepoCondition(repoName)
+	stmt := "SELECT DISTINCT author_email FROM commits WHERE " + repoCondition
+	rows, err := sqlb.Db.QueryContext(ctx, stmt, repoArgs...)
+	if err != nil {
+		return nil, fmt.Errorf("retrieving authors: %w", err)
+	}
+
+	defer rows.Close()
+
+	authors := []string{}
 	for rows.Next() {
-		commit := sqlb.ScanRowInRowsToCommits(rows)
-		commits = append(commits, commit)
+		var author string
+		if err := rows.Scan(&author); err != nil {
+			return nil, fmt.Errorf("reading author: %w", err)
+		}
+
+		authors = append(authors, author)
 	}
 
-	return commits, nil
+	return authors, rows.Err()
+}
+
+func (sqlb *SQLiteBackend) AuthorCommits(ctx context.Context, authorEmail string, repoName string) ([]*common.Commit, error) {
+	return sqlb.collectQueriedCommits(ctx, common.NewCommitQuery().
+		WithRepo(repoName).
+		WithAuthorEmail(authorEmail).
+		WithCommitDetails())
 }
 
-func (sqlb *SQLiteBackend) Authors() ([]string, error) {
-	stmt := "SELECT DISTINCT author_email FROM commits"
-	accStmt, err := sqlb.Db.Prepare(stmt)
+// Columns in the order of scanRowInRowsToFileChange
+const fileChangeColumns = "commit_id, path, previous_path, extension, num_insertions, num_deletions, binary"
+
+func (sqlb *SQLiteBackend) scanRowInRowsToFileChange(rows *sql.Rows) (string, *common.FileChange, error) {
+	var commitId string
+	var extension string
+	fileChange := new(common.FileChange)
+
+	err := rows.Scan(
+		&commitId,
+		&fileChange.Path,
+		&fileChange.PreviousPath,
+		&extension,
+		&fileChange.NumInsertions,
+		&fileChange.NumDeletions,
+		&fileChange.Binary,
+	)
 	if err != nil {
-		log.Fatalf("Encountered error preparing commits retrieval statement: %s", err)
-		return nil, err
+		return "", nil, fmt.Errorf("reading file change: %w", err)
+	}
+
+	return commitId, fileChange, nil
+}
+
+func (sqlb *SQLiteBackend) FileChanges(ctx context.Context, commitId string) ([]*common.FileChange, error) {
+	rows, err := sqlb.Db.QueryContext(ctx, "SELECT "+fileChangeColumns+" FROM commit_files WHERE commit_id = ? ORDER BY rowid", commitId)
+	if err != nil {
+		return nil, fmt.Errorf("retrieving file changes of commit %s: %w", commitId, err)
+	}
+
+	defer rows.Close()
+
+	var fileChanges []*common.FileChange
+	for rows.Next() {
+		_, fileChange, err := sqlb.scanRowInRowsToFileChange(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		fileChanges = append(fileChanges, fileChange)
 	}
 
-	defer accStmt.Close()
+	return fileChanges, rows.Err()
+}
 
-	rows, err := accStmt.Query()
+func commitsById(commits []*common.Commit) map[string]*common.Commit {
+	commitMap := make(map[string]*common.Commit, len(commits))
+	for _, commit := range commits {
+		commitMap[commit.Id] = commit
+	}
+
+	return commitMap
+}
+
+// Fills in the details stored outside of the commits table (file changes, trailers, parents) for
+// the given commits. commitIdsSelect is used in an IN clause and should select the ids of these
+// commits.
+func (sqlb *SQLiteBackend) attachCommitDetails(ctx context.Context, commits []*common.Commit, commitIdsSelect string, args ...any) error {
+	if len(commits) == 0 {
+		return nil
+	}
+
+	commitMap := commitsById(commits)
+
+	err := sqlb.attachFileChanges(ctx, commitMap, commitIdsSelect, args...)
+	if err != nil {
+		return err
+	}
+
+	err = sqlb.attachTrailers(ctx, commitMap, commitIdsSelect, args...)
+	if err != nil {
+		return err
+	}
+
+	return sqlb.attachParents(ctx, commitMap, commitIdsSelect, args...)
+}
+
+func (sqlb *SQLiteBackend) attachFileChanges(ctx context.Context, commits map[string]*common.Commit, commitIdsSelect string, args ...any) error {
+	stmt := "SELECT " + fileChangeColumns + " FROM commit_files WHERE commit_id IN (" + commitIdsSelect + ") ORDER BY rowid"
+	rows, err := sqlb.Db.QueryContext(ctx, stmt, args...)
+	if err != nil {
+		return fmt.Errorf("retrieving file changes: %w", err)
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		commitId, fileChange, err := sqlb.scanRowInRowsToFileChange(rows)
+		if err != nil {
+			return err
+		}
+
+		if commit, ok := commits[commitId]; ok {
+			commit.FileChanges = append(commit.FileChanges, fileChange)
+		}
+	}
+
+	return rows.Err()
+}
+
+// Columns in the order of scanRowInRowsToTrailer
+const trailerColumns = "commit_id, kind, key, value, person_name, person_email"
+
+func (sqlb *SQLiteBackend) scanRowInRowsToTrailer(rows *sql.Rows) (string, *common.Trailer, error) {
+	var commitId string
+	trailer := new(common.Trailer)
+
+	err := rows.Scan(
+		&commitId,
+		&trailer.Kind,
+		&trailer.Key,
+		&trailer.Value,
+		&trailer.Person.Name,
+		&trailer.Person.Email,
+	)
+	if err != nil {
+		return "", nil, fmt.Errorf("reading trailer: %w", err)
+	}
+
+	return commitId, trailer, nil
+}
+
+func (sqlb *SQLiteBackend) attachTrailers(ctx context.Context, commits map[string]*common.Commit, commitIdsSelect string, args ...any) error {
+	stmt := "SELECT " + trailerColumns + " FROM commit_trailers WHERE commit_id IN (" + commitIdsSelect + ") ORDER BY rowid"
+	rows, err := sqlb.Db.QueryContext(ctx, stmt, args...)
+	if err != nil {
+		return fmt.Errorf("retrieving trailers: %w", err)
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		commitId, trailer, err := sqlb.scanRowInRowsToTrailer(rows)
+		if err != nil {
+			return err
+		}
+
+		if commit, ok := commits[commitId]; ok {
+			commit.Trailers = append(commit.Trailers, trailer)
+		}
+	}
+
+	return rows.Err()
+}
+
+// Commits crediting co-authors through Co-authored-by trailers, including their trailers
+func (sqlb *SQLiteBackend) CoAuthoredCommits(ctx context.Context, repoName string) ([]*common.Commit, error) {
+	coAuthoredCondition := "commits.id IN (SELECT commit_id FROM commit_trailers WHERE kind = ?)"
+	stmt, args := CommitsSelect(repoName, coAuthoredCondition, common.CoAuthoredByTrailer)
+
+	rows, err := sqlb.Db.QueryContext(ctx, stmt, args...)
+	if err != nil {
+		return nil, fmt.Errorf("retrieving co-authored commits: %w", err)
+	}
+
+	commits, err := sqlb.scanCommits(rows)
 	if err != nil {
-		log.Fatalf("Error retrieving rows: %s", err)
 		return nil, err
 	}
 
-	authors := []string{}
+	repoCondition, repoArgs := RepoCondition(repoName)
+	err = sqlb.attachCommitDetails(ctx, commits,
+		"SELECT id FROM commits WHERE "+repoCondition+" AND "+coAuthoredCondition,
+		append(repoArgs, common.CoAuthoredByTrailer)...)
+	if err != nil {
+		return nil, err
+	}
+
+	return commits, nil
+}
+
+func (sqlb *SQLiteBackend) attachParents(ctx context.Context, commits map[string]*common.Commit, commitIdsSelect string, args ...any) error {
+	stmt := `SELECT commit_id, parent_id FROM commit_parents
+		WHERE commit_id IN (` + commitIdsSelect + `) ORDER BY commit_id, parent_index`
+	rows, err := sqlb.Db.QueryContext(ctx, stmt, args...)
+	if err != nil {
+		return fmt.Errorf("retrieving parents: %w", err)
+	}
+
+	defer rows.Close()
+
 	for rows.Next() {
-		author := new(string)
-		rows.Scan(author)
-		authors = append(authors, *author)
+		var commitId, parentId string
+		if err := rows.Scan(&commitId, &parentId); err != nil {
+			return fmt.Errorf("reading parent: %w", err)
+		}
+
+		if commit, ok := commits[commitId]; ok {
+			commit.ParentIds = append(commit.ParentIds, parentId)
+		}
+	}
+
+	return rows.Err()
+}
+
+// Merge commits, i.e. commits with more than one parent, including their parents. Only commits
+// ingested with merges included are recorded as merges.
+func (sqlb *SQLiteBackend) MergeCommits(ctx context.Context, repoName string) ([]*common.Commit, error) {
+	mergeCondition := "commits.id IN (SELECT commit_id FROM commit_parents WHERE parent_index > 0)"
+	stmt, args := CommitsSelect(repoName, mergeCondition)
+
+	rows, err := sqlb.Db.QueryContext(ctx, stmt, args...)
+	if err != nil {
+		return nil, fmt.Errorf("retrieving merge commits: %w", err)
 	}
 
-	return authors, nil
+	commits, err := sqlb.scanCommits(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	repoCondition, repoArgs := RepoCondition(repoName)
+	err = sqlb.attachCommitDetails(ctx, commits, "SELECT id FROM commits WHERE "+repoCondition+" AND "+mergeCondition, repoArgs...)
+	if err != nil {
+		return nil, err
+	}
+
+	return commits, nil
+}
+
+// Condition matching commits whose domain column (author_domain or committer_domain) is the domain
+// or, with includeSubdomains, one of its subdomains. Domains are matched case-insensitively.
+func DomainCondition(column string, domain string, includeSubdomains bool) (string, []any) {
+	domain = strings.ToLower(domain)
+	if !includeSubdomains {
+		return "commits." + column + " = ?", []any{domain}
+	}
+
+	escapedDomain := strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(domain)
+	return "commits." + column + " = ? OR commits." + column + ` LIKE ? ESCAPE '\'`, []any{domain, "%." + escapedDomain}
 }
 
-func (sqlb *SQLiteBackend) AuthorCommits(authorEmail string) ([]*common.Commit, error) {
-	stmt := "SELECT * FROM commits WHERE author_email = ?"
-	accStmt, err := sqlb.Db.Prepare(stmt)
+// Commits whose author email is of the domain (see DomainCondition), without their file changes,
+// trailers or parents
+func (sqlb *SQLiteBackend) DomainCommits(ctx context.Context, domain string, repoName string, includeSubdomains bool) ([]*common.Commit, error) {
+	return sqlb.collectQueriedCommits(ctx, common.NewCommitQuery().
+		WithRepo(repoName).
+		WithAuthorDomain(domain, includeSubdomains))
+}
+
+func (sqlb *SQLiteBackend) DomainLineChanges(ctx context.Context, domain string, repoName string, includeSubdomains bool) (*common.LineChanges, error) {
+	condition, conditionArgs := DomainCondition("author_domain", domain, includeSubdomains)
+	aggregates, err := sqlb.aggregates(ctx, repoName, condition, conditionArgs)
 	if err != nil {
-		log.Fatalf("Encountered error preparing commits retrieval statement: %s", err)
 		return nil, err
 	}
 
-	defer accStmt.Close()
+	return &aggregates[0].LineChanges, nil
+}
 
-	rows, err := accStmt.Query(authorEmail)
+func (sqlb *SQLiteBackend) DomainYearlyLineChanges(ctx context.Context, domain string, repoName string, includeSubdomains bool) (common.YearlyLineChangeMap, error) {
+	condition, conditionArgs := DomainCondition("author_domain", domain, includeSubdomains)
+	aggregates, err := sqlb.aggregates(ctx, repoName, condition, conditionArgs, common.AggregateByYear)
 	if err != nil {
-		log.Fatalf("Error retrieving rows: %s", err)
 		return nil, err
 	}
 
-	commits := make([]*common.Commit, 0)
+	yearBuckets := common.YearlyLineChangeMap{}
+	for _, aggregate := range aggregates {
+		yearBuckets.AddLineChanges(&aggregate.LineChanges, aggregate.Year)
+	}
+
+	return yearBuckets, nil
+}
+
+// Number of commits of an author in each month (by author time, in UTC) they committed in
+func (sqlb *SQLiteBackend) AuthorYearMonthCommits(ctx context.Context, authorEmail string, repoName string) (common.YearMonthCount, error) {
+	repoCondition, repoArgs := RepoCondition(repoName)
+	stmt := `SELECT
+			CAST(strftime('%Y', author_time, 'unixepoch') AS INT),
+			CAST(strftime('%m', author_time, 'unixepoch') AS INT),
+			COUNT(*)
+		FROM commits WHERE author_email = ? AND ` + repoCondition + `
+		GROUP BY 1, 2`
+
+	rows, err := sqlb.Db.QueryContext(ctx, stmt, append([]any{authorEmail}, repoArgs...)...)
+	if err != nil {
+		return nil, fmt.Errorf("retrieving monthly commits of %s: %w", authorEmail, err)
+	}
+
+	defer rows.Close()
+
+	yearMonthCommits := common.YearMonthCount{}
 	for rows.Next() {
-		commit := sqlb.ScanRowInRowsToCommits(rows)
-		commits = append(commits, commit)
+		var year, month, count int
+		if err := rows.Scan(&year, &month, &count); err != nil {
+			return nil, fmt.Errorf("reading monthly commits of %s: %w", authorEmail, err)
+		}
+
+		if _, ok := yearMonthCommits[year]; !ok {
+			yearMonthCommits[year] = common.MonthCount{}
+		}
+
+		yearMonthCommits[year][month] = count
 	}
 
-	return commits, nil
+	return yearMonthCommits, rows.Err()
 }
@@ -1,11 +1,11 @@
-package authorgroups
+package dbtesting
 
 import (
+	"context"
 	"strings"
 	"testing"
 	"time"
 
-	dbtesting "github.com/claucambra/commit-analysis-tool/internal/db/testing"
 	"github.com/claucambra/commit-analysis-tool/pkg/common"
 	"github.com/google/go-cmp/cmp"
 )
@@ -23,19 +23,21 @@ func emailHasDomain(email string, domain string) bool {
 	return domainFromEmail == domain
 }
 
-func TestDomainChanges(t *testing.T) {
-	sqlb := dbtesting.InitTestDB(t)
-	cleanup := func() { dbtesting.CleanupTestDB(sqlb) }
+func TestSqliteDomainChanges(t *testing.T) {
+	ctx := context.Background()
+
+	sqlb := InitTestDB(t)
+	cleanup := func() { CleanupTestDB(sqlb) }
 	t.Cleanup(cleanup)
 
-	dbtesting.IngestTestCommits(sqlb, t)
+	IngestTestCommits(sqlb, t)
 
-	retrievedDomainChanges, err := domainLineChanges(sqlb, testDomain)
+	retrievedDomainChanges, err := sqlb.DomainLineChanges(ctx, testDomain, "", false)
 	if err != nil {
 		t.Fatalf("Error retrieving domain's changes from database")
 	}
 
-	parsedCommitLog := dbtesting.ParsedTestCommitLog(t)
+	parsedCommitLog := ParsedTestCommitLog(t)
 	testDomainLineChanges := &common.LineChanges{
 		NumInsertions: 0,
 		NumDeletions:  0,
@@ -55,19 +57,21 @@ func TestDomainChanges(t *testing.T) {
 	}
 }
 
-func TestDomainYearlyChanges(t *testing.T) {
-	sqlb := dbtesting.InitTestDB(t)
-	cleanup := func() { dbtesting.CleanupTestDB(sqlb) }
+func TestSqliteDomainYearlyChanges(t *testing.T) {
+	ctx := context.Background()
+
+	sqlb := InitTestDB(t)
+	cleanup := func() { CleanupTestDB(sqlb) }
 	t.Cleanup(cleanup)
 
-	dbtesting.IngestTestCommits(sqlb, t)
+	IngestTestCommits(sqlb, t)
 
-	retrievedDomainYearlyLineChanges, err := domainYearlyLineChanges(sqlb, testDomain)
+	retrievedDomainYearlyLineChanges, err := sqlb.DomainYearlyLineChanges(ctx, testDomain, "", false)
 	if err != nil {
 		t.Fatalf("Error retrieving domain's yearly changes from database")
 	}
 
-	parsedCommitLog := dbtesting.ParsedTestCommitLog(t)
+	parsedCommitLog := ParsedTestCommitLog(t)
 	testDomainYearlyLineChanges := make(common.YearlyLineChangeMap, 0)
 
 	for _, commit := range parsedCommitLog {
@@ -0,0 +1,194 @@
+package dbtesting
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/claucambra/commit-analysis-tool/internal/db"
+	"github.com/claucambra/commit-analysis-tool/pkg/common"
+)
+
+// Iterator failing once its commits have been read, like a git log that is interrupted
+type failingCommitIterator struct {
+	*common.CommitSliceIterator
+	err error
+}
+
+func (fci *failingCommitIterator) Err() error {
+	return fci.err
+}
+
+func ingestTestCommits(numCommits int) []*common.Commit {
+	commits := make([]*common.Commit, numCommits)
+	for i := range commits {
+		commits[i] = &common.Commit{
+			Id:       fmt.Sprintf("%040x", i+1),
+			RepoName: "ingest",
+			Author:   common.Person{Name: "Author", Email: "author@example.com"},
+			FileChanges: []*common.FileChange{
+				{Path: "file.go", LineChanges: common.LineChanges{NumInsertions: 1}},
+			},
+		}
+	}
+
+	return commits
+}
+
+func TestSqliteIngestProgress(t *testing.T) {
+	ctx := context.Background()
+
+	sqlb := InitTestDB(t)
+	cleanup := func() { CleanupTestDB(sqlb) }
+	t.Cleanup(cleanup)
+
+	ingest, err := sqlb.BeginIngest(ctx)
+	if err != nil {
+		t.Fatalf("Could not begin ingest: %s", err)
+	}
+
+	const numCommits = 2500
+	reports := []db.IngestProgress{}
+	numAdded, err := ingest.IngestCommits(ctx, common.NewCommitSliceIterator(ingestTestCommits(numCommits)), func(progress db.IngestProgress) {
+		reports = append(reports, progress)
+	})
+	if err != nil {
+		t.Fatalf("Error ingesting commits: %s", err)
+	} else if numAdded != numCommits {
+		t.Fatalf("Unexpected number of added commits %d, expected %d", numAdded, numCommits)
+	}
+
+	if err = ingest.Commit(); err != nil {
+		t.Fatalf("Could not commit ingest: %s", err)
+	}
+
+	expectedReads := []int{1000, 2000, numCommits}
+	if len(reports) != len(expectedReads) {
+		t.Fatalf("Unexpected number of progress reports %d, expected %d", len(reports), len(expectedReads))
+	}
+
+	for i, report := range reports {
+		if report.NumRead != expectedReads[i] || report.NumAdded != expectedReads[i] {
+			t.Fatalf("Unexpected progress report %+v", report)
+		} else if report.Done != (i == len(reports)-1) {
+			t.Fatalf("Only the last progress report should be done: %+v", report)
+		}
+	}
+
+	commits, err := sqlb.Commits(ctx, "ingest")
+	if err != nil {
+		t.Fatalf("Error reading ingested commits: %s", err)
+	} else if len(commits) != numCommits {
+		t.Fatalf("Unexpected number of ingested commits %d, expected %d", len(commits), numCommits)
+	}
+}
+
+func TestSqliteIngestRollback(t *testing.T) {
+	ctx := context.Background()
+
+	sqlb := InitTestDB(t)
+	cleanup := func() { CleanupTestDB(sqlb) }
+	t.Cleanup(cleanup)
+
+	ingest, err := sqlb.BeginIngest(ctx)
+	if err != nil {
+		t.Fatalf("Could not begin ingest: %s", err)
+	}
+
+	readErr := errors.New("git log was interrupted")
+	commits := &failingCommitIterator{common.NewCommitSliceIterator(ingestTestCommits(10)), readErr}
+
+	_, err = ingest.IngestCommits(ctx, commits, nil)
+	if !errors.Is(err, readErr) {
+		t.Fatalf("Unexpected ingest error %v, expected %v", err, readErr)
+	}
+
+	if err = ingest.SetIngestedRefTips(ctx, "ingest", map[string]string{"refs/heads/main": fmt.Sprintf("%040x", 10)}); err != nil {
+		t.Fatalf("Could not record ref tips: %s", err)
+	} else if err = ingest.AddRepository(ctx, &common.Repository{Name: "ingest"}); err != nil {
+		t.Fatalf("Could not record repository: %s", err)
+	} else if err = ingest.Rollback(); err != nil {
+		t.Fatalf("Could not roll back ingest: %s", err)
+	}
+
+	ingestedCommits, err := sqlb.Commits(ctx, "")
+	if err != nil {
+		t.Fatalf("Error reading commits: %s", err)
+	} else if len(ingestedCommits) != 0 {
+		t.Fatalf("Rolled back ingest left %d commits behind", len(ingestedCommits))
+	}
+
+	tips, err := sqlb.IngestedRefTips(ctx, "ingest")
+	if err != nil {
+		t.Fatalf("Error reading ref tips: %s", err)
+	} else if len(tips) != 0 {
+		t.Fatalf("Rolled back ingest left ref tips behind: %v", tips)
+	}
+
+	var numFileChanges int
+	if err = sqlb.Db.QueryRow("SELECT COUNT(*) FROM commit_files").Scan(&numFileChanges); err != nil {
+		t.Fatalf("Error counting file changes: %s", err)
+	} else if numFileChanges != 0 {
+		t.Fatalf("Rolled back ingest left %d file changes behind", numFileChanges)
+	}
+
+	// The same commits can be ingested again afterwards
+	numAdded, err := sqlb.IngestCommits(ctx, common.NewCommitSliceIterator(ingestTestCommits(10)))
+	if err != nil {
+		t.Fatalf("Error ingesting commits again: %s", err)
+	} else if numAdded != 10 {
+		t.Fatalf("Unexpected number of added commits %d, expected 10", numAdded)
+	}
+}
+
+// Iterator cancelling the ingest's context after producing some commits, like an interrupted CLI
+type cancellingCommitIterator struct {
+	*common.CommitSliceIterator
+	cancel      context.CancelFunc
+	numBefore   int
+	numProduced int
+}
+
+func (cci *cancellingCommitIterator) Next() bool {
+	if cci.numProduced == cci.numBefore {
+		cci.cancel()
+	}
+
+	cci.numProduced++
+	return cci.CommitSliceIterator.Next()
+}
+
+func TestSqliteIngestCancelled(t *testing.T) {
+	sqlb := InitTestDB(t)
+	cleanup := func() { CleanupTestDB(sqlb) }
+	t.Cleanup(cleanup)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	commits := &cancellingCommitIterator{
+		CommitSliceIterator: common.NewCommitSliceIterator(ingestTestCommits(10)),
+		cancel:              cancel,
+		numBefore:           5,
+	}
+
+	numAdded, err := sqlb.IngestCommits(ctx, commits)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Unexpected ingest error %v, expected %v", err, context.Canceled)
+	} else if numAdded != 0 {
+		t.Fatalf("Cancelled ingest reported %d added commits", numAdded)
+	}
+
+	ingestedCommits, err := sqlb.Commits(context.Background(), "")
+	if err != nil {
+		t.Fatalf("Error reading commits: %s", err)
+	} else if len(ingestedCommits) != 0 {
+		t.Fatalf("Cancelled ingest left %d commits behind", len(ingestedCommits))
+	}
+
+	// Queries stop with the context's error too
+	if _, err := sqlb.Commits(ctx, ""); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Unexpected error %v querying with a cancelled context, expected %v", err, context.Canceled)
+	}
+}
@@ -0,0 +1,282 @@
+package dbtesting
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/claucambra/commit-analysis-tool/internal/commitfields"
+	"github.com/claucambra/commit-analysis-tool/internal/db"
+	"github.com/claucambra/commit-analysis-tool/pkg/common"
+	"github.com/google/go-cmp/cmp"
+)
+
+func tableColumns(t *testing.T, sqlb *db.SQLiteBackend, table string) map[string]bool {
+	rows, err := sqlb.Db.Query("SELECT name FROM pragma_table_info(?)", table)
+	if err != nil {
+		t.Fatalf("Could not read columns of %s: %s", table, err)
+	}
+
+	defer rows.Close()
+
+	columns := map[string]bool{}
+	for rows.Next() {
+		var column string
+		rows.Scan(&column)
+		columns[column] = true
+	}
+
+	return columns
+}
+
+func TestSqliteMigrationsCreateCommitFieldColumns(t *testing.T) {
+	ctx := context.Background()
+
+	sqlb := InitTestDB(t)
+	cleanup := func() { CleanupTestDB(sqlb) }
+	t.Cleanup(cleanup)
+
+	version, err := sqlb.SchemaVersion(ctx)
+	if err != nil {
+		t.Fatalf("Error reading schema version: %s", err)
+	} else if version != db.LatestSchemaVersion() {
+		t.Fatalf("Unexpected schema version %d, expected %d", version, db.LatestSchemaVersion())
+	}
+
+	columns := tableColumns(t, sqlb, "commits")
+	for _, column := range commitfields.Columns("") {
+		if !columns[column] {
+			t.Fatalf("Commits table lacks column %s, add a migration creating it", column)
+		}
+	}
+}
+
+// The commits table is created by the migrations while commits are inserted into and scanned from
+// the columns of commitfields.Fields, so both must have the same columns
+func TestSqliteMigrationsMatchCommitFieldColumns(t *testing.T) {
+	sqlb := InitTestDB(t)
+	cleanup := func() { CleanupTestDB(sqlb) }
+	t.Cleanup(cleanup)
+
+	fieldColumns := map[string]bool{}
+	for _, column := range commitfields.Columns("") {
+		fieldColumns[column] = true
+	}
+
+	if columns := tableColumns(t, sqlb, "commits"); !cmp.Equal(fieldColumns, columns) {
+		t.Fatalf("Commits table columns do not match commit field columns: %s", cmp.Diff(fieldColumns, columns))
+	}
+}
+
+// Databases created before schema versions were recorded named the repository in the commits table
+func TestSqliteMigrateLegacyDatabase(t *testing.T) {
+	ctx := context.Background()
+
+	dbPath := filepath.Join(t.TempDir(), "legacy.db")
+
+	sqlb := new(db.SQLiteBackend)
+	if err := sqlb.OpenUnmigrated(dbPath); err != nil {
+		t.Fatalf("Could not open database: %s", err)
+	}
+
+	_, err := sqlb.Db.Exec(`CREATE TABLE commits (
+			id TEXT PRIMARY KEY ON CONFLICT REPLACE,
+			repo_name TEXT NOT NULL,
+			author_name TEXT,
+			author_email TEXT,
+			author_time INT,
+			committer_name TEXT,
+			committer_email TEXT,
+			committer_time INT,
+			num_insertions INT,
+			num_deletions INT,
+			num_files_changed INT,
+			subject TEXT,
+			body TEXT);
+		CREATE INDEX index_repo_name ON commits (repo_name);
+		INSERT INTO commits VALUES ('a', 'vlc', 'Claudio Cambra', 'developer@ClaudioCambra.com',
+			1685867734, 'Claudio Cambra', 'developer@claudiocambra.com', 1685867734, 3, 1, 2,
+			'Add readme', '')`)
+	if err != nil {
+		t.Fatalf("Could not create legacy database: %s", err)
+	}
+
+	pending, err := sqlb.PendingMigrations(ctx)
+	if err != nil {
+		t.Fatalf("Error reading pending migrations: %s", err)
+	} else if len(pending) != db.LatestSchemaVersion()-1 || pending[0].Version != 2 {
+		t.Fatalf("Unexpected pending migrations: %+v", pending)
+	}
+
+	sqlb.Close()
+
+	if err := sqlb.Open(ctx, dbPath); err != nil {
+		t.Fatalf("Could not migrate legacy database: %s", err)
+	}
+
+	t.Cleanup(func() { sqlb.Close() })
+
+	if tableColumns(t, sqlb, "commits")["repo_name"] {
+		t.Fatalf("Repository names were not moved out of the commits table")
+	}
+
+	expectedCommit := &common.Commit{
+		Changes: common.Changes{
+			LineChanges:     common.LineChanges{NumInsertions: 3, NumDeletions: 1},
+			NumFilesChanged: 2,
+		},
+		Id:            "a",
+		RepoName:      "vlc",
+		Author:        common.Person{Name: "Claudio Cambra", Email: "developer@ClaudioCambra.com"},
+		AuthorTime:    1685867734,
+		Committer:     common.Person{Name: "Claudio Cambra", Email: "developer@claudiocambra.com"},
+		CommitterTime: 1685867734,
+		Subject:       "Add readme",
+	}
+
+	commits, err := sqlb.Commits(ctx, "vlc")
+	if err != nil {
+		t.Fatalf("Error reading migrated commits: %s", err)
+	}
+
+	CompareCommitArrays(t, []*common.Commit{expectedCommit}, commits)
+
+	// Domains of existing commits are filled in, lower-cased
+	domainCommits, err := sqlb.DomainCommits(ctx, "claudiocambra.com", "", false)
+	if err != nil {
+		t.Fatalf("Error reading migrated domain commits: %s", err)
+	} else if len(domainCommits) != 1 {
+		t.Fatalf("Unexpected number of migrated domain commits %d, expected 1", len(domainCommits))
+	}
+
+	if pending, err := sqlb.PendingMigrations(ctx); err != nil || len(pending) != 0 {
+		t.Fatalf("Unexpected pending migrations after migrating: %+v %v", pending, err)
+	}
+}
+
+// Repositories used to be named after the last component of their origin url
+func TestSqliteMigrateRepoNames(t *testing.T) {
+	ctx := context.Background()
+
+	dbPath := filepath.Join(t.TempDir(), "reponames.db")
+
+	sqlb := new(db.SQLiteBackend)
+	if err := sqlb.Open(ctx, dbPath); err != nil {
+		t.Fatalf("Could not open database: %s", err)
+	}
+
+	_, err := sqlb.Db.Exec(`INSERT INTO repos VALUES ('vlc', 'https://code.videolan.org/videolan/vlc.git', '/src/vlc', 'master', 1, 2);
+		INSERT INTO repos VALUES ('local', '', '/src/local', 'main', 1, 2);
+		INSERT INTO ingested_refs VALUES ('vlc', 'refs/heads/master', 'a');
+		INSERT INTO repo_commits VALUES ('vlc', 'a');
+		INSERT INTO repo_commits VALUES ('local', 'b');
+		DELETE FROM schema_version WHERE version = 9;`)
+	if err != nil {
+		t.Fatalf("Could not create database with old repository names: %s", err)
+	}
+
+	sqlb.Close()
+
+	if err := sqlb.Open(ctx, dbPath); err != nil {
+		t.Fatalf("Could not migrate database: %s", err)
+	}
+
+	t.Cleanup(func() { sqlb.Close() })
+
+	expectedName := "code.videolan.org/videolan/vlc"
+	if repo, err := sqlb.Repository(ctx, expectedName); err != nil || repo == nil {
+		t.Fatalf("Repository was not renamed to %s: %+v %v", expectedName, repo, err)
+	}
+
+	if tips, err := sqlb.IngestedRefTips(ctx, expectedName); err != nil || tips["refs/heads/master"] != "a" {
+		t.Fatalf("Ingested ref tips were not renamed: %+v %v", tips, err)
+	}
+
+	if repos, err := sqlb.CommitRepos(ctx, "a"); err != nil || len(repos) != 1 || repos[0] != expectedName {
+		t.Fatalf("Repository commits were not renamed: %+v %v", repos, err)
+	}
+
+	// Repositories without an origin url keep their names
+	if repos, err := sqlb.CommitRepos(ctx, "b"); err != nil || len(repos) != 1 || repos[0] != "local" {
+		t.Fatalf("Repository without origin url was renamed: %+v %v", repos, err)
+	}
+}
+
+// Repositories whose new names are taken are merged instead of failing the migration
+func TestSqliteMigrateRepoNameCollisions(t *testing.T) {
+	ctx := context.Background()
+
+	dbPath := filepath.Join(t.TempDir(), "reponamecollisions.db")
+
+	sqlb := new(db.SQLiteBackend)
+	if err := sqlb.Open(ctx, dbPath); err != nil {
+		t.Fatalf("Could not open database: %s", err)
+	}
+
+	// Two clones of the same origin, and a repository ingested under the new name of another
+	_, err := sqlb.Db.Exec(`INSERT INTO repos VALUES ('vlc', 'https://code.videolan.org/videolan/vlc.git', '/src/vlc', 'master', 5, 6);
+		INSERT INTO repos VALUES ('vlc-clone', 'git@code.videolan.org:videolan/vlc.git', '/src/vlc-clone', 'master', 1, 9);
+		INSERT INTO repos VALUES ('code.videolan.org/videolan/libvlc', '', '/src/libvlc', 'master', 3, 4);
+		INSERT INTO repos VALUES ('libvlc', 'https://code.videolan.org/videolan/libvlc.git', '/src/libvlc-old', 'master', 2, 3);
+		INSERT INTO ingested_refs VALUES ('vlc', 'refs/heads/master', 'a');
+		INSERT INTO ingested_refs VALUES ('vlc-clone', 'refs/heads/master', 'c');
+		INSERT INTO ingested_refs VALUES ('vlc-clone', 'refs/heads/dev', 'c');
+		INSERT INTO repo_commits VALUES ('vlc', 'a');
+		INSERT INTO repo_commits VALUES ('vlc', 'b');
+		INSERT INTO repo_commits VALUES ('vlc-clone', 'b');
+		INSERT INTO repo_commits VALUES ('vlc-clone', 'c');
+		INSERT INTO repo_commits VALUES ('code.videolan.org/videolan/libvlc', 'd');
+		INSERT INTO repo_commits VALUES ('libvlc', 'e');
+		DELETE FROM schema_version WHERE version = 9;`)
+	if err != nil {
+		t.Fatalf("Could not create database with old repository names: %s", err)
+	}
+
+	sqlb.Close()
+
+	if err := sqlb.Open(ctx, dbPath); err != nil {
+		t.Fatalf("Could not migrate database: %s", err)
+	}
+
+	t.Cleanup(func() { sqlb.Close() })
+
+	repos, err := sqlb.Repositories(ctx)
+	if err != nil {
+		t.Fatalf("Error reading repositories: %s", err)
+	}
+
+	repoIngestTimes := map[string][2]int64{}
+	for _, repo := range repos {
+		repoIngestTimes[repo.Name] = [2]int64{repo.FirstIngestTime, repo.LastIngestTime}
+	}
+
+	expectedIngestTimes := map[string][2]int64{
+		"code.videolan.org/videolan/vlc":    {1, 9},
+		"code.videolan.org/videolan/libvlc": {2, 4},
+	}
+	if !cmp.Equal(expectedIngestTimes, repoIngestTimes) {
+		t.Fatalf("Unexpected repositories after merging: %s", cmp.Diff(expectedIngestTimes, repoIngestTimes))
+	}
+
+	expectedCommitRepos := map[string]string{
+		"a": "code.videolan.org/videolan/vlc",
+		"b": "code.videolan.org/videolan/vlc",
+		"c": "code.videolan.org/videolan/vlc",
+		"d": "code.videolan.org/videolan/libvlc",
+		"e": "code.videolan.org/videolan/libvlc",
+	}
+	for commitId, expectedRepo := range expectedCommitRepos {
+		if commitRepos, err := sqlb.CommitRepos(ctx, commitId); err != nil || len(commitRepos) != 1 || commitRepos[0] != expectedRepo {
+			t.Fatalf("Unexpected repositories %v of commit %s, expected %s: %v", commitRepos, commitId, expectedRepo, err)
+		}
+	}
+
+	// Refs ingested under both names keep the tips of the repository merged into first
+	tips, err := sqlb.IngestedRefTips(ctx, "code.videolan.org/videolan/vlc")
+	expectedTips := map[string]string{"refs/heads/master": "a", "refs/heads/dev": "c"}
+	if err != nil {
+		t.Fatalf("Error reading ingested ref tips: %s", err)
+	} else if !cmp.Equal(expectedTips, tips) {
+		t.Fatalf("Unexpected ingested ref tips after merging: %s", cmp.Diff(expectedTips, tips))
+	}
+}
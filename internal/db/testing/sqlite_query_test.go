@@ -0,0 +1,96 @@
+package dbtesting
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/claucambra/commit-analysis-tool/pkg/common"
+	"github.com/google/go-cmp/cmp"
+)
+
+// Commits with few distinct times and sizes, so that pages split commits with equal order keys. All
+// of their subjects match the text "commit".
+func queryTestCommits() []*common.Commit {
+	commits := []*common.Commit{}
+	for i := 0; i < 50; i++ {
+		commits = append(commits, &common.Commit{
+			Changes:       common.Changes{LineChanges: common.LineChanges{NumInsertions: i % 4, NumDeletions: i % 3}},
+			Id:            fmt.Sprintf("%040x", i+1),
+			RepoName:      "query",
+			Author:        common.Person{Email: fmt.Sprintf("author%d@example.com", i%5)},
+			AuthorTime:    int64(1000 + (i*7)%5),
+			Committer:     common.Person{Email: "committer@example.com"},
+			CommitterTime: int64(2000 - i%6),
+			Subject:       fmt.Sprintf("Commit %d", i),
+		})
+	}
+
+	return commits
+}
+
+func TestSqliteQueryCommitsPages(t *testing.T) {
+	ctx := context.Background()
+
+	sqlb := InitTestDB(t)
+	cleanup := func() { CleanupTestDB(sqlb) }
+	t.Cleanup(cleanup)
+
+	commits := queryTestCommits()
+	if err := sqlb.AddCommits(ctx, commits); err != nil {
+		t.Fatalf("Error adding commits: %s", err)
+	}
+
+	orders := []common.CommitOrder{
+		common.OrderByIngest,
+		common.OrderByAuthorTime,
+		common.OrderByCommitterTime,
+		common.OrderByLineChanges,
+	}
+
+	for _, order := range orders {
+		for _, descending := range []bool{false, true} {
+			query := common.NewCommitQuery().
+				WithOrder(order, descending).
+				WithText("commit").
+				WithLineChangesRange(1, -1).
+				WithOffset(3).
+				WithLimit(30)
+
+			sortedCommits := []*common.Commit{}
+			for _, commit := range commits {
+				if query.Matches(commit) {
+					sortedCommits = append(sortedCommits, commit)
+				}
+			}
+
+			query.SortCommits(sortedCommits)
+			expectedCommitIds := []string{}
+			for _, commit := range sortedCommits[3:33] {
+				expectedCommitIds = append(expectedCommitIds, commit.Id)
+			}
+
+			for _, pageSize := range []int{1, 7, 0} {
+				queryCommits, err := sqlb.QueryCommits(ctx, query.WithPageSize(pageSize))
+				if err != nil {
+					t.Fatalf("Error querying commits: %s", err)
+				}
+
+				collectedCommits, err := common.CollectCommits(queryCommits)
+				if err != nil {
+					t.Fatalf("Error reading queried commits: %s", err)
+				}
+
+				commitIds := []string{}
+				for _, commit := range collectedCommits {
+					commitIds = append(commitIds, commit.Id)
+				}
+
+				if !cmp.Equal(expectedCommitIds, commitIds) {
+					t.Fatalf("Unexpected commits in order %d (descending %t) with page size %d: %s",
+						order, descending, pageSize, cmp.Diff(expectedCommitIds, commitIds))
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package dbtesting
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/claucambra/commit-analysis-tool/internal/db"
+	"github.com/claucambra/commit-analysis-tool/pkg/common"
+	"github.com/google/go-cmp/cmp"
+)
+
+func searchCommitIds(t *testing.T, sqlb *db.SQLiteBackend, query string, repoName string) []string {
+	ctx := context.Background()
+
+	commits, err := sqlb.SearchCommits(ctx, query, repoName)
+	if err != nil {
+		t.Fatalf("Error searching for %q: %s", query, err)
+	}
+
+	commitIds := []string{}
+	for _, commit := range commits {
+		commitIds = append(commitIds, commit.Id)
+	}
+
+	return commitIds
+}
+
+func TestSqliteSearchCommits(t *testing.T) {
+	ctx := context.Background()
+
+	sqlb := InitTestDB(t)
+	cleanup := func() { CleanupTestDB(sqlb) }
+	t.Cleanup(cleanup)
+
+	module, err := sqlb.SearchModule(ctx)
+	if err != nil {
+		t.Fatalf("Error reading search module: %s", err)
+	} else if module != db.FTS4SearchModule && module != db.FTS5SearchModule {
+		t.Fatalf("Unexpected search module %q", module)
+	}
+
+	for _, index := range []string{"index_subject", "index_body"} {
+		var count int
+		if err := sqlb.Db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE name = ?", index).Scan(&count); err != nil {
+			t.Fatalf("Error looking up %s: %s", index, err)
+		} else if count != 0 {
+			t.Fatalf("Index %s should be replaced by the search index", index)
+		}
+	}
+
+	commits := []*common.Commit{
+		{Id: "a", RepoName: "vlc", Subject: "Fix memory leak in decoder", Body: "The decoder leaked frames."},
+		{Id: "b", RepoName: "vlc", Subject: "Add subtitle renderer", Body: "Fixes rendering of memory-mapped fonts."},
+		{Id: "c", RepoName: "libvlc", Subject: "Fix crash on exit"},
+	}
+	if err := sqlb.AddCommits(ctx, commits); err != nil {
+		t.Fatalf("Error adding commits: %s", err)
+	}
+
+	expectedSearches := []struct {
+		query             string
+		repoName          string
+		expectedCommitIds []string
+	}{
+		{"fix", "", []string{"a", "c"}},
+		{`"memory leak"`, "", []string{"a"}},
+		{"memory NOT leak", "", []string{"b"}},
+		{"fix* AND memory", "", []string{"a", "b"}},
+		{"subject:fix OR subtitle", "", []string{"a", "b", "c"}},
+		{"fix", "libvlc", []string{"c"}},
+	}
+	for _, search := range expectedSearches {
+		commitIds := searchCommitIds(t, sqlb, search.query, search.repoName)
+		if !cmp.Equal(search.expectedCommitIds, commitIds) {
+			t.Fatalf("Unexpected results for %q: %s", search.query, cmp.Diff(search.expectedCommitIds, commitIds))
+		}
+	}
+
+	// Replaced commits are indexed with their new subject only
+	replacedCommit := *commits[2]
+	replacedCommit.Subject = "Avoid crash on exit"
+	if err := sqlb.AddCommit(ctx, &replacedCommit); err != nil {
+		t.Fatalf("Error replacing commit: %s", err)
+	}
+
+	if commitIds := searchCommitIds(t, sqlb, "fix", ""); !cmp.Equal([]string{"a"}, commitIds) {
+		t.Fatalf("Replaced commit still matches its old subject: %v", commitIds)
+	} else if commitIds := searchCommitIds(t, sqlb, "avoid", ""); !cmp.Equal([]string{"c"}, commitIds) {
+		t.Fatalf("Replaced commit does not match its new subject: %v", commitIds)
+	}
+
+	if err := sqlb.RebuildSearchIndex(ctx); err != nil {
+		t.Fatalf("Error rebuilding search index: %s", err)
+	} else if commitIds := searchCommitIds(t, sqlb, "crash", ""); !cmp.Equal([]string{"c"}, commitIds) {
+		t.Fatalf("Unexpected results after rebuilding search index: %v", commitIds)
+	}
+
+	if _, err := sqlb.SearchCommits(ctx, `"unterminated`, ""); err == nil {
+		t.Fatalf("Malformed query should fail")
+	}
+}
+
+// Builds without FTS5 must not ingest commits that an FTS5 search index would then be missing
+func TestSqliteIngestRefusesUnusableSearchIndex(t *testing.T) {
+	ctx := context.Background()
+
+	dbPath := filepath.Join(t.TempDir(), "fts5.db")
+
+	sqlb := new(db.SQLiteBackend)
+	if err := sqlb.Open(ctx, dbPath); err != nil {
+		t.Fatalf("Could not open database: %s", err)
+	}
+
+	t.Cleanup(func() { sqlb.Close() })
+
+	if module, err := sqlb.SearchModule(ctx); err != nil {
+		t.Fatalf("Error reading search module: %s", err)
+	} else if module == db.FTS5SearchModule {
+		t.Skip("This build can use FTS5 search indexes")
+	}
+
+	// Makes the index look like it was created by a build with FTS5
+	_, err := sqlb.Db.Exec(`PRAGMA writable_schema = ON;
+		UPDATE sqlite_master SET sql = replace(sql, 'fts4', 'fts5') WHERE name = 'commits_fts';
+		PRAGMA writable_schema = OFF;`)
+	if err != nil {
+		t.Fatalf("Could not rewrite search index definition: %s", err)
+	}
+
+	sqlb.Close()
+
+	if err := sqlb.Open(ctx, dbPath); err != nil {
+		t.Fatalf("Could not reopen database: %s", err)
+	}
+
+	if ingest, err := sqlb.BeginIngest(ctx); err == nil {
+		ingest.Rollback()
+		t.Fatalf("Ingest began although the search index cannot be updated")
+	}
+
+	if _, err := sqlb.SearchCommits(ctx, "fix", ""); err == nil {
+		t.Fatalf("Search succeeded although the search index cannot be used")
+	}
+}
@@ -1,6 +1,7 @@
 package dbtesting
 
 import (
+	"context"
 	"testing"
 
 	"github.com/claucambra/commit-analysis-tool/pkg/common"
@@ -10,6 +11,8 @@ import (
 const expectedCommitCount = 20000
 
 func TestSqliteDbAddCommit(t *testing.T) {
+	ctx := context.Background()
+
 	sqlb := InitTestDB(t)
 	cleanup := func() { CleanupTestDB(sqlb) }
 	t.Cleanup(cleanup)
@@ -18,8 +21,8 @@ func TestSqliteDbAddCommit(t *testing.T) {
 
 	commit := parsedCommitLog[0]
 
-	sqlb.AddCommit(commit)
-	retrievedCommit, err := sqlb.Commit(commit.Id)
+	sqlb.AddCommit(ctx, commit)
+	retrievedCommit, err := sqlb.Commit(ctx, commit.Id)
 	if err != nil {
 		t.Fatalf("Error during commit retrieval: %s", err)
 	}
@@ -30,6 +33,8 @@ func TestSqliteDbAddCommit(t *testing.T) {
 }
 
 func TestSqliteCommits(t *testing.T) {
+	ctx := context.Background()
+
 	sqlb := InitTestDB(t)
 	cleanup := func() { CleanupTestDB(sqlb) }
 	t.Cleanup(cleanup)
@@ -42,7 +47,7 @@ func TestSqliteCommits(t *testing.T) {
 
 	testCommits := ParsedTestCommitLog(t)
 
-	retrievedCommits, err := sqlb.Commits()
+	retrievedCommits, err := sqlb.Commits(ctx, "")
 	if err != nil {
 		t.Fatalf("Could not retrieve commits in database")
 	}
@@ -51,13 +56,15 @@ func TestSqliteCommits(t *testing.T) {
 }
 
 func TestSqliteAuthors(t *testing.T) {
+	ctx := context.Background()
+
 	sqlb := InitTestDB(t)
 	cleanup := func() { CleanupTestDB(sqlb) }
 	t.Cleanup(cleanup)
 
 	IngestTestCommits(sqlb, t)
 
-	authors, err := sqlb.Authors()
+	authors, err := sqlb.Authors(ctx, "")
 	if err != nil {
 		t.Fatalf("Received error when fetching authors: %s", err)
 	}
@@ -71,6 +78,8 @@ func TestSqliteAuthors(t *testing.T) {
 }
 
 func TestSqliteAuthorCommits(t *testing.T) {
+	ctx := context.Background()
+
 	sqlb := InitTestDB(t)
 	cleanup := func() { CleanupTestDB(sqlb) }
 	t.Cleanup(cleanup)
@@ -83,7 +92,7 @@ func TestSqliteAuthorCommits(t *testing.T) {
 
 	testAuthorEmail := "developer@claudiocambra.com"
 
-	retrievedAuthorCommits, err := sqlb.AuthorCommits(testAuthorEmail)
+	retrievedAuthorCommits, err := sqlb.AuthorCommits(ctx, testAuthorEmail, "")
 	if err != nil {
 		t.Fatalf("Could not retrieve commits for author in database")
 	}
@@ -99,3 +108,376 @@ func TestSqliteAuthorCommits(t *testing.T) {
 
 	CompareCommitArrays(t, testAuthorCommits, retrievedAuthorCommits)
 }
+
+func TestSqliteFileChanges(t *testing.T) {
+	ctx := context.Background()
+
+	sqlb := InitTestDB(t)
+	cleanup := func() { CleanupTestDB(sqlb) }
+	t.Cleanup(cleanup)
+
+	commit := &common.Commit{
+		Id: "4610c5caa1b48f113ee87f48aeace2846a474957",
+		FileChanges: []*common.FileChange{
+			{Path: "assets/icon.png", Binary: true},
+			{Path: "src/new/file.go", PreviousPath: "src/old/file.go"},
+			{Path: "README.md", LineChanges: common.LineChanges{NumInsertions: 12, NumDeletions: 40}},
+		},
+	}
+
+	// Adding a commit twice should not duplicate its file changes
+	for i := 0; i < 2; i++ {
+		if err := sqlb.AddCommit(ctx, commit); err != nil {
+			t.Fatalf("Error adding commit: %s", err)
+		}
+	}
+
+	retrievedFileChanges, err := sqlb.FileChanges(ctx, commit.Id)
+	if err != nil {
+		t.Fatalf("Error during file changes retrieval: %s", err)
+	}
+
+	if !cmp.Equal(commit.FileChanges, retrievedFileChanges) {
+		t.Fatalf(`Database file changes do not equal expected file changes. %s`, cmp.Diff(commit.FileChanges, retrievedFileChanges))
+	}
+
+	retrievedCommits, err := sqlb.Commits(ctx, "")
+	if err != nil {
+		t.Fatalf("Error during commits retrieval: %s", err)
+	}
+
+	CompareCommitArrays(t, []*common.Commit{commit}, retrievedCommits)
+}
+
+func TestSqliteIngestCommitsSkipsExisting(t *testing.T) {
+	ctx := context.Background()
+
+	sqlb := InitTestDB(t)
+	cleanup := func() { CleanupTestDB(sqlb) }
+	t.Cleanup(cleanup)
+
+	commitA := &common.Commit{Id: "1c915e7dd147d4b060c2c241bb966d6f6c6ecde9"}
+	commitB := &common.Commit{Id: "4610c5caa1b48f113ee87f48aeace2846a474957"}
+
+	numAdded, err := sqlb.IngestCommits(ctx, common.NewCommitSliceIterator([]*common.Commit{commitA}))
+	if err != nil {
+		t.Fatalf("Error ingesting commits: %s", err)
+	} else if numAdded != 1 {
+		t.Fatalf("Unexpected number of ingested commits: expected 1, received %d", numAdded)
+	}
+
+	numAdded, err = sqlb.IngestCommits(ctx, common.NewCommitSliceIterator([]*common.Commit{commitA, commitB}))
+	if err != nil {
+		t.Fatalf("Error ingesting commits: %s", err)
+	} else if numAdded != 1 {
+		t.Fatalf("Unexpected number of ingested commits: expected 1, received %d", numAdded)
+	}
+
+	for _, commit := range []*common.Commit{commitA, commitB} {
+		if exists, err := sqlb.HasCommit(ctx, commit.Id); err != nil || !exists {
+			t.Fatalf("Ingested commit %s not found in database: %v", commit.Id, err)
+		}
+	}
+}
+
+func TestSqliteIngestedRefTips(t *testing.T) {
+	ctx := context.Background()
+
+	sqlb := InitTestDB(t)
+	cleanup := func() { CleanupTestDB(sqlb) }
+	t.Cleanup(cleanup)
+
+	testRepo := "/repos/vlc"
+	firstTips := map[string]string{
+		"HEAD":              "1c915e7dd147d4b060c2c241bb966d6f6c6ecde9",
+		"refs/heads/master": "1c915e7dd147d4b060c2c241bb966d6f6c6ecde9",
+		"refs/heads/old":    "4610c5caa1b48f113ee87f48aeace2846a474957",
+	}
+	secondTips := map[string]string{
+		"HEAD":              "93367dcd81d5a5709f53abd78054fb444cd9af2f",
+		"refs/heads/master": "93367dcd81d5a5709f53abd78054fb444cd9af2f",
+	}
+
+	for _, tips := range []map[string]string{firstTips, secondTips} {
+		if err := sqlb.SetIngestedRefTips(ctx, testRepo, tips); err != nil {
+			t.Fatalf("Error setting ingested ref tips: %s", err)
+		}
+
+		retrievedTips, err := sqlb.IngestedRefTips(ctx, testRepo)
+		if err != nil {
+			t.Fatalf("Error retrieving ingested ref tips: %s", err)
+		}
+
+		if !cmp.Equal(tips, retrievedTips) {
+			t.Fatalf("Retrieved ref tips do not equal expected ref tips. %s", cmp.Diff(tips, retrievedTips))
+		}
+	}
+
+	otherRepoTips, err := sqlb.IngestedRefTips(ctx, "/repos/other")
+	if err != nil {
+		t.Fatalf("Error retrieving ingested ref tips: %s", err)
+	} else if len(otherRepoTips) != 0 {
+		t.Fatalf("Received ref tips for a repository that was never ingested: %+v", otherRepoTips)
+	}
+}
+
+func TestSqliteRepositories(t *testing.T) {
+	ctx := context.Background()
+
+	sqlb := InitTestDB(t)
+	cleanup := func() { CleanupTestDB(sqlb) }
+	t.Cleanup(cleanup)
+
+	repo := &common.Repository{
+		Name:            "vlc",
+		OriginUrl:       "https://code.videolan.org/videolan/vlc.git",
+		LocalPath:       "/repos/vlc",
+		DefaultBranch:   "master",
+		FirstIngestTime: 1000,
+		LastIngestTime:  1000,
+	}
+
+	if err := sqlb.AddRepository(ctx, repo); err != nil {
+		t.Fatalf("Error adding repository: %s", err)
+	}
+
+	// Re-ingesting should only move the last ingest time forward
+	updatedRepo := *repo
+	updatedRepo.LocalPath = "/other/vlc"
+	updatedRepo.FirstIngestTime = 2000
+	updatedRepo.LastIngestTime = 2000
+
+	if err := sqlb.AddRepository(ctx, &updatedRepo); err != nil {
+		t.Fatalf("Error updating repository: %s", err)
+	}
+
+	expectedRepo := updatedRepo
+	expectedRepo.FirstIngestTime = repo.FirstIngestTime
+
+	retrievedRepos, err := sqlb.Repositories(ctx)
+	if err != nil {
+		t.Fatalf("Error retrieving repositories: %s", err)
+	}
+
+	expectedRepos := []*common.Repository{&expectedRepo}
+	if !cmp.Equal(expectedRepos, retrievedRepos) {
+		t.Fatalf("Retrieved repositories do not equal expected repositories. %s", cmp.Diff(expectedRepos, retrievedRepos))
+	}
+}
+
+func TestSqliteRepoFilter(t *testing.T) {
+	ctx := context.Background()
+
+	sqlb := InitTestDB(t)
+	cleanup := func() { CleanupTestDB(sqlb) }
+	t.Cleanup(cleanup)
+
+	vlcCommit := &common.Commit{
+		Id:       "1c915e7dd147d4b060c2c241bb966d6f6c6ecde9",
+		RepoName: "vlc",
+		Author:   common.Person{Name: "Claudio Cambra", Email: "developer@claudiocambra.com"},
+	}
+	otherCommit := &common.Commit{
+		Id:       "4610c5caa1b48f113ee87f48aeace2846a474957",
+		RepoName: "commit-analysis-tool",
+		Author:   common.Person{Name: "Claudio Cambra", Email: "developer@claudiocambra.com"},
+	}
+
+	if err := sqlb.AddCommits(ctx, []*common.Commit{vlcCommit, otherCommit}); err != nil {
+		t.Fatalf("Error adding commits: %s", err)
+	}
+
+	allCommits, err := sqlb.Commits(ctx, "")
+	if err != nil {
+		t.Fatalf("Error retrieving commits: %s", err)
+	}
+
+	CompareCommitArrays(t, []*common.Commit{vlcCommit, otherCommit}, allCommits)
+
+	vlcCommits, err := sqlb.Commits(ctx, "vlc")
+	if err != nil {
+		t.Fatalf("Error retrieving repository commits: %s", err)
+	}
+
+	CompareCommitArrays(t, []*common.Commit{vlcCommit}, vlcCommits)
+
+	vlcAuthorCommits, err := sqlb.AuthorCommits(ctx, vlcCommit.Author.Email, "vlc")
+	if err != nil {
+		t.Fatalf("Error retrieving repository author commits: %s", err)
+	}
+
+	CompareCommitArrays(t, []*common.Commit{vlcCommit}, vlcAuthorCommits)
+
+	missingRepoAuthors, err := sqlb.Authors(ctx, "missing")
+	if err != nil {
+		t.Fatalf("Error retrieving repository authors: %s", err)
+	} else if len(missingRepoAuthors) != 0 {
+		t.Fatalf("Received authors for a repository without commits: %+v", missingRepoAuthors)
+	}
+}
+
+func TestSqliteSharedCommits(t *testing.T) {
+	ctx := context.Background()
+
+	sqlb := InitTestDB(t)
+	cleanup := func() { CleanupTestDB(sqlb) }
+	t.Cleanup(cleanup)
+
+	sharedCommit := &common.Commit{Id: "1c915e7dd147d4b060c2c241bb966d6f6c6ecde9", RepoName: "vlc"}
+	forkCommit := &common.Commit{Id: "4610c5caa1b48f113ee87f48aeace2846a474957", RepoName: "vlc-fork"}
+	forkSharedCommit := *sharedCommit
+	forkSharedCommit.RepoName = "vlc-fork"
+
+	if _, err := sqlb.IngestCommits(ctx, common.NewCommitSliceIterator([]*common.Commit{sharedCommit})); err != nil {
+		t.Fatalf("Error ingesting commits: %s", err)
+	}
+
+	numAdded, err := sqlb.IngestCommits(ctx, common.NewCommitSliceIterator([]*common.Commit{&forkSharedCommit, forkCommit}))
+	if err != nil {
+		t.Fatalf("Error ingesting fork commits: %s", err)
+	} else if numAdded != 2 {
+		t.Fatalf("Unexpected number of fork commits added: expected 2, received %d", numAdded)
+	}
+
+	commitRepos, err := sqlb.CommitRepos(ctx, sharedCommit.Id)
+	if err != nil {
+		t.Fatalf("Error retrieving commit repositories: %s", err)
+	}
+
+	expectedCommitRepos := []string{"vlc", "vlc-fork"}
+	if !cmp.Equal(expectedCommitRepos, commitRepos) {
+		t.Fatalf("Shared commit repositories do not match expected repositories. %s", cmp.Diff(expectedCommitRepos, commitRepos))
+	}
+
+	retrievedCommit, err := sqlb.Commit(ctx, sharedCommit.Id)
+	if err != nil {
+		t.Fatalf("Error retrieving shared commit: %s", err)
+	} else if !cmp.Equal(sharedCommit, retrievedCommit) {
+		t.Fatalf("Shared commit does not equal expected commit. %s", cmp.Diff(sharedCommit, retrievedCommit))
+	}
+
+	forkCommits, err := sqlb.Commits(ctx, "vlc-fork")
+	if err != nil {
+		t.Fatalf("Error retrieving fork commits: %s", err)
+	}
+
+	CompareCommitArrays(t, []*common.Commit{&forkSharedCommit, forkCommit}, forkCommits)
+
+	expectedCounts := []struct {
+		repoName     string
+		countPerRepo bool
+		count        int
+	}{
+		{"", false, 2},
+		{"", true, 3},
+		{"vlc", false, 1},
+		{"vlc-fork", true, 2},
+	}
+
+	for _, expected := range expectedCounts {
+		count, err := sqlb.CommitCount(ctx, expected.repoName, expected.countPerRepo)
+		if err != nil {
+			t.Fatalf("Error counting commits: %s", err)
+		} else if count != expected.count {
+			t.Fatalf("Unexpected commit count for %+v: received %d", expected, count)
+		}
+	}
+}
+
+func TestSqliteTrailers(t *testing.T) {
+	ctx := context.Background()
+
+	sqlb := InitTestDB(t)
+	cleanup := func() { CleanupTestDB(sqlb) }
+	t.Cleanup(cleanup)
+
+	coAuthoredCommit := &common.Commit{
+		Id:     "1c915e7dd147d4b060c2c241bb966d6f6c6ecde9",
+		Author: common.Person{Name: "Claudio Cambra", Email: "developer@claudiocambra.com"},
+		Trailers: []*common.Trailer{
+			{
+				Kind:   common.CoAuthoredByTrailer,
+				Key:    "Co-authored-by",
+				Value:  "Jean-Baptiste Kempf <jb@videolan.org>",
+				Person: common.Person{Name: "Jean-Baptiste Kempf", Email: "jb@videolan.org"},
+			},
+			{
+				Kind:  common.OtherTrailer,
+				Key:   "Fixes",
+				Value: "#1234",
+			},
+		},
+	}
+	signedOffCommit := &common.Commit{
+		Id:     "4610c5caa1b48f113ee87f48aeace2846a474957",
+		Author: common.Person{Name: "Claudio Cambra", Email: "developer@claudiocambra.com"},
+		Trailers: []*common.Trailer{
+			{
+				Kind:   common.SignedOffByTrailer,
+				Key:    "Signed-off-by",
+				Value:  "Claudio Cambra <developer@claudiocambra.com>",
+				Person: common.Person{Name: "Claudio Cambra", Email: "developer@claudiocambra.com"},
+			},
+		},
+	}
+
+	// Adding a commit twice should not duplicate its trailers
+	for _, commit := range []*common.Commit{coAuthoredCommit, signedOffCommit, coAuthoredCommit} {
+		if err := sqlb.AddCommit(ctx, commit); err != nil {
+			t.Fatalf("Error adding commit: %s", err)
+		}
+	}
+
+	retrievedCommit, err := sqlb.Commit(ctx, coAuthoredCommit.Id)
+	if err != nil {
+		t.Fatalf("Error during commit retrieval: %s", err)
+	} else if !cmp.Equal(coAuthoredCommit, retrievedCommit) {
+		t.Fatalf("Database commit does not equal expected commit. %s", cmp.Diff(coAuthoredCommit, retrievedCommit))
+	}
+
+	coAuthoredCommits, err := sqlb.CoAuthoredCommits(ctx, "")
+	if err != nil {
+		t.Fatalf("Error during co-authored commits retrieval: %s", err)
+	}
+
+	CompareCommitArrays(t, []*common.Commit{coAuthoredCommit}, coAuthoredCommits)
+}
+
+func TestSqliteMergeCommits(t *testing.T) {
+	ctx := context.Background()
+
+	sqlb := InitTestDB(t)
+	cleanup := func() { CleanupTestDB(sqlb) }
+	t.Cleanup(cleanup)
+
+	rootCommit := &common.Commit{Id: "1c915e7dd147d4b060c2c241bb966d6f6c6ecde9", RepoName: "vlc"}
+	branchCommit := &common.Commit{
+		Id:        "4610c5caa1b48f113ee87f48aeace2846a474957",
+		RepoName:  "vlc",
+		ParentIds: []string{rootCommit.Id},
+	}
+	mergeCommit := &common.Commit{
+		Id:        "93367dcd81d5a5709f53abd78054fb444cd9af2f",
+		RepoName:  "vlc",
+		ParentIds: []string{rootCommit.Id, branchCommit.Id},
+	}
+
+	commits := []*common.Commit{rootCommit, branchCommit, mergeCommit}
+	if _, err := sqlb.IngestCommits(ctx, common.NewCommitSliceIterator(commits)); err != nil {
+		t.Fatalf("Error ingesting commits: %s", err)
+	}
+
+	retrievedCommits, err := sqlb.Commits(ctx, "vlc")
+	if err != nil {
+		t.Fatalf("Error during commits retrieval: %s", err)
+	}
+
+	CompareCommitArrays(t, commits, retrievedCommits)
+
+	mergeCommits, err := sqlb.MergeCommits(ctx, "vlc")
+	if err != nil {
+		t.Fatalf("Error during merge commits retrieval: %s", err)
+	}
+
+	CompareCommitArrays(t, []*common.Commit{mergeCommit}, mergeCommits)
+}
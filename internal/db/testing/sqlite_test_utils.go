@@ -1,6 +1,7 @@
 package dbtesting
 
 import (
+	"context"
 	"log"
 	"os"
 	"path/filepath"
@@ -19,6 +20,8 @@ var testDir = ""
 var TestLogFilePath = "../../../test/data/log.txt"
 
 func InitTestDB(t *testing.T) *db.SQLiteBackend {
+	ctx := context.Background()
+
 	testDir, err := os.MkdirTemp("", testDirName)
 	if err != nil {
 		t.Fatalf("Could not create temp test dir, received error: %s", err)
@@ -29,19 +32,13 @@ func InitTestDB(t *testing.T) *db.SQLiteBackend {
 
 	log.Printf("Setting up test database at %s\n", testDir)
 	var sqlb = new(db.SQLiteBackend)
-	err = sqlb.Open(testDbPath)
+	err = sqlb.Open(ctx, testDbPath)
 
 	if err != nil {
 		t.Fatalf("Could not open database: %s", err)
 		return nil
 	}
 
-	err = sqlb.Setup()
-	if err != nil {
-		t.Fatalf("Could not setup database: %s", err)
-		return nil
-	}
-
 	return sqlb
 }
 
@@ -65,14 +62,16 @@ func ParsedTestCommitLog(t *testing.T) []*common.Commit {
 }
 
 func IngestTestCommits(sqlb *db.SQLiteBackend, t *testing.T) []*common.Commit {
+	ctx := context.Background()
+
 	parsedCommitLog := ParsedTestCommitLog(t)
 
-	err := sqlb.AddCommits(parsedCommitLog)
+	err := sqlb.AddCommits(ctx, parsedCommitLog)
 	if err != nil {
 		t.Fatalf("Error during test log file ingest: %s", err)
 	}
 
-	parsedCommits, err := sqlb.Commits()
+	parsedCommits, err := sqlb.Commits(ctx, "")
 	if err != nil {
 		t.Fatalf("Error checking ingested commits: %s", err)
 	}
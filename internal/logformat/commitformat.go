@@ -1,29 +1,39 @@
 package logformat
 
 import (
-	"fmt"
 	"strings"
+
+	"github.com/claucambra/commit-analysis-tool/internal/commitfields"
 )
 
 const PrettyFormatStringStart = "PRETTYFORMATSTART__"
 const PrettyFormatStringSeparator = "__SEPARATOR__"
 const PrettyFormatStringEnd = "__PRETTYFORMATEND"
 
+// Placeholders of the fields in commitfields.Fields, between the start and end markers
 func PrettyFormatString() string {
-	return fmt.Sprintf("%s%%H%s%%aD%s%%aN%s%%aE%s%%cD%s%%cN%s%%cE%s%%s%s%%b%s",
-		PrettyFormatStringStart,
-		PrettyFormatStringSeparator,
-		PrettyFormatStringSeparator,
-		PrettyFormatStringSeparator,
-		PrettyFormatStringSeparator,
-		PrettyFormatStringSeparator,
-		PrettyFormatStringSeparator,
-		PrettyFormatStringSeparator,
-		PrettyFormatStringSeparator,
-		PrettyFormatStringEnd)
+	placeholders := []string{}
+	for _, field := range commitfields.PrettyFormatFields() {
+		placeholders = append(placeholders, field.Placeholder)
+	}
+
+	return PrettyFormatStringStart +
+		strings.Join(placeholders, PrettyFormatStringSeparator) +
+		PrettyFormatStringEnd
 }
 
 func PrettyFormatStringParameterCount() int {
-	splitPrettyFormat := strings.Split(PrettyFormatString(), PrettyFormatStringSeparator)
-	return len(splitPrettyFormat)
+	return len(commitfields.PrettyFormatFields())
+}
+
+// Parameter count of logs written before the optional fields were part of the pretty format
+func LegacyPrettyFormatStringParameterCount() int {
+	count := 0
+	for _, field := range commitfields.PrettyFormatFields() {
+		if !field.Optional {
+			count++
+		}
+	}
+
+	return count
 }
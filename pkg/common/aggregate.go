@@ -0,0 +1,145 @@
+package common
+
+import (
+	"sort"
+	"time"
+)
+
+// What aggregate queries can group commits by
+type AggregateDimension int
+
+const (
+	// Domain of the author's email, see EmailDomain
+	AggregateByDomain AggregateDimension = iota
+	// Author's email
+	AggregateByAuthor
+	// Year of the author time, in UTC
+	AggregateByYear
+	// Year and month of the author time, in UTC
+	AggregateByMonth
+	// Repository the commits are part of. Commits that are part of several repositories are
+	// counted in each of them.
+	AggregateByRepo
+)
+
+// Totals of the commits sharing the same values of the dimensions they were grouped by. The
+// fields of dimensions that were not grouped by are left empty.
+type Aggregate struct {
+	Domain      string
+	AuthorEmail string
+	Year        int
+	Month       int
+	RepoName    string
+
+	NumCommits int
+	// Number of distinct authors of the commits
+	NumAuthors int
+	LineChanges
+}
+
+// Key of an aggregate's dimension values, to tell aggregates apart
+type aggregateKey struct {
+	domain      string
+	authorEmail string
+	year        int
+	month       int
+	repoName    string
+}
+
+// Groups commits by the dimensions in memory, like aggregate queries do in SQL. repoNames returns
+// the repositories a commit is part of, for AggregateByRepo. Aggregates are sorted by their
+// dimension values, in the order of the dimensions. Without dimensions, a single aggregate of all
+// commits is returned.
+func AggregateCommits(commits []*Commit, repoNames func(*Commit) []string, dimensions ...AggregateDimension) []*Aggregate {
+	byDimension := map[AggregateDimension]bool{}
+	for _, dimension := range dimensions {
+		byDimension[dimension] = true
+	}
+
+	aggregates := map[aggregateKey]*Aggregate{}
+	aggregateAuthors := map[aggregateKey]EmailSet{}
+	keys := []aggregateKey{}
+
+	add := func(commit *Commit, repoName string) {
+		key := aggregateKey{}
+		if byDimension[AggregateByDomain] {
+			key.domain = commit.Author.Domain()
+		}
+		if byDimension[AggregateByAuthor] {
+			key.authorEmail = commit.Author.Email
+		}
+		authorTime := time.Unix(commit.AuthorTime, 0).UTC()
+		if byDimension[AggregateByYear] || byDimension[AggregateByMonth] {
+			key.year = authorTime.Year()
+		}
+		if byDimension[AggregateByMonth] {
+			key.month = int(authorTime.Month())
+		}
+		key.repoName = repoName
+
+		aggregate, ok := aggregates[key]
+		if !ok {
+			aggregate = &Aggregate{
+				Domain:      key.domain,
+				AuthorEmail: key.authorEmail,
+				Year:        key.year,
+				Month:       key.month,
+				RepoName:    key.repoName,
+			}
+			aggregates[key] = aggregate
+			aggregateAuthors[key] = EmailSet{}
+			keys = append(keys, key)
+		}
+
+		aggregate.NumCommits++
+		aggregate.NumInsertions += commit.NumInsertions
+		aggregate.NumDeletions += commit.NumDeletions
+		aggregateAuthors[key][commit.Author.Email] = true
+		aggregate.NumAuthors = len(aggregateAuthors[key])
+	}
+
+	for _, commit := range commits {
+		if !byDimension[AggregateByRepo] {
+			add(commit, "")
+			continue
+		}
+
+		for _, repoName := range repoNames(commit) {
+			add(commit, repoName)
+		}
+	}
+
+	if len(dimensions) == 0 && len(keys) == 0 {
+		return []*Aggregate{{}}
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].less(keys[j], dimensions)
+	})
+
+	sortedAggregates := make([]*Aggregate, len(keys))
+	for i, key := range keys {
+		sortedAggregates[i] = aggregates[key]
+	}
+
+	return sortedAggregates
+}
+
+func (key aggregateKey) less(otherKey aggregateKey, dimensions []AggregateDimension) bool {
+	for _, dimension := range dimensions {
+		switch {
+		case dimension == AggregateByDomain && key.domain != otherKey.domain:
+			return key.domain < otherKey.domain
+		case dimension == AggregateByAuthor && key.authorEmail != otherKey.authorEmail:
+			return key.authorEmail < otherKey.authorEmail
+		case (dimension == AggregateByYear || dimension == AggregateByMonth) && key.year != otherKey.year:
+			return key.year < otherKey.year
+		case dimension == AggregateByMonth && key.month != otherKey.month:
+			return key.month < otherKey.month
+		case dimension == AggregateByRepo && key.repoName != otherKey.repoName:
+			return key.repoName < otherKey.repoName
+		}
+	}
+
+	return false
+}
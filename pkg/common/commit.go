@@ -19,6 +19,16 @@ type Commit struct {
 	CommitterTime int64
 	Subject       string
 	Body          string
+	FileChanges   []*FileChange
+	Trailers      []*Trailer
+
+	// Hashes of the parent commits, first parent first. Empty for root commits and for commits
+	// read from logs that did not record parents.
+	ParentIds []string
+}
+
+func (commit *Commit) IsMerge() bool {
+	return len(commit.ParentIds) > 1
 }
 
 type CommitMap map[string]*Commit
@@ -0,0 +1,224 @@
+package common
+
+import (
+	"sort"
+	"time"
+)
+
+// What commit queries can order commits by
+type CommitOrder int
+
+const (
+	// Order the commits were added in
+	OrderByIngest CommitOrder = iota
+	OrderByAuthorTime
+	OrderByCommitterTime
+	// Number of inserted and deleted lines
+	OrderByLineChanges
+)
+
+// Number of commits iterators read at a time when a query does not set a page size
+const DefaultCommitPageSize = 1000
+
+// Filters, order and limits of a commit query, run with store.Store.QueryCommits. Empty fields
+// do not filter. Queries can be written as literals or built up with the With methods, e.g.
+//
+//	NewCommitQuery().WithAuthorDomain("videolan.org", true).WithLimit(10)
+type CommitQuery struct {
+	// Only commits of this repository, or of all repositories if empty
+	RepoName string
+
+	AuthorEmail    string
+	CommitterEmail string
+	// Domains match exactly (ignoring case) unless IncludeSubdomains is set, see DomainMatches
+	AuthorDomain      string
+	CommitterDomain   string
+	IncludeSubdomains bool
+
+	// Commits authored at or after Since and at or before Until
+	Since time.Time
+	Until time.Time
+
+	// Bounds of the number of inserted and deleted lines, inclusive
+	MinLineChanges *int
+	MaxLineChanges *int
+
+	// Full-text query the subject or body must match, see store.Store.SearchCommits
+	Text string
+
+	Order      CommitOrder
+	Descending bool
+
+	// Number of commits to skip, and the maximum number of commits to return if not 0
+	Offset int
+	Limit  int
+	// Number of commits read at a time, DefaultCommitPageSize if not set. Stores may look up
+	// full-text matches again for every page, so text queries matching many commits read faster
+	// with larger pages.
+	PageSize int
+
+	// Also read the file changes, trailers and parents of the commits
+	WithDetails bool
+}
+
+func NewCommitQuery() *CommitQuery {
+	return &CommitQuery{}
+}
+
+func (query *CommitQuery) WithRepo(repoName string) *CommitQuery {
+	query.RepoName = repoName
+	return query
+}
+
+func (query *CommitQuery) WithAuthorEmail(email string) *CommitQuery {
+	query.AuthorEmail = email
+	return query
+}
+
+func (query *CommitQuery) WithCommitterEmail(email string) *CommitQuery {
+	query.CommitterEmail = email
+	return query
+}
+
+func (query *CommitQuery) WithAuthorDomain(domain string, includeSubdomains bool) *CommitQuery {
+	query.AuthorDomain = domain
+	query.IncludeSubdomains = includeSubdomains
+	return query
+}
+
+func (query *CommitQuery) WithCommitterDomain(domain string, includeSubdomains bool) *CommitQuery {
+	query.CommitterDomain = domain
+	query.IncludeSubdomains = includeSubdomains
+	return query
+}
+
+// Zero times leave that end of the range open
+func (query *CommitQuery) WithAuthorTimeRange(since time.Time, until time.Time) *CommitQuery {
+	query.Since = since
+	query.Until = until
+	return query
+}
+
+// Negative bounds leave that end of the range open
+func (query *CommitQuery) WithLineChangesRange(min int, max int) *CommitQuery {
+	query.MinLineChanges = nil
+	query.MaxLineChanges = nil
+
+	if min >= 0 {
+		query.MinLineChanges = &min
+	}
+
+	if max >= 0 {
+		query.MaxLineChanges = &max
+	}
+
+	return query
+}
+
+func (query *CommitQuery) WithText(text string) *CommitQuery {
+	query.Text = text
+	return query
+}
+
+func (query *CommitQuery) WithOrder(order CommitOrder, descending bool) *CommitQuery {
+	query.Order = order
+	query.Descending = descending
+	return query
+}
+
+func (query *CommitQuery) WithOffset(offset int) *CommitQuery {
+	query.Offset = offset
+	return query
+}
+
+func (query *CommitQuery) WithLimit(limit int) *CommitQuery {
+	query.Limit = limit
+	return query
+}
+
+func (query *CommitQuery) WithPageSize(pageSize int) *CommitQuery {
+	query.PageSize = pageSize
+	return query
+}
+
+func (query *CommitQuery) WithCommitDetails() *CommitQuery {
+	query.WithDetails = true
+	return query
+}
+
+func (query *CommitQuery) PageSizeOrDefault() int {
+	if query.PageSize > 0 {
+		return query.PageSize
+	}
+
+	return DefaultCommitPageSize
+}
+
+// Whether the commit passes the query's filters other than the repository and the text, which
+// need the store's records
+func (query *CommitQuery) Matches(commit *Commit) bool {
+	if query.AuthorEmail != "" && commit.Author.Email != query.AuthorEmail {
+		return false
+	} else if query.CommitterEmail != "" && commit.Committer.Email != query.CommitterEmail {
+		return false
+	} else if query.AuthorDomain != "" && !DomainMatches(commit.Author.Domain(), query.AuthorDomain, query.IncludeSubdomains) {
+		return false
+	} else if query.CommitterDomain != "" && !DomainMatches(commit.Committer.Domain(), query.CommitterDomain, query.IncludeSubdomains) {
+		return false
+	} else if !query.Since.IsZero() && commit.AuthorTime < query.Since.Unix() {
+		return false
+	} else if !query.Until.IsZero() && commit.AuthorTime > query.Until.Unix() {
+		return false
+	}
+
+	lineChanges := commit.NumInsertions + commit.NumDeletions
+	if query.MinLineChanges != nil && lineChanges < *query.MinLineChanges {
+		return false
+	} else if query.MaxLineChanges != nil && lineChanges > *query.MaxLineChanges {
+		return false
+	}
+
+	return true
+}
+
+// Value of the commit the order sorts by
+func (order CommitOrder) Key(commit *Commit) int64 {
+	switch order {
+	case OrderByAuthorTime:
+		return commit.AuthorTime
+	case OrderByCommitterTime:
+		return commit.CommitterTime
+	case OrderByLineChanges:
+		return int64(commit.NumInsertions + commit.NumDeletions)
+	}
+
+	return 0
+}
+
+// Sorts commits given in the order they were added into the query's order, keeping commits with
+// equal keys in the order they were added (reversed when descending)
+func (query *CommitQuery) SortCommits(commits []*Commit) {
+	if query.Descending {
+		for i, j := 0, len(commits)-1; i < j; i, j = i+1, j-1 {
+			commits[i], commits[j] = commits[j], commits[i]
+		}
+	}
+
+	sort.SliceStable(commits, func(i, j int) bool {
+		if query.Descending {
+			return query.Order.Key(commits[i]) > query.Order.Key(commits[j])
+		}
+
+		return query.Order.Key(commits[i]) < query.Order.Key(commits[j])
+	})
+}
+
+// Reads all commits of the iterator
+func CollectCommits(commits CommitIterator) ([]*Commit, error) {
+	collected := []*Commit{}
+	for commits.Next() {
+		collected = append(collected, commits.Commit())
+	}
+
+	return collected, commits.Err()
+}
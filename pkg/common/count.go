@@ -83,15 +83,29 @@ func EqualiseYearMonths(ymc1 YearMonthCount, ymc2 YearMonthCount) (YearMonthCoun
 	return filteredYmc1, filteredYmc2
 }
 
-func CorrelateYearMonthCounts(ymc1 YearMonthCount, ymc2 YearMonthCount) float64 {
+// Monthly values of two YMCs over the months they have in common, see EqualiseYearMonths, as series
+// of the same length to compare. Both are empty if the YMCs have no years in common.
+func EqualisedYearMonthSeries(ymc1 YearMonthCount, ymc2 YearMonthCount) ([]float64, []float64) {
 	filteredYmc1, filteredYmc2 := EqualiseYearMonths(ymc1, ymc2)
 
 	if filteredYmc1 == nil || filteredYmc2 == nil {
-		return math.NaN()
+		return []float64{}, []float64{}
 	}
 
 	flatFloatFilteredYmc1 := SliceIntToFloat[int, float64](filteredYmc1.Flatten())
 	flatFloatFilteredYmc2 := SliceIntToFloat[int, float64](filteredYmc2.Flatten())
 
-	return stat.Correlation(flatFloatFilteredYmc1, flatFloatFilteredYmc2, nil)
+	return flatFloatFilteredYmc1, flatFloatFilteredYmc2
+}
+
+// Pearson correlation of the same months of both YMCs, see statistics.CrossCorrelate to compare
+// them at different lags or otherwise
+func CorrelateYearMonthCounts(ymc1 YearMonthCount, ymc2 YearMonthCount) float64 {
+	series1, series2 := EqualisedYearMonthSeries(ymc1, ymc2)
+
+	if len(series1) == 0 {
+		return math.NaN()
+	}
+
+	return stat.Correlation(series1, series2, nil)
 }
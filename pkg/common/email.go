@@ -1,5 +1,7 @@
 package common
 
+import "strings"
+
 type EmailSet map[string]bool
 type YearlyEmailMap map[int]EmailSet
 
@@ -59,3 +61,27 @@ func (yem *YearlyEmailMap) SubtractYearlyEmailMap(yemToSubtract YearlyEmailMap)
 		yem.SubtractEmailSet(emailsToSubtract, year)
 	}
 }
+
+// Lower-cased domain of an email address, i.e. what follows its last "@". Empty if the address has
+// no "@".
+func EmailDomain(email string) string {
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return ""
+	}
+
+	return strings.ToLower(email[at+1:])
+}
+
+// Whether an email domain is the given domain or, with includeSubdomains, one of its subdomains.
+// Domains are compared case-insensitively.
+func DomainMatches(emailDomain string, domain string, includeSubdomains bool) bool {
+	emailDomain = strings.ToLower(emailDomain)
+	domain = strings.ToLower(domain)
+
+	if emailDomain == domain {
+		return true
+	}
+
+	return includeSubdomains && strings.HasSuffix(emailDomain, "."+domain)
+}
@@ -169,3 +169,41 @@ func TestSubtractYearlyEmailMapToYearlyEmailMap(t *testing.T) {
 		t.Fatalf(`Subtracted YLCM B email set to yearly email set map does not match expected changes: %s`, cmp.Diff(expectedSubbedEmailSets, testYearBSubEmailSet))
 	}
 }
+
+func TestEmailDomain(t *testing.T) {
+	emailDomains := map[string]string{
+		"developer@IBM.com":         "ibm.com",
+		"bob.ibm.com@gmail.com":     "gmail.com",
+		"odd@name@research.ibm.com": "research.ibm.com",
+		"no-domain":                 "",
+	}
+
+	for email, expectedDomain := range emailDomains {
+		if domain := EmailDomain(email); domain != expectedDomain {
+			t.Fatalf("Unexpected domain %q of %s, expected %q", domain, email, expectedDomain)
+		}
+	}
+}
+
+func TestDomainMatches(t *testing.T) {
+	tests := []struct {
+		emailDomain       string
+		domain            string
+		includeSubdomains bool
+		expected          bool
+	}{
+		{"ibm.com", "ibm.com", false, true},
+		{"ibm.com", "IBM.com", false, true},
+		{"notibm.com", "ibm.com", false, false},
+		{"notibm.com", "ibm.com", true, false},
+		{"research.ibm.com", "ibm.com", false, false},
+		{"research.ibm.com", "ibm.com", true, true},
+		{"ibm.com.evil.org", "ibm.com", true, false},
+	}
+
+	for _, test := range tests {
+		if matches := DomainMatches(test.emailDomain, test.domain, test.includeSubdomains); matches != test.expected {
+			t.Fatalf("Unexpected match of %s against %s (subdomains: %t): %t", test.emailDomain, test.domain, test.includeSubdomains, matches)
+		}
+	}
+}
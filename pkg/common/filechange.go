@@ -0,0 +1,29 @@
+package common
+
+import (
+	"path"
+	"strings"
+)
+
+// Changes made to a single file within a commit
+type FileChange struct {
+	LineChanges
+
+	Path         string
+	PreviousPath string // Path the file was renamed from, empty if the file was not renamed
+	Binary       bool
+}
+
+func (fc *FileChange) Renamed() bool {
+	return fc.PreviousPath != "" && fc.PreviousPath != fc.Path
+}
+
+// Directory containing the file, "." for files at the root of the repository
+func (fc *FileChange) Directory() string {
+	return path.Dir(fc.Path)
+}
+
+// Lower-cased file extension without the leading dot, used to group changes by file type
+func (fc *FileChange) Extension() string {
+	return strings.ToLower(strings.TrimPrefix(path.Ext(fc.Path), "."))
+}
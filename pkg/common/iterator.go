@@ -0,0 +1,44 @@
+package common
+
+// Iterates over a sequence of commits one at a time without holding the whole sequence in memory.
+// Usage follows bufio.Scanner: call Next until it returns false, then check Err.
+type CommitIterator interface {
+	Next() bool
+	Commit() *Commit
+	Err() error
+}
+
+// CommitIterator over commits that are already in memory
+type CommitSliceIterator struct {
+	commits []*Commit
+	index   int
+}
+
+func NewCommitSliceIterator(commits []*Commit) *CommitSliceIterator {
+	return &CommitSliceIterator{
+		commits: commits,
+		index:   -1,
+	}
+}
+
+func (csi *CommitSliceIterator) Next() bool {
+	if csi.index+1 >= len(csi.commits) {
+		csi.index = len(csi.commits)
+		return false
+	}
+
+	csi.index++
+	return true
+}
+
+func (csi *CommitSliceIterator) Commit() *Commit {
+	if csi.index < 0 || csi.index >= len(csi.commits) {
+		return nil
+	}
+
+	return csi.commits[csi.index]
+}
+
+func (csi *CommitSliceIterator) Err() error {
+	return nil
+}
@@ -4,3 +4,8 @@ type Person struct {
 	Name  string
 	Email string
 }
+
+// Lower-cased domain of the person's email, see EmailDomain
+func (person Person) Domain() string {
+	return EmailDomain(person.Email)
+}
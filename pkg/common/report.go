@@ -1,5 +1,9 @@
 package common
 
+import "context"
+
+// Report generated from stored commits. Generate returns the first error it encounters, including
+// the context's error once it is cancelled.
 type Report interface {
-	Generate()
+	Generate(ctx context.Context) error
 }
@@ -0,0 +1,46 @@
+package common
+
+import (
+	"net/url"
+	"path"
+	"strings"
+)
+
+// A git repository whose commits have been ingested
+type Repository struct {
+	Name            string
+	OriginUrl       string
+	LocalPath       string
+	DefaultBranch   string
+	FirstIngestTime int64
+	LastIngestTime  int64
+}
+
+// Derives a repository name from a clone url, made of the host and the path of the repository on
+// it, e.g. https://code.videolan.org/videolan/vlc.git -> code.videolan.org/videolan/vlc. Names
+// identify repositories in the database, so repositories of the same name on different hosts or
+// of different owners are kept apart, while https and ssh clones of a repository share its name.
+func RepoNameFromUrl(cloneUrl string) string {
+	cloneUrl = strings.TrimSpace(cloneUrl)
+	host, repoPath := "", cloneUrl
+
+	if parsedUrl, err := url.Parse(cloneUrl); err == nil && parsedUrl.Scheme != "" {
+		host, repoPath = parsedUrl.Hostname(), parsedUrl.Path
+	} else if colon := strings.Index(cloneUrl, ":"); colon > 0 && !strings.Contains(cloneUrl[:colon], "/") {
+		// scp-like syntax of ssh urls, e.g. git@github.com:claucambra/commit-analysis-tool.git
+		host, repoPath = cloneUrl[:colon], cloneUrl[colon+1:]
+		if at := strings.LastIndex(host, "@"); at >= 0 {
+			host = host[at+1:]
+		}
+	}
+
+	repoPath = strings.Trim(path.Clean("/"+repoPath), "/")
+	repoPath = strings.TrimSuffix(repoPath, ".git")
+	if repoPath == "" {
+		return ""
+	} else if host == "" {
+		return repoPath
+	}
+
+	return strings.ToLower(host) + "/" + repoPath
+}
@@ -0,0 +1,51 @@
+package common
+
+import "strings"
+
+type TrailerKind string
+
+const (
+	SignedOffByTrailer  TrailerKind = "signed-off-by"
+	CoAuthoredByTrailer TrailerKind = "co-authored-by"
+	ReviewedByTrailer   TrailerKind = "reviewed-by"
+	AckedByTrailer      TrailerKind = "acked-by"
+	TestedByTrailer     TrailerKind = "tested-by"
+	OtherTrailer        TrailerKind = "other"
+)
+
+// A "Key: value" line at the end of a commit message, e.g. "Co-authored-by: Name <email>"
+type Trailer struct {
+	Kind  TrailerKind
+	Key   string // As written in the commit message
+	Value string
+
+	// Set when the value names a person in the usual "Name <email>" form
+	Person Person
+}
+
+func TrailerKindForKey(key string) TrailerKind {
+	switch kind := TrailerKind(strings.ToLower(key)); kind {
+	case SignedOffByTrailer, CoAuthoredByTrailer, ReviewedByTrailer, AckedByTrailer, TestedByTrailer:
+		return kind
+	default:
+		return OtherTrailer
+	}
+}
+
+// Emails of the co-authors credited in a commit's trailers, excluding the commit's author
+func (commit *Commit) CoAuthorEmails() []string {
+	coAuthorEmails := []string{}
+
+	for _, trailer := range commit.Trailers {
+		email := trailer.Person.Email
+		if trailer.Kind != CoAuthoredByTrailer || email == "" || strings.EqualFold(email, commit.Author.Email) {
+			continue
+		}
+
+		if found, _ := SliceContains(coAuthorEmails, email); !found {
+			coAuthorEmails = append(coAuthorEmails, email)
+		}
+	}
+
+	return coAuthorEmails
+}
@@ -5,46 +5,27 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
-	"time"
 
+	"github.com/claucambra/commit-analysis-tool/internal/commitfields"
 	"github.com/claucambra/commit-analysis-tool/internal/logformat"
 	"github.com/claucambra/commit-analysis-tool/pkg/common"
 )
 
-const splitterString = "__SPLITATME__"
-
 var numberRegex = regexp.MustCompile("[0-9]+")
 var insertionsRegex = regexp.MustCompile("([0-9]+) insertions?")
 var deletionsRegex = regexp.MustCompile("([0-9]+) deletions?")
-var filesChangedRegex = regexp.MustCompile("([0-9]+) files changed?")
-var prettyLogLineRegex = regexp.MustCompile(fmt.Sprintf("%s([\\s\\S]*?)%s", logformat.PrettyFormatStringStart, logformat.PrettyFormatStringEnd))
+var filesChangedRegex = regexp.MustCompile("([0-9]+) files? changed")
 
 func ParseCommitLog(commitLog string) ([]*common.Commit, error) {
-	prettyFormatLines := prettyLogLineRegex.FindAllString(commitLog, -1)
-	statLinesString := prettyLogLineRegex.ReplaceAllString(commitLog, splitterString)
-	// We want to use the pretty log lines in this case as a separator; if we don't remove the first
-	// one then we will have an empty string at the first index of statLines
-	statLinesString = strings.Replace(statLinesString, splitterString, "", 1)
-	statLines := strings.Split(statLinesString, splitterString)
-
-	prettyFormatLineCount := len(prettyFormatLines)
-	statLineCount := len(statLines)
-
-	if prettyFormatLineCount != statLineCount {
-		return nil, fmt.Errorf("mismatched pretty log lines (%+v) and stat lines (%+v)", prettyFormatLineCount, statLineCount)
-	}
-
-	parsedCommits := make([]*common.Commit, prettyFormatLineCount)
+	scanner := NewCommitScanner(strings.NewReader(commitLog))
+	parsedCommits := []*common.Commit{}
 
-	for i := 0; i < prettyFormatLineCount; i++ {
-		commitString := prettyFormatLines[i] + statLines[i]
-		parsedCommit, err := ParseCommit(commitString)
-
-		if err != nil {
-			return nil, err
-		}
+	for scanner.Next() {
+		parsedCommits = append(parsedCommits, scanner.Commit())
+	}
 
-		parsedCommits[i] = parsedCommit
+	if err := scanner.Err(); err != nil {
+		return nil, err
 	}
 
 	return parsedCommits, nil
@@ -70,6 +51,7 @@ func ParseCommitLog(commitLog string) ([]*common.Commit, error) {
 
  **/
 
+// Errors are returned as *ParseError
 func ParseCommit(rawCommit string) (*common.Commit, error) {
 	commitLogLines := strings.Split(rawCommit, logformat.PrettyFormatStringEnd)
 	prettyLogLine := commitLogLines[0]
@@ -81,12 +63,14 @@ func ParseCommit(rawCommit string) (*common.Commit, error) {
 
 	commit, err := parsePrettyLogLine(prettyLogLine)
 	if err != nil {
-		return nil, err
+		return nil, newParseError(rawCommit, err)
 	}
 
 	commit.NumInsertions = insertions
 	commit.NumDeletions = deletions
 	commit.NumFilesChanged = filesChanged
+	commit.FileChanges = parseFileChanges(changesLogLine)
+	commit.Trailers = ParseTrailers(commit.Body)
 
 	return commit, nil
 }
@@ -112,16 +96,17 @@ func parseChangesLine(changesLogLine string, specificChangesRegex *regexp.Regexp
 
 /**
  * Parse the git pretty format log line.
- * This is heavily influenced by the format of the pretty format. Look at PrettyFormat for further
- * details on this.
+ * Values are parsed by the fields in commitfields.Fields, in the order of the pretty format.
+ * Optional fields may be missing from the end of the line in logs written before they existed.
  */
 func parsePrettyLogLine(prettyLogLine string) (*common.Commit, error) {
 	commitData := new(common.Commit)
 	splitPrettyLogLine := strings.Split(prettyLogLine, logformat.PrettyFormatStringSeparator)
 
 	expectedParameterCount := logformat.PrettyFormatStringParameterCount()
+	legacyParameterCount := logformat.LegacyPrettyFormatStringParameterCount()
 	prettyLogLineValueCount := len(splitPrettyLogLine)
-	if prettyLogLineValueCount != expectedParameterCount {
+	if prettyLogLineValueCount > expectedParameterCount || prettyLogLineValueCount < legacyParameterCount {
 		return nil, fmt.Errorf("pretty log has an unexpected amount of values: expected %d, received %d", expectedParameterCount, prettyLogLineValueCount)
 	}
 
@@ -132,32 +117,13 @@ func parsePrettyLogLine(prettyLogLine string) (*common.Commit, error) {
 	splitPrettyLogLine[0] = strings.Replace(firstString, logformat.PrettyFormatStringStart, "", -1)
 	splitPrettyLogLine[len(splitPrettyLogLine)-1] = strings.Replace(lastString, logformat.PrettyFormatStringEnd, "", -1)
 
-	commitData.Id = splitPrettyLogLine[0]
-
-	authorParsedTime, authorParsedTimeErr := time.Parse(common.TimeFormat, splitPrettyLogLine[1])
-	if authorParsedTimeErr != nil {
-		return nil, authorParsedTimeErr
-	}
-	commitData.AuthorTime = authorParsedTime.Unix()
-
-	commitData.Author = common.Person{
-		Name:  splitPrettyLogLine[2],
-		Email: splitPrettyLogLine[3],
-	}
-
-	committerParsedTime, committerParsedTimeErr := time.Parse(common.TimeFormat, splitPrettyLogLine[4])
-	if committerParsedTimeErr != nil {
-		return nil, committerParsedTimeErr
-	}
-	commitData.CommitterTime = committerParsedTime.Unix()
-
-	commitData.Committer = common.Person{
-		Name:  splitPrettyLogLine[5],
-		Email: splitPrettyLogLine[6],
+	fields := commitfields.PrettyFormatFields()
+	for i, value := range splitPrettyLogLine {
+		field := fields[i]
+		if err := field.Parse(commitData, value); err != nil {
+			return nil, fmt.Errorf("could not parse %s: %w", field.Name, err)
+		}
 	}
 
-	commitData.Subject = splitPrettyLogLine[7]
-	commitData.Body = splitPrettyLogLine[8]
-
 	return commitData, nil
 }
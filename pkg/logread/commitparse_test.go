@@ -9,6 +9,16 @@ import (
 	"github.com/google/go-cmp/cmp"
 )
 
+func testFileChange(path string, insertions int, deletions int) *common.FileChange {
+	return &common.FileChange{
+		LineChanges: common.LineChanges{
+			NumInsertions: insertions,
+			NumDeletions:  deletions,
+		},
+		Path: path,
+	}
+}
+
 func TestParseCommit(t *testing.T) {
 	testCommit := `PRETTYFORMATSTART__1c915e7dd147d4b060c2c241bb966d6f6c6ecde9__SEPARATOR__Sat, 8 Apr 2023 17:47:43 +0800__SEPARATOR__Claudio Cambra__SEPARATOR__developer@claudiocambra.com__SEPARATOR__Wed, 12 Apr 2023 23:21:43 +0000__SEPARATOR__Jean-Baptiste Kempf__SEPARATOR__jb@videolan.org__SEPARATOR__This is a commit message__SEPARATOR__This is a commit body__PRETTYFORMATEND
 modules/gui/macosx/library/VLCLibraryWindow.h                            |  6 +++---
@@ -46,6 +56,14 @@ modules/gui/macosx/library/video-library/VLCLibraryVideoViewController.m |  2 +-
 	expectedCommitData.NumFilesChanged = 6
 	expectedCommitData.Subject = "This is a commit message"
 	expectedCommitData.Body = "This is a commit body"
+	expectedCommitData.FileChanges = []*common.FileChange{
+		testFileChange("modules/gui/macosx/library/VLCLibraryWindow.h", 3, 3),
+		testFileChange("modules/gui/macosx/library/VLCLibraryWindowPersistentPreferences.h", 9, 13),
+		testFileChange("modules/gui/macosx/library/VLCLibraryWindowPersistentPreferences.m", 15, 15),
+		testFileChange("modules/gui/macosx/library/audio-library/VLCLibraryAudioViewController.m", 2, 2),
+		testFileChange("modules/gui/macosx/library/media-source/VLCMediaSourceBaseDataSource.m", 2, 2),
+		testFileChange("modules/gui/macosx/library/video-library/VLCLibraryVideoViewController.m", 1, 1),
+	}
 
 	commitData, err := ParseCommit(testCommit)
 	if err != nil {
@@ -57,6 +75,56 @@ modules/gui/macosx/library/video-library/VLCLibraryVideoViewController.m |  2 +-
 	}
 }
 
+func TestParseCommitNumstat(t *testing.T) {
+	testCommit := `PRETTYFORMATSTART__93367dcd81d5a5709f53abd78054fb444cd9af2f__SEPARATOR__Sun, 4 Jun 2023 16:35:34 +0800__SEPARATOR__Claudio Cambra__SEPARATOR__developer@claudiocambra.com__SEPARATOR__Sun, 4 Jun 2023 16:35:34 +0800__SEPARATOR__Claudio Cambra__SEPARATOR__developer@claudiocambra.com__SEPARATOR__Move things around__SEPARATOR____PRETTYFORMATEND
+-	-	assets/icon.png
+0	0	src/{old => new}/file.go
+12	40	README.md
+ assets/icon.png          | Bin 2 -> 3 bytes
+ src/{old => new}/file.go |  0
+ README.md                | 52 +++-----------
+ 3 files changed, 12 insertions(+), 40 deletions(-)`
+
+	expectedFileChanges := []*common.FileChange{
+		{Path: "assets/icon.png", Binary: true},
+		{Path: "src/new/file.go", PreviousPath: "src/old/file.go"},
+		testFileChange("README.md", 12, 40),
+	}
+
+	commitData, err := ParseCommit(testCommit)
+	if err != nil {
+		t.Fatalf("Received an error while parsing commit: %s", err)
+	}
+
+	if !cmp.Equal(commitData.FileChanges, expectedFileChanges) {
+		t.Fatalf(`Parsed file changes do not equal expected file changes. %s`, cmp.Diff(expectedFileChanges, commitData.FileChanges))
+	}
+}
+
+func TestParseCommitParents(t *testing.T) {
+	testCommits := map[string][]string{
+		// Merge commits have no stat lines unless a diff is requested for them
+		`PRETTYFORMATSTART__93367dcd81d5a5709f53abd78054fb444cd9af2f__SEPARATOR__Sun, 4 Jun 2023 16:35:34 +0800__SEPARATOR__Claudio Cambra__SEPARATOR__developer@claudiocambra.com__SEPARATOR__Sun, 4 Jun 2023 16:35:34 +0800__SEPARATOR__Claudio Cambra__SEPARATOR__developer@claudiocambra.com__SEPARATOR__Merge branch 'feature'__SEPARATOR____SEPARATOR__4610c5caa1b48f113ee87f48aeace2846a474957 1c915e7dd147d4b060c2c241bb966d6f6c6ecde9__PRETTYFORMATEND
+`: {"4610c5caa1b48f113ee87f48aeace2846a474957", "1c915e7dd147d4b060c2c241bb966d6f6c6ecde9"},
+		`PRETTYFORMATSTART__1c915e7dd147d4b060c2c241bb966d6f6c6ecde9__SEPARATOR__Sun, 4 Jun 2023 16:35:34 +0800__SEPARATOR__Claudio Cambra__SEPARATOR__developer@claudiocambra.com__SEPARATOR__Sun, 4 Jun 2023 16:35:34 +0800__SEPARATOR__Claudio Cambra__SEPARATOR__developer@claudiocambra.com__SEPARATOR__Initial commit__SEPARATOR____SEPARATOR____PRETTYFORMATEND
+ README.md | 1 +
+ 1 file changed, 1 insertion(+)`: nil,
+	}
+
+	for testCommit, expectedParentIds := range testCommits {
+		commitData, err := ParseCommit(testCommit)
+		if err != nil {
+			t.Fatalf("Received an error while parsing commit: %s", err)
+		}
+
+		if !cmp.Equal(commitData.ParentIds, expectedParentIds) {
+			t.Fatalf(`Parsed parents do not equal expected parents. %s`, cmp.Diff(expectedParentIds, commitData.ParentIds))
+		} else if commitData.IsMerge() != (len(expectedParentIds) > 1) {
+			t.Fatalf("Commit %s incorrectly reported as a merge: %t", commitData.Id, commitData.IsMerge())
+		}
+	}
+}
+
 func TestParseCommitLog(t *testing.T) {
 	testCommitLogBytes, err := os.ReadFile("../../test/data/log.txt")
 	if err != nil {
@@ -0,0 +1,197 @@
+package logread
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"log"
+
+	"github.com/claucambra/commit-analysis-tool/internal/logformat"
+	"github.com/claucambra/commit-analysis-tool/pkg/common"
+)
+
+const initialScanBufferSize = 64 * 1024
+
+// Upper bound for a single commit (pretty line plus stat lines) in the log
+const maxCommitSize = 512 * 1024 * 1024
+
+var prettyFormatStartBytes = []byte(logformat.PrettyFormatStringStart)
+
+// Reads commits one by one from a git log produced with the pretty format in commitformat.go.
+// Only the commit currently being parsed is held in memory, so logs of any length can be read.
+type CommitScanner struct {
+	scanner *bufio.Scanner
+	commit  *common.Commit
+	err     error
+
+	// Set on every scanned commit, if not empty
+	repoName string
+	// Only commits for which this returns true are returned, if set
+	filter func(*common.Commit) bool
+	// Skip commits that cannot be parsed instead of stopping
+	lenient     bool
+	onReject    func(*ParseError)
+	numRejected int
+
+	// Bytes consumed from the reader and position of the last commit read, for errors
+	readOffset   int64
+	commitOffset int64
+
+	// Called once the underlying reader is exhausted, e.g. to wait on a git process
+	finish func() error
+	// Called when scanning stops before the underlying reader is exhausted
+	abort func() error
+}
+
+func NewCommitScanner(reader io.Reader) *CommitScanner {
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 0, initialScanBufferSize), maxCommitSize)
+
+	cs := &CommitScanner{
+		scanner: scanner,
+	}
+
+	scanner.Split(func(data []byte, atEOF bool) (int, []byte, error) {
+		advance, token, err := splitCommits(data, atEOF)
+		if token != nil {
+			cs.commitOffset = cs.readOffset + int64(advance-len(token))
+		}
+
+		cs.readOffset += int64(advance)
+		return advance, token, err
+	})
+
+	return cs
+}
+
+/**
+ * Split function for bufio.Scanner that returns one raw commit per token.
+ * A commit starts at a pretty format start marker and runs until the next one (or the end of
+ * the log), which includes the stat lines that follow the pretty format line.
+ */
+func splitCommits(data []byte, atEOF bool) (int, []byte, error) {
+	start := bytes.Index(data, prettyFormatStartBytes)
+	if start < 0 {
+		if atEOF {
+			return len(data), nil, nil
+		}
+
+		// Discard data that cannot contain the beginning of a start marker
+		discardable := len(data) - len(prettyFormatStartBytes) + 1
+		return common.MaxInt(discardable, 0), nil, nil
+	}
+
+	searchFrom := start + len(prettyFormatStartBytes)
+	next := bytes.Index(data[searchFrom:], prettyFormatStartBytes)
+	if next < 0 {
+		if atEOF {
+			return len(data), data[start:], nil
+		}
+
+		return start, nil, nil
+	}
+
+	end := searchFrom + next
+	return end, data[start:end], nil
+}
+
+func (cs *CommitScanner) Next() bool {
+	if cs.err != nil {
+		return false
+	}
+
+	for cs.scanner.Scan() {
+		rawCommit := cs.scanner.Text()
+		commit, err := ParseCommit(rawCommit)
+		if err != nil {
+			var parseErr *ParseError
+			if !errors.As(err, &parseErr) {
+				parseErr = newParseError(rawCommit, err)
+			}
+
+			parseErr.Offset = cs.commitOffset
+
+			if cs.lenient {
+				cs.reject(parseErr)
+				continue
+			}
+
+			cs.commit = nil
+			cs.err = parseErr
+			cs.Close()
+			return false
+		}
+
+		if cs.filter != nil && !cs.filter(commit) {
+			continue
+		}
+
+		if cs.repoName != "" {
+			commit.RepoName = cs.repoName
+		}
+
+		cs.commit = commit
+		return true
+	}
+
+	cs.commit = nil
+	cs.err = cs.scanner.Err()
+
+	if cs.err != nil {
+		cs.Close()
+	} else if cs.finish != nil {
+		cs.err = cs.finish()
+		cs.finish = nil
+		cs.abort = nil
+	}
+
+	return false
+}
+
+// Makes the scanner skip commits that cannot be parsed rather than stopping at the first one.
+// onReject, if not nil, is called with the error of every skipped commit.
+func (cs *CommitScanner) SetLenient(onReject func(*ParseError)) {
+	cs.lenient = true
+	cs.onReject = onReject
+}
+
+func (cs *CommitScanner) reject(parseErr *ParseError) {
+	log.Printf("Skipping commit: %s", parseErr)
+	cs.numRejected++
+
+	if cs.onReject != nil {
+		cs.onReject(parseErr)
+	}
+}
+
+// Number of commits skipped in lenient mode
+func (cs *CommitScanner) NumRejected() int {
+	return cs.numRejected
+}
+
+// Tags every commit read from now on as belonging to the named repository
+func (cs *CommitScanner) SetRepoName(repoName string) {
+	cs.repoName = repoName
+}
+
+func (cs *CommitScanner) Commit() *common.Commit {
+	return cs.commit
+}
+
+func (cs *CommitScanner) Err() error {
+	return cs.err
+}
+
+// Releases the underlying reader's resources if scanning is stopped before reaching the end
+func (cs *CommitScanner) Close() error {
+	if cs.abort == nil {
+		return nil
+	}
+
+	err := cs.abort()
+	cs.abort = nil
+	cs.finish = nil
+
+	return err
+}
@@ -0,0 +1,152 @@
+package logread
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+const testScannerCommitA = `PRETTYFORMATSTART__1c915e7dd147d4b060c2c241bb966d6f6c6ecde9__SEPARATOR__Sat, 8 Apr 2023 17:47:43 +0800__SEPARATOR__Claudio Cambra__SEPARATOR__developer@claudiocambra.com__SEPARATOR__Wed, 12 Apr 2023 23:21:43 +0000__SEPARATOR__Jean-Baptiste Kempf__SEPARATOR__jb@videolan.org__SEPARATOR__This is a commit message__SEPARATOR__This is a commit body__PRETTYFORMATEND
+modules/gui/macosx/library/VLCLibraryWindow.h                            |  6 +++---
+modules/gui/macosx/library/audio-library/VLCLibraryAudioViewController.m |  4 ++--
+2 files changed, 5 insertions(+), 5 deletions(-)
+
+`
+
+const testScannerCommitB = `PRETTYFORMATSTART__4610c5caa1b48f113ee87f48aeace2846a474957__SEPARATOR__Sun, 4 Jun 2023 16:35:34 +0800__SEPARATOR__Claudio Cambra__SEPARATOR__developer@claudiocambra.com__SEPARATOR__Sun, 4 Jun 2023 16:35:34 +0800__SEPARATOR__Claudio Cambra__SEPARATOR__developer@claudiocambra.com__SEPARATOR__Replace use of reflect.DeepEqual__SEPARATOR__Multi-line
+body
+
+Signed-off-by: Claudio Cambra <developer@claudiocambra.com>__PRETTYFORMATEND
+go.mod |   1 +
+go.sum |   2 ++
+2 files changed, 3 insertions(+)`
+
+func TestCommitScanner(t *testing.T) {
+	expectedCommitA, err := ParseCommit(testScannerCommitA)
+	if err != nil {
+		t.Fatalf("Received an error while parsing commit: %s", err)
+	}
+
+	expectedCommitB, err := ParseCommit(testScannerCommitB)
+	if err != nil {
+		t.Fatalf("Received an error while parsing commit: %s", err)
+	}
+
+	// Reading one byte at a time makes sure commits split across reads are put back together
+	reader := iotest.OneByteReader(strings.NewReader(testScannerCommitA + testScannerCommitB))
+	scanner := NewCommitScanner(reader)
+
+	expectedCommits := []string{expectedCommitA.Id, expectedCommitB.Id}
+	scannedCommits := []string{}
+
+	for scanner.Next() {
+		commit := scanner.Commit()
+		scannedCommits = append(scannedCommits, commit.Id)
+
+		if commit.Id == expectedCommitA.Id && !cmp.Equal(commit, expectedCommitA) {
+			t.Fatalf("Scanned commit does not equal expected commit. %s", cmp.Diff(expectedCommitA, commit))
+		} else if commit.Id == expectedCommitB.Id && !cmp.Equal(commit, expectedCommitB) {
+			t.Fatalf("Scanned commit does not equal expected commit. %s", cmp.Diff(expectedCommitB, commit))
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		t.Fatalf("Received an error while scanning commits: %s", err)
+	}
+
+	if !cmp.Equal(scannedCommits, expectedCommits) {
+		t.Fatalf("Scanned commits do not match expected commits. %s", cmp.Diff(expectedCommits, scannedCommits))
+	}
+}
+
+func TestCommitScannerEmptyLog(t *testing.T) {
+	scanner := NewCommitScanner(strings.NewReader(""))
+
+	if scanner.Next() {
+		t.Fatalf("Scanner returned a commit for an empty log: %+v", scanner.Commit())
+	}
+
+	if err := scanner.Err(); err != nil {
+		t.Fatalf("Received an error while scanning empty log: %s", err)
+	}
+}
+
+// Has a separator token in its subject, which leaves the pretty line with too many values
+const testScannerBadCommit = `PRETTYFORMATSTART__93367dcd81d5a5709f53abd78054fb444cd9af2f__SEPARATOR__Sun, 4 Jun 2023 16:35:34 +0800__SEPARATOR__Claudio Cambra__SEPARATOR__developer@claudiocambra.com__SEPARATOR__Sun, 4 Jun 2023 16:35:34 +0800__SEPARATOR__Claudio Cambra__SEPARATOR__developer@claudiocambra.com__SEPARATOR__Mention __SEPARATOR__ in a subject__SEPARATOR____SEPARATOR____PRETTYFORMATEND
+`
+
+// Has an author date that cannot be parsed
+const testScannerBadDateCommit = `PRETTYFORMATSTART__4c1d30fa1c4d1b8c2e77f2b4cd48f3e19b1ee3a1__SEPARATOR__yesterday__SEPARATOR__Claudio Cambra__SEPARATOR__developer@claudiocambra.com__SEPARATOR__Sun, 4 Jun 2023 16:35:34 +0800__SEPARATOR__Claudio Cambra__SEPARATOR__developer@claudiocambra.com__SEPARATOR__Subject__SEPARATOR____SEPARATOR____PRETTYFORMATEND
+`
+
+func TestCommitScannerParseError(t *testing.T) {
+	scanner := NewCommitScanner(strings.NewReader(testScannerCommitA + testScannerBadCommit + testScannerCommitB))
+
+	numScanned := 0
+	for scanner.Next() {
+		numScanned++
+	}
+
+	var parseErr *ParseError
+	if !errors.As(scanner.Err(), &parseErr) {
+		t.Fatalf("Expected a parse error, received: %v", scanner.Err())
+	} else if numScanned != 1 {
+		t.Fatalf("Unexpected number of commits scanned before the parse error: %d", numScanned)
+	}
+
+	expectedOffset := int64(len(testScannerCommitA))
+	if parseErr.CommitId != "93367dcd81d5a5709f53abd78054fb444cd9af2f" || parseErr.Offset != expectedOffset {
+		t.Fatalf("Unexpected parse error location: commit %s at byte %d, expected byte %d", parseErr.CommitId, parseErr.Offset, expectedOffset)
+	} else if parseErr.Raw != testScannerBadCommit {
+		t.Fatalf("Unexpected raw commit in parse error: %q", parseErr.Raw)
+	}
+}
+
+func TestCommitScannerLenient(t *testing.T) {
+	testLog := testScannerBadDateCommit + testScannerCommitA + testScannerBadCommit + testScannerCommitB
+	scanner := NewCommitScanner(strings.NewReader(testLog))
+
+	rejectsBuffer := &bytes.Buffer{}
+	rejectsWriter := NewRejectsWriter(rejectsBuffer)
+	scanner.SetLenient(func(parseErr *ParseError) {
+		if err := rejectsWriter.Write(parseErr); err != nil {
+			t.Fatalf("Error writing rejected commit: %s", err)
+		}
+	})
+
+	numScanned := 0
+	for scanner.Next() {
+		numScanned++
+	}
+
+	if err := scanner.Err(); err != nil {
+		t.Fatalf("Received an error while scanning leniently: %s", err)
+	} else if numScanned != 2 || scanner.NumRejected() != 2 {
+		t.Fatalf("Unexpected commit counts: %d scanned, %d rejected", numScanned, scanner.NumRejected())
+	}
+
+	expectedRejects := []struct {
+		commitId string
+		offset   int64
+	}{
+		{"4c1d30fa1c4d1b8c2e77f2b4cd48f3e19b1ee3a1", 0},
+		{"93367dcd81d5a5709f53abd78054fb444cd9af2f", int64(len(testScannerBadDateCommit + testScannerCommitA))},
+	}
+
+	decoder := json.NewDecoder(rejectsBuffer)
+	for _, expectedReject := range expectedRejects {
+		var reject rejectedCommit
+		if err := decoder.Decode(&reject); err != nil {
+			t.Fatalf("Could not decode rejected commit: %s", err)
+		}
+
+		if reject.CommitId != expectedReject.commitId || reject.Offset != expectedReject.offset || reject.Reason == "" || reject.Raw == "" {
+			t.Fatalf("Unexpected rejected commit: %+v", reject)
+		}
+	}
+}
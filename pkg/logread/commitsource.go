@@ -0,0 +1,55 @@
+package logread
+
+import (
+	"fmt"
+
+	"github.com/claucambra/commit-analysis-tool/pkg/common"
+)
+
+const (
+	// Runs the git binary, see GitCommitSource
+	GitCommitSourceKind = "git"
+	// Reads a saved log file, see LogFileCommitSource
+	LogFileCommitSourceKind = "log"
+	// Reads the repository in-process, see GoGitCommitSource
+	GoGitCommitSourceKind = "go-git"
+)
+
+var CommitSourceKinds = []string{GitCommitSourceKind, LogFileCommitSourceKind, GoGitCommitSourceKind}
+
+// Where the commits of a repository are read from
+type CommitSource interface {
+	// Identity of the repository the commits belong to
+	Repository() (*common.Repository, error)
+	// Commit hash each ref that is read points to, keyed by ref name. Empty for sources without refs.
+	RefTips() (map[string]string, error)
+	// Filters out the commit hashes that are not known to the source
+	ExistingCommits(commitIds []string) ([]string, error)
+	// Reads the commits that are not among the excluded commits or their ancestors
+	Commits(excludedCommits []string, options ReadOptions) (CommitStream, error)
+}
+
+// Commits as they are read from a CommitSource. The stream must be read until Next returns false,
+// or closed, to release its resources.
+type CommitStream interface {
+	common.CommitIterator
+
+	// Tags every commit read from now on as belonging to the named repository
+	SetRepoName(repoName string)
+	Close() error
+}
+
+// Creates a commit source of the given kind reading from path, which is a repository or a log file
+// depending on the kind
+func NewCommitSource(kind string, path string) (CommitSource, error) {
+	switch kind {
+	case GitCommitSourceKind, "":
+		return NewGitCommitSource(path), nil
+	case LogFileCommitSourceKind:
+		return NewLogFileCommitSource(path), nil
+	case GoGitCommitSourceKind:
+		return NewGoGitCommitSource(path)
+	default:
+		return nil, fmt.Errorf("unknown commit source %q, expected one of %v", kind, CommitSourceKinds)
+	}
+}
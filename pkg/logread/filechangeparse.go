@@ -0,0 +1,107 @@
+package logread
+
+import (
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/claucambra/commit-analysis-tool/pkg/common"
+)
+
+// Lines printed by --numstat, e.g. "12\t3\tpath/to/file" or "-\t-\tbinary.png"
+var numstatLineRegex = regexp.MustCompile(`^([0-9]+|-)\t([0-9]+|-)\t(.+)$`)
+
+// Lines printed by --stat, e.g. " path/to/file | 15 ++++++-----" or " image.png | Bin 0 -> 12 bytes"
+var statLineRegex = regexp.MustCompile(`^\s*(.+?)\s+\|\s+(?:(Bin)\b.*|([0-9]+)\s*([+-]*))\s*$`)
+
+// Rename notation used by git in stat output, e.g. "dir/{old => new}/file"
+var bracedRenameRegex = regexp.MustCompile(`^(.*)\{(.*) => (.*)\}(.*)$`)
+
+/**
+ * Parse the per-file lines that follow the pretty format line of a commit.
+ * --numstat lines are preferred as they contain exact insertion and deletion counts; --stat lines
+ * are only used when no --numstat lines are present (e.g. in logs saved without --numstat).
+ */
+func parseFileChanges(changesLog string) []*common.FileChange {
+	var numstatChanges []*common.FileChange
+	var statChanges []*common.FileChange
+
+	for _, line := range strings.Split(changesLog, "\n") {
+		if match := numstatLineRegex.FindStringSubmatch(line); match != nil {
+			numstatChanges = append(numstatChanges, parseNumstatLine(match))
+		} else if match := statLineRegex.FindStringSubmatch(line); match != nil {
+			statChanges = append(statChanges, parseStatLine(match))
+		}
+	}
+
+	if len(numstatChanges) > 0 {
+		return numstatChanges
+	}
+
+	return statChanges
+}
+
+func parseNumstatLine(match []string) *common.FileChange {
+	fileChange := newFileChange(match[3])
+
+	if match[1] == "-" && match[2] == "-" {
+		fileChange.Binary = true
+		return fileChange
+	}
+
+	fileChange.NumInsertions, _ = strconv.Atoi(match[1])
+	fileChange.NumDeletions, _ = strconv.Atoi(match[2])
+
+	return fileChange
+}
+
+/**
+ * The graph in stat lines is scaled down when a file has many changes, so the number of + and -
+ * signs is only used for the ratio of insertions to deletions within the total.
+ */
+func parseStatLine(match []string) *common.FileChange {
+	fileChange := newFileChange(match[1])
+
+	if match[2] != "" {
+		fileChange.Binary = true
+		return fileChange
+	}
+
+	totalChanges, _ := strconv.Atoi(match[3])
+	graph := match[4]
+	plusCount := strings.Count(graph, "+")
+	minusCount := strings.Count(graph, "-")
+
+	if plusCount+minusCount == 0 {
+		return fileChange
+	}
+
+	insertionsRatio := float64(plusCount) / float64(plusCount+minusCount)
+	fileChange.NumInsertions = int(math.Round(float64(totalChanges) * insertionsRatio))
+	fileChange.NumDeletions = totalChanges - fileChange.NumInsertions
+
+	return fileChange
+}
+
+// Creates a file change for a path as printed by git, resolving rename notation
+func newFileChange(printedPath string) *common.FileChange {
+	fileChange := new(common.FileChange)
+
+	if match := bracedRenameRegex.FindStringSubmatch(printedPath); match != nil {
+		fileChange.PreviousPath = cleanRenamedPath(match[1] + match[2] + match[4])
+		fileChange.Path = cleanRenamedPath(match[1] + match[3] + match[4])
+	} else if splitPath := strings.Split(printedPath, " => "); len(splitPath) == 2 {
+		fileChange.PreviousPath = splitPath[0]
+		fileChange.Path = splitPath[1]
+	} else {
+		fileChange.Path = printedPath
+	}
+
+	return fileChange
+}
+
+// Renames into or out of a directory produce paths like "dir/{ => sub}/file"
+func cleanRenamedPath(renamedPath string) string {
+	return strings.Replace(renamedPath, "//", "/", -1)
+}
@@ -0,0 +1,30 @@
+package logread
+
+import "github.com/claucambra/commit-analysis-tool/pkg/common"
+
+// Reads commits by running the git binary on a repository
+type GitCommitSource struct {
+	RepoPath string
+}
+
+func NewGitCommitSource(repoPath string) *GitCommitSource {
+	return &GitCommitSource{
+		RepoPath: repoPath,
+	}
+}
+
+func (gcs *GitCommitSource) Repository() (*common.Repository, error) {
+	return ReadRepository(gcs.RepoPath)
+}
+
+func (gcs *GitCommitSource) RefTips() (map[string]string, error) {
+	return RefTips(gcs.RepoPath)
+}
+
+func (gcs *GitCommitSource) ExistingCommits(commitIds []string) ([]string, error) {
+	return ExistingCommits(gcs.RepoPath, commitIds)
+}
+
+func (gcs *GitCommitSource) Commits(excludedCommits []string, options ReadOptions) (CommitStream, error) {
+	return StreamCommitsSince(gcs.RepoPath, excludedCommits, options)
+}
@@ -0,0 +1,687 @@
+package logread
+
+import (
+	"container/heap"
+	"context"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/claucambra/commit-analysis-tool/pkg/common"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	fdiff "github.com/go-git/go-git/v5/plumbing/format/diff"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+)
+
+// Reads commits of a repository in-process, without needing the git binary. Reads the same refs
+// and commits as GitCommitSource. Commits made at the same time can be read in a different order,
+// and line counts can differ slightly as diffs are computed with a different algorithm.
+type GoGitCommitSource struct {
+	RepoPath string
+
+	repo *git.Repository
+}
+
+func NewGoGitCommitSource(repoPath string) (*GoGitCommitSource, error) {
+	repo, err := git.PlainOpenWithOptions(repoPath, &git.PlainOpenOptions{DetectDotGit: true})
+	if err != nil {
+		return nil, err
+	}
+
+	return &GoGitCommitSource{
+		RepoPath: repoPath,
+		repo:     repo,
+	}, nil
+}
+
+func (ggcs *GoGitCommitSource) Repository() (*common.Repository, error) {
+	localPath, err := filepath.Abs(ggcs.RepoPath)
+	if err != nil {
+		return nil, err
+	}
+
+	originUrl := ""
+	if remote, err := ggcs.repo.Remote(originRemoteName); err == nil && len(remote.Config().URLs) > 0 {
+		originUrl = remote.Config().URLs[0]
+	}
+
+	defaultBranch := ""
+	remoteHeadName := plumbing.NewRemoteHEADReferenceName(originRemoteName)
+	if remoteHead, err := ggcs.repo.Storer.Reference(remoteHeadName); err == nil && remoteHead.Type() == plumbing.SymbolicReference {
+		defaultBranch = strings.TrimPrefix(remoteHead.Target().Short(), originRemoteName+"/")
+	} else if head, err := ggcs.repo.Storer.Reference(plumbing.HEAD); err == nil && head.Type() == plumbing.SymbolicReference {
+		defaultBranch = head.Target().Short()
+	}
+
+	return &common.Repository{
+		Name:          repositoryName(originUrl, localPath),
+		OriginUrl:     originUrl,
+		LocalPath:     localPath,
+		DefaultBranch: defaultBranch,
+	}, nil
+}
+
+func (ggcs *GoGitCommitSource) RefTips() (map[string]string, error) {
+	refs, err := ggcs.repo.References()
+	if err != nil {
+		return nil, err
+	}
+
+	tips := map[string]string{}
+	err = refs.ForEach(func(ref *plumbing.Reference) error {
+		if !ref.Name().IsBranch() && !ref.Name().IsRemote() {
+			return nil
+		}
+
+		resolvedRef, err := ggcs.repo.Reference(ref.Name(), true)
+		if err != nil {
+			// Dangling symbolic refs are skipped, like git does
+			return nil
+		}
+
+		tips[ref.Name().String()] = resolvedRef.Hash().String()
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if head, err := ggcs.repo.Head(); err == nil {
+		tips[headRefName] = head.Hash().String()
+	}
+
+	return tips, nil
+}
+
+func (ggcs *GoGitCommitSource) ExistingCommits(commitIds []string) ([]string, error) {
+	existingIds := []string{}
+
+	for _, commitId := range commitIds {
+		if _, err := ggcs.repo.CommitObject(plumbing.NewHash(commitId)); err == nil {
+			existingIds = append(existingIds, commitId)
+		}
+	}
+
+	return existingIds, nil
+}
+
+func (ggcs *GoGitCommitSource) Commits(excludedCommits []string, options ReadOptions) (CommitStream, error) {
+	repo, err := ggcs.Repository()
+	if err != nil {
+		return nil, err
+	}
+
+	authorRegexps, err := options.authorRegexps()
+	if err != nil {
+		return nil, err
+	}
+
+	startCommits, excludedRangeCommits, err := ggcs.startCommits(options)
+	if err != nil {
+		return nil, err
+	}
+
+	excludedCommits = append(excludedRangeCommits, excludedCommits...)
+
+	commitHashes, err := ggcs.walk(startCommits, excludedCommits, options.FirstParent)
+	if err != nil {
+		return nil, err
+	}
+
+	return &goGitCommitStream{
+		repo:          ggcs.repo,
+		hashes:        orderCommitHashes(commitHashes),
+		index:         -1,
+		options:       options,
+		authorRegexps: authorRegexps,
+		repoName:      repo.Name,
+	}, nil
+}
+
+// Commits to start reading from and commits excluded by revision ranges, like git log's revisions
+func (ggcs *GoGitCommitSource) startCommits(options ReadOptions) ([]string, []string, error) {
+	startCommits := []string{}
+	excludedCommits := []string{}
+
+	if len(options.Refs) == 0 && !options.FirstParent {
+		tips, err := ggcs.RefTips()
+		if err != nil {
+			return nil, nil, err
+		}
+
+		for _, commitId := range tips {
+			startCommits = append(startCommits, commitId)
+		}
+
+		return startCommits, excludedCommits, nil
+	}
+
+	refs := options.Refs
+	if len(refs) == 0 {
+		refs = []string{"HEAD"}
+	}
+
+	for _, ref := range refs {
+		included := ref
+		excluded := ""
+
+		if strings.HasPrefix(ref, "^") {
+			included = ""
+			excluded = ref[1:]
+		} else if from, to, isRange := strings.Cut(ref, ".."); isRange {
+			included = to
+			excluded = from
+		}
+
+		if included != "" {
+			commitId, err := ggcs.resolveRevision(included)
+			if err != nil {
+				return nil, nil, err
+			}
+
+			startCommits = append(startCommits, commitId)
+		}
+
+		if excluded != "" {
+			commitId, err := ggcs.resolveRevision(excluded)
+			if err != nil {
+				return nil, nil, err
+			}
+
+			excludedCommits = append(excludedCommits, commitId)
+		}
+	}
+
+	return startCommits, excludedCommits, nil
+}
+
+func (ggcs *GoGitCommitSource) resolveRevision(revision string) (string, error) {
+	hash, err := ggcs.repo.ResolveRevision(plumbing.Revision(revision))
+	if err != nil {
+		return "", fmt.Errorf("could not resolve %s: %w", revision, err)
+	}
+
+	return hash.String(), nil
+}
+
+// Commit in the graph walked to decide which commits are read
+type goGitGraphCommit struct {
+	hash          plumbing.Hash
+	parentHashes  []plumbing.Hash
+	committerTime int64
+}
+
+// Commit reached by the walk of GoGitCommitSource.walk
+type goGitWalkCommit struct {
+	*goGitGraphCommit
+	// All parents, even when only first parents are read
+	allParentHashes []plumbing.Hash
+
+	// Reachable from an excluded commit
+	uninteresting bool
+	inQueue       bool
+	walked        bool
+}
+
+// Number of commits the walk goes on for once only excluded commits are left to walk, in case a
+// commit with a skewed clock is still to be excluded, like git's
+const goGitWalkSlop = 5
+
+// Ancestors of the start commits, themselves included, that are not ancestors of the excluded
+// commits, keyed by hash. Like git's revision walk, commits are walked newest first and exclusion
+// spreads from the excluded commits to their ancestors as they are walked, so the walk stops once
+// only excluded commits are left instead of reading the whole history behind them.
+func (ggcs *GoGitCommitSource) walk(startCommits []string, excludedCommits []string, firstParent bool) (map[plumbing.Hash]*goGitGraphCommit, error) {
+	walkCommits := map[plumbing.Hash]*goGitWalkCommit{}
+	queue := &goGitWalkQueue{}
+	// Commits in the queue that are not excluded
+	numInteresting := 0
+
+	var reach func(hash plumbing.Hash, uninteresting bool) error
+	markUninteresting := func(walkCommit *goGitWalkCommit) error {
+		pending := []*goGitWalkCommit{walkCommit}
+		for len(pending) > 0 {
+			walkCommit := pending[len(pending)-1]
+			pending = pending[:len(pending)-1]
+
+			if walkCommit.uninteresting {
+				continue
+			}
+
+			walkCommit.uninteresting = true
+			if walkCommit.inQueue {
+				numInteresting--
+				continue
+			}
+
+			// Walked before it was known to be excluded, so its ancestors are excluded too
+			for _, parentHash := range walkCommit.allParentHashes {
+				if parent, ok := walkCommits[parentHash]; ok {
+					pending = append(pending, parent)
+				} else if err := reach(parentHash, true); err != nil {
+					return err
+				}
+			}
+		}
+
+		return nil
+	}
+
+	reach = func(hash plumbing.Hash, uninteresting bool) error {
+		if walkCommit, ok := walkCommits[hash]; ok {
+			if uninteresting {
+				return markUninteresting(walkCommit)
+			}
+
+			return nil
+		}
+
+		commit, err := ggcs.repo.CommitObject(hash)
+		if err != nil {
+			return err
+		}
+
+		parentHashes := commit.ParentHashes
+		if firstParent && len(parentHashes) > 1 {
+			parentHashes = parentHashes[:1]
+		}
+
+		walkCommit := &goGitWalkCommit{
+			goGitGraphCommit: &goGitGraphCommit{
+				hash:          hash,
+				parentHashes:  parentHashes,
+				committerTime: commit.Committer.When.Unix(),
+			},
+			allParentHashes: commit.ParentHashes,
+			uninteresting:   uninteresting,
+			inQueue:         true,
+		}
+
+		walkCommits[hash] = walkCommit
+		heap.Push(queue, walkCommit)
+		if !uninteresting {
+			numInteresting++
+		}
+
+		return nil
+	}
+
+	for _, commitId := range excludedCommits {
+		if err := reach(plumbing.NewHash(commitId), true); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, commitId := range startCommits {
+		if err := reach(plumbing.NewHash(commitId), false); err != nil {
+			return nil, err
+		}
+	}
+
+	slop := goGitWalkSlop
+	for queue.Len() > 0 {
+		if numInteresting > 0 {
+			slop = goGitWalkSlop
+		} else if slop == 0 {
+			break
+		} else {
+			slop--
+		}
+
+		walkCommit := heap.Pop(queue).(*goGitWalkCommit)
+		walkCommit.inQueue = false
+		walkCommit.walked = true
+
+		parentHashes := walkCommit.allParentHashes
+		if !walkCommit.uninteresting {
+			numInteresting--
+			parentHashes = walkCommit.parentHashes
+		}
+
+		for _, parentHash := range parentHashes {
+			if err := reach(parentHash, walkCommit.uninteresting); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	graphCommits := map[plumbing.Hash]*goGitGraphCommit{}
+	for hash, walkCommit := range walkCommits {
+		if walkCommit.walked && !walkCommit.uninteresting {
+			graphCommits[hash] = walkCommit.goGitGraphCommit
+		}
+	}
+
+	return graphCommits, nil
+}
+
+// Queue of commits to walk, newest first
+type goGitWalkQueue []*goGitWalkCommit
+
+func (q goGitWalkQueue) Len() int           { return len(q) }
+func (q goGitWalkQueue) Less(i, j int) bool { return q[i].committerTime > q[j].committerTime }
+func (q goGitWalkQueue) Swap(i, j int)      { q[i], q[j] = q[j], q[i] }
+
+func (q *goGitWalkQueue) Push(x any) {
+	*q = append(*q, x.(*goGitWalkCommit))
+}
+
+func (q *goGitWalkQueue) Pop() any {
+	old := *q
+	last := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return last
+}
+
+// Heap of commits ready to be read, oldest first
+type goGitGraphCommitHeap []*goGitGraphCommit
+
+func (h goGitGraphCommitHeap) Len() int           { return len(h) }
+func (h goGitGraphCommitHeap) Less(i, j int) bool { return h[i].committerTime < h[j].committerTime }
+func (h goGitGraphCommitHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+
+func (h *goGitGraphCommitHeap) Push(x any) {
+	*h = append(*h, x.(*goGitGraphCommit))
+}
+
+func (h *goGitGraphCommitHeap) Pop() any {
+	old := *h
+	last := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return last
+}
+
+// Orders commits like git log --reverse --date-order: parents before their children, otherwise
+// oldest first
+func orderCommitHashes(graphCommits map[plumbing.Hash]*goGitGraphCommit) []plumbing.Hash {
+	numPendingParents := map[plumbing.Hash]int{}
+	children := map[plumbing.Hash][]*goGitGraphCommit{}
+	ready := &goGitGraphCommitHeap{}
+
+	for hash, graphCommit := range graphCommits {
+		for _, parentHash := range graphCommit.parentHashes {
+			if _, ok := graphCommits[parentHash]; ok {
+				numPendingParents[hash]++
+				children[parentHash] = append(children[parentHash], graphCommit)
+			}
+		}
+
+		if numPendingParents[hash] == 0 {
+			heap.Push(ready, graphCommit)
+		}
+	}
+
+	orderedHashes := make([]plumbing.Hash, 0, len(graphCommits))
+	for ready.Len() > 0 {
+		graphCommit := heap.Pop(ready).(*goGitGraphCommit)
+		orderedHashes = append(orderedHashes, graphCommit.hash)
+
+		for _, child := range children[graphCommit.hash] {
+			numPendingParents[child.hash]--
+			if numPendingParents[child.hash] == 0 {
+				heap.Push(ready, child)
+			}
+		}
+	}
+
+	return orderedHashes
+}
+
+// Converts the ordered commits one at a time, as computing their changes is the expensive part
+type goGitCommitStream struct {
+	repo     *git.Repository
+	hashes   []plumbing.Hash
+	index    int
+	options  ReadOptions
+	repoName string
+	// Compiled options.Authors
+	authorRegexps []*regexp.Regexp
+
+	commit *common.Commit
+	err    error
+}
+
+func (ggcs *goGitCommitStream) Next() bool {
+	for ggcs.err == nil && ggcs.index+1 < len(ggcs.hashes) {
+		ggcs.index++
+
+		gitCommit, err := ggcs.repo.CommitObject(ggcs.hashes[ggcs.index])
+		if err != nil {
+			ggcs.err = err
+			break
+		}
+
+		if gitCommit.NumParents() > 1 && !ggcs.options.IncludeMerges {
+			continue
+		}
+
+		commit, err := ggcs.convertCommit(gitCommit)
+		if err != nil {
+			ggcs.err = err
+			break
+		} else if commit == nil {
+			continue
+		}
+
+		ggcs.commit = commit
+		return true
+	}
+
+	ggcs.commit = nil
+	return false
+}
+
+// Returns nil for commits left out by the read options
+func (ggcs *goGitCommitStream) convertCommit(gitCommit *object.Commit) (*common.Commit, error) {
+	subject, body := splitCommitMessage(gitCommit.Message)
+
+	commit := &common.Commit{
+		Id:       gitCommit.Hash.String(),
+		RepoName: ggcs.repoName,
+		Author: common.Person{
+			Name:  gitCommit.Author.Name,
+			Email: gitCommit.Author.Email,
+		},
+		AuthorTime: gitCommit.Author.When.Unix(),
+		Committer: common.Person{
+			Name:  gitCommit.Committer.Name,
+			Email: gitCommit.Committer.Email,
+		},
+		CommitterTime: gitCommit.Committer.When.Unix(),
+		Subject:       subject,
+		Body:          body,
+		Trailers:      ParseTrailers(body),
+	}
+
+	for _, parentHash := range gitCommit.ParentHashes {
+		commit.ParentIds = append(commit.ParentIds, parentHash.String())
+	}
+
+	if !ggcs.options.matchesCommit(commit, ggcs.authorRegexps) {
+		return nil, nil
+	}
+
+	// Like git log, merges only show changes when following first parents, and are left out by
+	// path filters when they do not change the paths relative to any parent
+	if gitCommit.NumParents() > 1 && !ggcs.options.FirstParent {
+		if touchesPaths, err := ggcs.mergeTouchesPaths(gitCommit); err != nil || !touchesPaths {
+			return nil, err
+		}
+
+		return commit, nil
+	}
+
+	fileChanges, err := commitFileChanges(gitCommit)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, fileChange := range fileChanges {
+		commit.NumInsertions += fileChange.NumInsertions
+		commit.NumDeletions += fileChange.NumDeletions
+	}
+
+	commit.NumFilesChanged = len(fileChanges)
+	commit.FileChanges = fileChanges
+
+	if !ggcs.options.filterFileChanges(commit) {
+		return nil, nil
+	}
+
+	return commit, nil
+}
+
+// Whether a merge changes paths passing the path filters relative to any of its parents, like
+// git log --full-history shows merges
+func (ggcs *goGitCommitStream) mergeTouchesPaths(gitCommit *object.Commit) (bool, error) {
+	if len(ggcs.options.IncludePaths) == 0 && len(ggcs.options.ExcludePaths) == 0 {
+		return true, nil
+	}
+
+	tree, err := gitCommit.Tree()
+	if err != nil {
+		return false, err
+	}
+
+	parents := gitCommit.Parents()
+	defer parents.Close()
+
+	touchesPaths := false
+	err = parents.ForEach(func(parent *object.Commit) error {
+		parentTree, err := parent.Tree()
+		if err != nil {
+			return err
+		}
+
+		changes, err := object.DiffTree(parentTree, tree)
+		if err != nil {
+			return err
+		}
+
+		for _, change := range changes {
+			for _, changedPath := range []string{change.From.Name, change.To.Name} {
+				if changedPath != "" && ggcs.options.matchesPath(changedPath) {
+					touchesPaths = true
+					return storer.ErrStop
+				}
+			}
+		}
+
+		return nil
+	})
+
+	return touchesPaths, err
+}
+
+// Changes of a commit relative to its first parent, with renames detected
+func commitFileChanges(gitCommit *object.Commit) ([]*common.FileChange, error) {
+	tree, err := gitCommit.Tree()
+	if err != nil {
+		return nil, err
+	}
+
+	parentTree := &object.Tree{}
+	if gitCommit.NumParents() > 0 {
+		parent, err := gitCommit.Parent(0)
+		if err != nil {
+			return nil, err
+		}
+
+		if parentTree, err = parent.Tree(); err != nil {
+			return nil, err
+		}
+	}
+
+	changes, err := object.DiffTreeWithOptions(context.Background(), parentTree, tree, object.DefaultDiffTreeOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	patch, err := changes.Patch()
+	if err != nil {
+		return nil, err
+	}
+
+	var fileChanges []*common.FileChange
+	for _, filePatch := range patch.FilePatches() {
+		from, to := filePatch.Files()
+		fileChange := &common.FileChange{Binary: filePatch.IsBinary()}
+
+		if to != nil {
+			fileChange.Path = to.Path()
+		} else if from != nil {
+			fileChange.Path = from.Path()
+		}
+
+		if from != nil && to != nil && from.Path() != to.Path() {
+			fileChange.PreviousPath = from.Path()
+		}
+
+		for _, chunk := range filePatch.Chunks() {
+			switch chunk.Type() {
+			case fdiff.Add:
+				fileChange.NumInsertions += countLines(chunk.Content())
+			case fdiff.Delete:
+				fileChange.NumDeletions += countLines(chunk.Content())
+			}
+		}
+
+		fileChanges = append(fileChanges, fileChange)
+	}
+
+	return fileChanges, nil
+}
+
+func countLines(content string) int {
+	if content == "" {
+		return 0
+	}
+
+	numLines := strings.Count(content, "\n")
+	if !strings.HasSuffix(content, "\n") {
+		numLines++
+	}
+
+	return numLines
+}
+
+// Splits a commit message like git's %s and %b placeholders do: the subject is the first
+// paragraph joined into one line, the body is everything after it
+func splitCommitMessage(message string) (string, string) {
+	message = strings.TrimLeft(message, "\n")
+	subjectParagraph := message
+	body := ""
+
+	if paragraphEnd := strings.Index(message, "\n\n"); paragraphEnd >= 0 {
+		subjectParagraph = message[:paragraphEnd]
+		body = strings.TrimLeft(message[paragraphEnd:], "\n")
+	}
+
+	subjectLines := strings.Split(strings.TrimRight(subjectParagraph, "\n"), "\n")
+	for i, line := range subjectLines {
+		subjectLines[i] = strings.TrimSpace(line)
+	}
+
+	return strings.Join(subjectLines, " "), body
+}
+
+func (ggcs *goGitCommitStream) SetRepoName(repoName string) {
+	ggcs.repoName = repoName
+}
+
+func (ggcs *goGitCommitStream) Commit() *common.Commit {
+	return ggcs.commit
+}
+
+func (ggcs *goGitCommitStream) Err() error {
+	return ggcs.err
+}
+
+func (ggcs *goGitCommitStream) Close() error {
+	ggcs.index = len(ggcs.hashes)
+	return nil
+}
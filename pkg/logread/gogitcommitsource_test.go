@@ -0,0 +1,242 @@
+package logread
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/claucambra/commit-analysis-tool/pkg/common"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/google/go-cmp/cmp"
+)
+
+var testGoGitCommitTime = time.Date(2023, 6, 4, 16, 35, 34, 0, time.UTC)
+
+func testGoGitCommit(t *testing.T, repo *git.Repository, repoPath string, files map[string]string, message string, parents []plumbing.Hash) plumbing.Hash {
+	return testGoGitCommitAt(t, repo, repoPath, files, message, parents, testGoGitCommitTime)
+}
+
+func testGoGitCommitAt(t *testing.T, repo *git.Repository, repoPath string, files map[string]string, message string, parents []plumbing.Hash, when time.Time) plumbing.Hash {
+	worktree, err := repo.Worktree()
+	if err != nil {
+		t.Fatalf("Could not get test repository worktree: %s", err)
+	}
+
+	for path, content := range files {
+		if err := os.WriteFile(filepath.Join(repoPath, path), []byte(content), 0644); err != nil {
+			t.Fatalf("Could not write test file: %s", err)
+		} else if _, err := worktree.Add(path); err != nil {
+			t.Fatalf("Could not add test file: %s", err)
+		}
+	}
+
+	signature := &object.Signature{
+		Name:  "Claudio Cambra",
+		Email: "developer@claudiocambra.com",
+		When:  when,
+	}
+
+	hash, err := worktree.Commit(message, &git.CommitOptions{
+		Author:    signature,
+		Committer: signature,
+		Parents:   parents,
+	})
+	if err != nil {
+		t.Fatalf("Could not create test commit: %s", err)
+	}
+
+	return hash
+}
+
+func TestGoGitCommitSource(t *testing.T) {
+	repoPath := filepath.Join(t.TempDir(), "vlc")
+	repo, err := git.PlainInit(repoPath, false)
+	if err != nil {
+		t.Fatalf("Could not create test repository: %s", err)
+	}
+
+	firstHash := testGoGitCommit(t, repo, repoPath, map[string]string{"README.md": "VLC\n"}, "Add readme\n", nil)
+	secondHash := testGoGitCommit(t, repo, repoPath,
+		map[string]string{"README.md": "VLC media player\n", "main.c": "int main() {\n}\n"},
+		"Describe the\nproject\n\nAnd add a main function.\n\nCo-authored-by: Jean-Baptiste Kempf <jb@videolan.org>\n",
+		nil)
+	mergeHash := testGoGitCommit(t, repo, repoPath, nil, "Merge branch 'feature'\n", []plumbing.Hash{secondHash, firstHash})
+
+	source, err := NewGoGitCommitSource(repoPath)
+	if err != nil {
+		t.Fatalf("Could not open test repository: %s", err)
+	}
+
+	tips, err := source.RefTips()
+	if err != nil {
+		t.Fatalf("Error reading refs: %s", err)
+	}
+
+	expectedTips := map[string]string{"refs/heads/master": mergeHash.String(), headRefName: mergeHash.String()}
+	if !cmp.Equal(expectedTips, tips) {
+		t.Fatalf("Unexpected ref tips: %s", cmp.Diff(expectedTips, tips))
+	}
+
+	commits, err := source.Commits([]string{firstHash.String()}, ReadOptions{IncludeMerges: true})
+	if err != nil {
+		t.Fatalf("Error reading commits: %s", err)
+	}
+
+	readCommits := []*common.Commit{}
+	for commits.Next() {
+		readCommits = append(readCommits, commits.Commit())
+	}
+
+	if err := commits.Err(); err != nil {
+		t.Fatalf("Error reading commits: %s", err)
+	} else if len(readCommits) != 2 {
+		t.Fatalf("Unexpected number of commits: expected 2, received %d", len(readCommits))
+	}
+
+	expectedCommit := &common.Commit{
+		Changes: common.Changes{
+			LineChanges:     common.LineChanges{NumInsertions: 3, NumDeletions: 1},
+			NumFilesChanged: 2,
+		},
+		Id:            secondHash.String(),
+		RepoName:      "vlc",
+		Author:        common.Person{Name: "Claudio Cambra", Email: "developer@claudiocambra.com"},
+		AuthorTime:    time.Date(2023, 6, 4, 16, 35, 34, 0, time.UTC).Unix(),
+		Committer:     common.Person{Name: "Claudio Cambra", Email: "developer@claudiocambra.com"},
+		CommitterTime: time.Date(2023, 6, 4, 16, 35, 34, 0, time.UTC).Unix(),
+		Subject:       "Describe the project",
+		Body:          "And add a main function.\n\nCo-authored-by: Jean-Baptiste Kempf <jb@videolan.org>\n",
+		FileChanges: []*common.FileChange{
+			testFileChange("README.md", 1, 1),
+			testFileChange("main.c", 2, 0),
+		},
+		Trailers: []*common.Trailer{
+			{
+				Kind:   common.CoAuthoredByTrailer,
+				Key:    "Co-authored-by",
+				Value:  "Jean-Baptiste Kempf <jb@videolan.org>",
+				Person: common.Person{Name: "Jean-Baptiste Kempf", Email: "jb@videolan.org"},
+			},
+		},
+		ParentIds: []string{firstHash.String()},
+	}
+
+	if !cmp.Equal(expectedCommit, readCommits[0]) {
+		t.Fatalf("Read commit does not equal expected commit. %s", cmp.Diff(expectedCommit, readCommits[0]))
+	}
+
+	mergeCommit := readCommits[1]
+	if mergeCommit.Id != mergeHash.String() || !mergeCommit.IsMerge() || mergeCommit.NumFilesChanged != 0 {
+		t.Fatalf("Unexpected merge commit: %+v", mergeCommit)
+	}
+}
+
+// Incremental reads only walk the history behind already read commits as far as needed, so they
+// still work when older commits cannot be read
+func TestGoGitCommitSourceExcludedHistory(t *testing.T) {
+	repoPath := filepath.Join(t.TempDir(), "vlc")
+	repo, err := git.PlainInit(repoPath, false)
+	if err != nil {
+		t.Fatalf("Could not create test repository: %s", err)
+	}
+
+	hashes := []plumbing.Hash{}
+	for i := 0; i < 10; i++ {
+		parents := []plumbing.Hash{}
+		if i > 0 {
+			parents = append(parents, hashes[i-1])
+		}
+
+		files := map[string]string{"README.md": fmt.Sprintf("VLC %d\n", i)}
+		when := testGoGitCommitTime.Add(time.Duration(i) * time.Hour)
+		hashes = append(hashes, testGoGitCommitAt(t, repo, repoPath, files, "Update readme\n", parents, when))
+	}
+
+	// Branched off long ago, merged after the last read
+	featureHash := testGoGitCommitAt(t, repo, repoPath, map[string]string{"main.c": "int main() {\n}\n"},
+		"Add main function\n", []plumbing.Hash{hashes[7]}, testGoGitCommitTime.Add(20*time.Hour))
+	mergeHash := testGoGitCommitAt(t, repo, repoPath, nil, "Merge branch 'feature'\n",
+		[]plumbing.Hash{hashes[9], featureHash}, testGoGitCommitTime.Add(21*time.Hour))
+
+	rootId := hashes[0].String()
+	if err := os.Remove(filepath.Join(repoPath, ".git", "objects", rootId[:2], rootId[2:])); err != nil {
+		t.Fatalf("Could not remove root commit: %s", err)
+	}
+
+	source, err := NewGoGitCommitSource(repoPath)
+	if err != nil {
+		t.Fatalf("Could not open test repository: %s", err)
+	}
+
+	commits, err := source.Commits([]string{hashes[9].String()}, ReadOptions{IncludeMerges: true})
+	if err != nil {
+		t.Fatalf("Error reading commits: %s", err)
+	}
+
+	readIds := []string{}
+	for commits.Next() {
+		readIds = append(readIds, commits.Commit().Id)
+	}
+
+	expectedIds := []string{featureHash.String(), mergeHash.String()}
+	if err := commits.Err(); err != nil {
+		t.Fatalf("Error reading commits: %s", err)
+	} else if !cmp.Equal(expectedIds, readIds) {
+		t.Fatalf("Unexpected commits read: %s", cmp.Diff(expectedIds, readIds))
+	}
+}
+
+// Like git log --full-history, merges are only read with path filters when they change the paths
+// relative to one of their parents
+func TestGoGitCommitSourceMergePaths(t *testing.T) {
+	repoPath := filepath.Join(t.TempDir(), "vlc")
+	repo, err := git.PlainInit(repoPath, false)
+	if err != nil {
+		t.Fatalf("Could not create test repository: %s", err)
+	}
+
+	for _, dir := range []string{"docs", "src"} {
+		if err := os.Mkdir(filepath.Join(repoPath, dir), 0755); err != nil {
+			t.Fatalf("Could not create test directory: %s", err)
+		}
+	}
+
+	baseHash := testGoGitCommit(t, repo, repoPath, map[string]string{"README.md": "VLC\n"}, "Add readme\n", nil)
+	featureHash := testGoGitCommit(t, repo, repoPath, map[string]string{"docs/index.md": "Docs\n"}, "Add docs\n",
+		[]plumbing.Hash{baseHash})
+	// Commits the whole index, so the docs are in its tree too
+	mainHash := testGoGitCommit(t, repo, repoPath, map[string]string{"src/main.c": "int main() {\n}\n"}, "Add main function\n",
+		[]plumbing.Hash{baseHash})
+	mergeHash := testGoGitCommit(t, repo, repoPath, nil, "Merge branch 'feature'\n", []plumbing.Hash{mainHash, featureHash})
+
+	source, err := NewGoGitCommitSource(repoPath)
+	if err != nil {
+		t.Fatalf("Could not open test repository: %s", err)
+	}
+
+	// The merge brings src/main.c to the feature branch, but changes no docs relative to main
+	expectedMerges := map[string]bool{"src": true, "docs": false}
+	for includePath, expectedMerge := range expectedMerges {
+		commits, err := source.Commits(nil, ReadOptions{IncludeMerges: true, IncludePaths: []string{includePath}})
+		if err != nil {
+			t.Fatalf("Error reading commits: %s", err)
+		}
+
+		readMerge := false
+		for commits.Next() {
+			if commits.Commit().Id == mergeHash.String() {
+				readMerge = true
+			}
+		}
+
+		if err := commits.Err(); err != nil {
+			t.Fatalf("Error reading commits: %s", err)
+		} else if readMerge != expectedMerge {
+			t.Fatalf("Unexpected merge read with path %s: expected %t, received %t", includePath, expectedMerge, readMerge)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package logread
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/claucambra/commit-analysis-tool/pkg/common"
+)
+
+// Log path that reads the log from stdin
+const StdinLogPath = "-"
+
+// Reads commits from a log saved with the command from GitLogCommandLine, e.g. one exported from a
+// repository that cannot be accessed directly. As a log has no refs, every read returns all of its
+// commits.
+type LogFileCommitSource struct {
+	LogPath string
+}
+
+func NewLogFileCommitSource(logPath string) *LogFileCommitSource {
+	return &LogFileCommitSource{
+		LogPath: logPath,
+	}
+}
+
+// Only the name is known for a log, which is taken from the file name, e.g. vlc.log -> vlc.
+// Nothing is known for a log read from stdin.
+func (lfcs *LogFileCommitSource) Repository() (*common.Repository, error) {
+	if lfcs.LogPath == StdinLogPath {
+		return &common.Repository{}, nil
+	}
+
+	localPath, err := filepath.Abs(lfcs.LogPath)
+	if err != nil {
+		return nil, err
+	}
+
+	fileName := filepath.Base(localPath)
+
+	return &common.Repository{
+		Name:      strings.TrimSuffix(fileName, filepath.Ext(fileName)),
+		LocalPath: localPath,
+	}, nil
+}
+
+func (lfcs *LogFileCommitSource) RefTips() (map[string]string, error) {
+	return map[string]string{}, nil
+}
+
+// Commits in a log cannot be excluded along with their ancestors, so none are reported as known
+func (lfcs *LogFileCommitSource) ExistingCommits(commitIds []string) ([]string, error) {
+	return []string{}, nil
+}
+
+// Dates, authors and paths are filtered as commits are read. Merges can only be left out of logs
+// that recorded parents. The traversal of the log was decided when it was written, so neither
+// refs nor first-parent reads are possible.
+func (lfcs *LogFileCommitSource) Commits(excludedCommits []string, options ReadOptions) (CommitStream, error) {
+	if len(excludedCommits) > 0 {
+		return nil, errors.New("cannot exclude commits from a saved log")
+	} else if options.FirstParent {
+		return nil, errors.New("cannot read first parents only from a saved log")
+	} else if len(options.Refs) > 0 {
+		return nil, errors.New("cannot read refs from a saved log")
+	}
+
+	repo, err := lfcs.Repository()
+	if err != nil {
+		return nil, err
+	}
+
+	filter, err := options.commitFilter()
+	if err != nil {
+		return nil, err
+	}
+
+	if lfcs.LogPath == StdinLogPath {
+		scanner := NewCommitScanner(os.Stdin)
+		scanner.filter = filter
+		options.configureScanner(scanner)
+		return scanner, nil
+	}
+
+	logFile, err := os.Open(lfcs.LogPath)
+	if err != nil {
+		return nil, err
+	}
+
+	scanner := NewCommitScanner(logFile)
+	scanner.SetRepoName(repo.Name)
+	scanner.filter = filter
+	options.configureScanner(scanner)
+	scanner.finish = logFile.Close
+	scanner.abort = logFile.Close
+
+	return scanner, nil
+}
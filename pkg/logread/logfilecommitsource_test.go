@@ -0,0 +1,66 @@
+package logread
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const testSavedLog = `PRETTYFORMATSTART__1c915e7dd147d4b060c2c241bb966d6f6c6ecde9__SEPARATOR__Sat, 8 Apr 2023 17:47:43 +0800__SEPARATOR__Claudio Cambra__SEPARATOR__developer@claudiocambra.com__SEPARATOR__Sat, 8 Apr 2023 17:47:43 +0800__SEPARATOR__Claudio Cambra__SEPARATOR__developer@claudiocambra.com__SEPARATOR__Add readme__SEPARATOR____SEPARATOR____PRETTYFORMATEND
+1	0	README.md
+ README.md | 1 +
+ 1 file changed, 1 insertion(+)
+PRETTYFORMATSTART__4610c5caa1b48f113ee87f48aeace2846a474957__SEPARATOR__Sun, 4 Jun 2023 16:35:34 +0800__SEPARATOR__Claudio Cambra__SEPARATOR__developer@claudiocambra.com__SEPARATOR__Sun, 4 Jun 2023 16:35:34 +0800__SEPARATOR__Claudio Cambra__SEPARATOR__developer@claudiocambra.com__SEPARATOR__Merge branch 'feature'__SEPARATOR____SEPARATOR__1c915e7dd147d4b060c2c241bb966d6f6c6ecde9 93367dcd81d5a5709f53abd78054fb444cd9af2f__PRETTYFORMATEND
+`
+
+func TestLogFileCommitSource(t *testing.T) {
+	logPath := filepath.Join(t.TempDir(), "vlc.log")
+	if err := os.WriteFile(logPath, []byte(testSavedLog), 0644); err != nil {
+		t.Fatalf("Could not write test log: %s", err)
+	}
+
+	source := NewLogFileCommitSource(logPath)
+
+	repo, err := source.Repository()
+	if err != nil {
+		t.Fatalf("Error reading log repository: %s", err)
+	} else if repo.Name != "vlc" {
+		t.Fatalf("Unexpected repository name for log: %s", repo.Name)
+	}
+
+	expectedCommitCounts := []struct {
+		options ReadOptions
+		count   int
+	}{
+		{ReadOptions{}, 1},
+		{ReadOptions{IncludeMerges: true}, 2},
+	}
+
+	for _, expected := range expectedCommitCounts {
+		options, expectedCommitCount := expected.options, expected.count
+
+		commits, err := source.Commits(nil, options)
+		if err != nil {
+			t.Fatalf("Error reading log commits: %s", err)
+		}
+
+		commitCount := 0
+		for commits.Next() {
+			if commits.Commit().RepoName != repo.Name {
+				t.Fatalf("Commit not tagged with log repository: %+v", commits.Commit())
+			}
+
+			commitCount++
+		}
+
+		if err := commits.Err(); err != nil {
+			t.Fatalf("Error reading log commits: %s", err)
+		} else if commitCount != expectedCommitCount {
+			t.Fatalf("Unexpected commit count with options %+v: expected %d, received %d", options, expectedCommitCount, commitCount)
+		}
+	}
+
+	if _, err := source.Commits(nil, ReadOptions{FirstParent: true}); err == nil {
+		t.Fatalf("Expected an error reading first parents from a saved log")
+	}
+}
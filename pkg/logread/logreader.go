@@ -4,43 +4,137 @@ import (
 	"fmt"
 	"log"
 	"os/exec"
+	"regexp"
+	"strings"
 
 	"github.com/claucambra/commit-analysis-tool/internal/logformat"
 	"github.com/claucambra/commit-analysis-tool/pkg/common"
 )
 
-func ReadCommits(repoPath string) ([]*common.Commit, error) {
-	log.Println("Running git log.")
-
-	cmd := exec.Command("git",
+// Arguments of the git log command reading commits, without the repository path, exclusions and
+// pathspecs
+func gitLogCommandArgs(options ReadOptions) []string {
+	args := []string{
 		"--no-pager",
-		"-C", repoPath,
 		"log",
-		"--no-merges",
-		"--branches",
-		"--remotes",
+	}
+
+	args = append(args, options.gitLogArgs()...)
+	args = append(args,
 		fmt.Sprintf("--pretty=format:%s", logformat.PrettyFormatString()),
 		"--reverse",
-		"--date-order",
-		"HEAD",
+		"--date-order")
+	args = append(args, options.gitLogRevisionArgs()...)
+	args = append(args,
+		"--numstat",
 		"--stat",
 		"--stat-width",
 		"999")
 
-	out, err := cmd.Output()
+	return args
+}
+
+func gitLogCommand(repoPath string, excludedCommits []string, options ReadOptions) *exec.Cmd {
+	args := append([]string{"-C", repoPath}, gitLogCommandArgs(options)...)
+
+	if len(excludedCommits) > 0 {
+		args = append(args, "--not")
+		args = append(args, excludedCommits...)
+	}
+
+	args = append(args, options.gitLogPathArgs()...)
+	return exec.Command("git", args...)
+}
+
+// The shell command that writes the log read by this package to stdout when run in a repository.
+// Its output can be saved and read with LogFileCommitSource where the repository is not available.
+func GitLogCommandLine(options ReadOptions) string {
+	args := append([]string{"git"}, gitLogCommandArgs(options)...)
+	args = append(args, options.gitLogPathArgs()...)
+	quotedArgs := make([]string, len(args))
+
+	for i, arg := range args {
+		quotedArgs[i] = shellQuote(arg)
+	}
+
+	return strings.Join(quotedArgs, " ")
+}
+
+var shellSafeRegex = regexp.MustCompile(`^[A-Za-z0-9_./=-]+$`)
+
+func shellQuote(arg string) string {
+	if shellSafeRegex.MatchString(arg) {
+		return arg
+	}
+
+	return "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+}
+
+// Starts git log and returns a scanner that parses its output as it is being printed.
+// The scanner must be read until Next returns false, or closed, to release the git process.
+func StreamCommits(repoPath string) (*CommitScanner, error) {
+	return StreamCommitsSince(repoPath, nil, ReadOptions{})
+}
+
+// Like StreamCommits, but leaves out the given commits and all of their ancestors. Passing the
+// ref tips recorded during a previous read yields only the commits added since then.
+func StreamCommitsSince(repoPath string, excludedCommits []string, options ReadOptions) (*CommitScanner, error) {
+	repo, err := ReadRepository(repoPath)
+	if err != nil {
+		return nil, err
+	}
+
+	// git log would only fail once it is running, after the repository has been checked
+	if _, err := options.authorRegexps(); err != nil {
+		return nil, err
+	}
+
+	cmd := gitLogCommand(repoPath, excludedCommits, options)
+
+	stdout, err := cmd.StdoutPipe()
 	if err != nil {
-		log.Fatalf("Error running git: %s\n", err)
 		return nil, err
 	}
 
-	log.Println("Git log printed.")
+	log.Println("Running git log.")
+
+	if err = cmd.Start(); err != nil {
+		return nil, err
+	}
 
-	outString := string(out)
+	scanner := NewCommitScanner(stdout)
+	scanner.SetRepoName(repo.Name)
+	options.configureScanner(scanner)
+	scanner.finish = func() error {
+		if err := cmd.Wait(); err != nil {
+			return fmt.Errorf("error running git: %w", err)
+		}
 
-	log.Println("Starting to parse git log.")
-	commits, err := ParseCommitLog(outString)
+		return nil
+	}
+	scanner.abort = func() error {
+		cmd.Process.Kill()
+		cmd.Wait()
+		return nil
+	}
+
+	return scanner, nil
+}
+
+func ReadCommits(repoPath string) ([]*common.Commit, error) {
+	scanner, err := StreamCommits(repoPath)
 	if err != nil {
-		log.Fatalf("Error during commit log parse: %s\n", err)
+		return nil, err
+	}
+
+	log.Println("Starting to parse git log.")
+
+	commits := []*common.Commit{}
+	for scanner.Next() {
+		commits = append(commits, scanner.Commit())
+	}
+
+	if err := scanner.Err(); err != nil {
 		return nil, err
 	}
 
@@ -4,7 +4,13 @@ import (
 	"log"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"testing"
+
+	"github.com/claucambra/commit-analysis-tool/pkg/common"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/google/go-cmp/cmp"
 )
 
 const repoUrl = "https://github.com/claucambra/commit-analysis-tool.git"
@@ -51,3 +57,51 @@ func TestReadCommits(t *testing.T) {
 
 	t.Logf("Read %d commits", numReadCommits)
 }
+
+func readTestCommits(t *testing.T, source CommitSource, options ReadOptions) []*common.Commit {
+	commits, err := source.Commits(nil, options)
+	if err != nil {
+		t.Fatalf("Error reading commits: %s", err)
+	}
+
+	readCommits := []*common.Commit{}
+	for commits.Next() {
+		readCommits = append(readCommits, commits.Commit())
+	}
+
+	if err := commits.Err(); err != nil {
+		t.Fatalf("Error reading commits: %s", err)
+	}
+
+	return readCommits
+}
+
+func TestGitLogCommandLine(t *testing.T) {
+	repoPath := filepath.Join(t.TempDir(), "vlc")
+	repo, err := git.PlainInit(repoPath, false)
+	if err != nil {
+		t.Fatalf("Could not create test repository: %s", err)
+	}
+
+	firstHash := testGoGitCommit(t, repo, repoPath, map[string]string{"README.md": "VLC\n"}, "Add readme\n", nil)
+	secondHash := testGoGitCommit(t, repo, repoPath, map[string]string{"main.c": "int main() {}\n"}, "Add 'main'\n", nil)
+	testGoGitCommit(t, repo, repoPath, nil, "Merge branch 'feature'\n", []plumbing.Hash{secondHash, firstHash})
+
+	options := ReadOptions{IncludeMerges: true}
+	logPath := filepath.Join(t.TempDir(), "vlc.log")
+
+	cmd := exec.Command("sh", "-c", GitLogCommandLine(options)+" > "+logPath)
+	cmd.Dir = repoPath
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("Error running printed git log command: %s %s", err, out)
+	}
+
+	expectedCommits := readTestCommits(t, NewGitCommitSource(repoPath), options)
+	savedCommits := readTestCommits(t, NewLogFileCommitSource(logPath), options)
+
+	if len(expectedCommits) != 3 {
+		t.Fatalf("Unexpected number of commits read from repository: %d", len(expectedCommits))
+	} else if !cmp.Equal(expectedCommits, savedCommits) {
+		t.Fatalf("Commits of saved log do not equal repository commits. %s", cmp.Diff(expectedCommits, savedCommits))
+	}
+}
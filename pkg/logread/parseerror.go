@@ -0,0 +1,82 @@
+package logread
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/claucambra/commit-analysis-tool/internal/logformat"
+)
+
+// Error for a commit in a log that could not be parsed
+type ParseError struct {
+	// Hash of the commit, if it could be read
+	CommitId string
+	// Position of the commit's start marker in the log, in bytes
+	Offset int64
+	// The commit as it appears in the log
+	Raw string
+
+	Err error
+}
+
+func newParseError(rawCommit string, err error) *ParseError {
+	return &ParseError{
+		CommitId: rawCommitId(rawCommit),
+		Raw:      rawCommit,
+		Err:      err,
+	}
+}
+
+func (pe *ParseError) Error() string {
+	if pe.CommitId == "" {
+		return fmt.Sprintf("could not parse commit at byte %d: %s", pe.Offset, pe.Err)
+	}
+
+	return fmt.Sprintf("could not parse commit %s at byte %d: %s", pe.CommitId, pe.Offset, pe.Err)
+}
+
+func (pe *ParseError) Unwrap() error {
+	return pe.Err
+}
+
+// The hash at the start of a raw commit, or an empty string if there is none
+func rawCommitId(rawCommit string) string {
+	commitId := strings.TrimPrefix(rawCommit, logformat.PrettyFormatStringStart)
+	commitId, _, _ = strings.Cut(commitId, logformat.PrettyFormatStringSeparator)
+
+	if len(commitId) == 0 || len(commitId) > 64 || strings.Trim(commitId, "0123456789abcdef") != "" {
+		return ""
+	}
+
+	return commitId
+}
+
+// Writes commits skipped in lenient mode to a rejects file, one JSON object per line holding the
+// reason along with the commit as it appeared in the log
+type RejectsWriter struct {
+	encoder *json.Encoder
+}
+
+type rejectedCommit struct {
+	CommitId string `json:"commit_id"`
+	Offset   int64  `json:"offset"`
+	Reason   string `json:"reason"`
+	Raw      string `json:"raw"`
+}
+
+func NewRejectsWriter(writer io.Writer) *RejectsWriter {
+	return &RejectsWriter{
+		encoder: json.NewEncoder(writer),
+	}
+}
+
+func (rw *RejectsWriter) Write(parseErr *ParseError) error {
+	return rw.encoder.Encode(rejectedCommit{
+		CommitId: parseErr.CommitId,
+		Offset:   parseErr.Offset,
+		Reason:   parseErr.Err.Error(),
+		Raw:      parseErr.Raw,
+	})
+}
@@ -0,0 +1,224 @@
+package logread
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/claucambra/commit-analysis-tool/pkg/common"
+)
+
+// Options controlling which commits are read from a repository. The zero value reads the
+// non-merge commits of all branches, like earlier versions did. Incremental ingests only read the
+// commits added since the last ingest, so they should use the same options as the first one.
+type ReadOptions struct {
+	// Also read merge commits along with their parents
+	IncludeMerges bool `json:"include_merges"`
+	// Only follow the first parent of merge commits, i.e. the mainline history rather than the
+	// commits of merged branches, which are not read. Merge commits then carry the changes they
+	// brought in. Starts from HEAD unless refs are given.
+	FirstParent bool `json:"first_parent"`
+
+	// Refs or revision ranges to read, e.g. "main" or "v1.0..v2.0". All branches, remote
+	// branches and HEAD are read if empty.
+	Refs []string `json:"refs"`
+	// Only read commits committed within these times, if not zero
+	Since time.Time `json:"since"`
+	Until time.Time `json:"until"`
+	// Only read changes to paths under (or matching the patterns in) IncludePaths, if not empty,
+	// and not under ExcludePaths. Commits without such changes are not read.
+	IncludePaths []string `json:"include_paths"`
+	ExcludePaths []string `json:"exclude_paths"`
+	// Only read commits whose author ("Name <email>") matches one of these regular expressions,
+	// if not empty
+	Authors []string `json:"authors"`
+
+	// Skip commits that cannot be parsed instead of stopping at the first one. Only applies to
+	// sources that parse logs.
+	Lenient bool `json:"lenient"`
+	// Called with the error of every commit skipped in lenient mode, e.g. to write a rejects file
+	OnReject func(*ParseError) `json:"-"`
+}
+
+func (options ReadOptions) gitLogArgs() []string {
+	args := []string{}
+
+	if !options.IncludeMerges {
+		args = append(args, "--no-merges")
+	}
+
+	if options.FirstParent {
+		args = append(args, "--first-parent")
+
+		if options.IncludeMerges {
+			args = append(args, "--diff-merges=first-parent")
+		}
+	}
+
+	if !options.Since.IsZero() {
+		args = append(args, "--since="+options.Since.UTC().Format(time.RFC3339))
+	}
+
+	if !options.Until.IsZero() {
+		args = append(args, "--until="+options.Until.UTC().Format(time.RFC3339))
+	}
+
+	// Without full history, git would not show side branch commits whose changes to the paths
+	// are also in the mainline
+	if len(options.IncludePaths) > 0 || len(options.ExcludePaths) > 0 {
+		args = append(args, "--full-history")
+	}
+
+	if len(options.Authors) > 0 {
+		args = append(args, "--extended-regexp")
+		for _, author := range options.Authors {
+			args = append(args, "--author="+author)
+		}
+	}
+
+	return args
+}
+
+// Refs to start reading from
+func (options ReadOptions) gitLogRevisionArgs() []string {
+	if len(options.Refs) > 0 {
+		return options.Refs
+	} else if options.FirstParent {
+		return []string{"HEAD"}
+	}
+
+	return []string{"--branches", "--remotes", "HEAD"}
+}
+
+// Pathspecs, which have to come last
+func (options ReadOptions) gitLogPathArgs() []string {
+	if len(options.IncludePaths) == 0 && len(options.ExcludePaths) == 0 {
+		return nil
+	}
+
+	args := []string{"--"}
+	args = append(args, options.IncludePaths...)
+
+	for _, excludePath := range options.ExcludePaths {
+		args = append(args, ":(exclude)"+excludePath)
+	}
+
+	return args
+}
+
+func (options ReadOptions) configureScanner(scanner *CommitScanner) {
+	if options.Lenient {
+		scanner.SetLenient(options.OnReject)
+	}
+}
+
+// Compiled Authors patterns, compiled once per read rather than for every commit
+func (options ReadOptions) authorRegexps() ([]*regexp.Regexp, error) {
+	authorRegexps := make([]*regexp.Regexp, len(options.Authors))
+	for i, authorPattern := range options.Authors {
+		authorRegexp, err := regexp.Compile(authorPattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid author pattern %q: %w", authorPattern, err)
+		}
+
+		authorRegexps[i] = authorRegexp
+	}
+
+	return authorRegexps, nil
+}
+
+// Whether a commit passes the date filters and matches one of the compiled Authors patterns
+func (options ReadOptions) matchesCommit(commit *common.Commit, authorRegexps []*regexp.Regexp) bool {
+	if !options.Since.IsZero() && commit.CommitterTime < options.Since.Unix() {
+		return false
+	} else if !options.Until.IsZero() && commit.CommitterTime > options.Until.Unix() {
+		return false
+	} else if len(authorRegexps) == 0 {
+		return true
+	}
+
+	author := commit.Author.Name + " <" + commit.Author.Email + ">"
+	for _, authorRegexp := range authorRegexps {
+		if authorRegexp.MatchString(author) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Whether the path passes the path filters. Like git pathspecs, a filter matches the path itself,
+// the paths below it, or the paths matching it as a glob pattern.
+func (options ReadOptions) matchesPath(filePath string) bool {
+	for _, excludePath := range options.ExcludePaths {
+		if pathspecMatches(excludePath, filePath) {
+			return false
+		}
+	}
+
+	if len(options.IncludePaths) == 0 {
+		return true
+	}
+
+	for _, includePath := range options.IncludePaths {
+		if pathspecMatches(includePath, filePath) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func pathspecMatches(pathspec string, filePath string) bool {
+	pathspec = strings.TrimSuffix(pathspec, "/")
+	if pathspec == "" || pathspec == "." || filePath == pathspec || strings.HasPrefix(filePath, pathspec+"/") {
+		return true
+	}
+
+	matched, err := path.Match(pathspec, filePath)
+	return err == nil && matched
+}
+
+// Applies the path filters to a commit's file changes, recounting its changes from the files
+// that are left. Returns false if no files are left, like git does not show such commits.
+func (options ReadOptions) filterFileChanges(commit *common.Commit) bool {
+	if len(options.IncludePaths) == 0 && len(options.ExcludePaths) == 0 {
+		return true
+	}
+
+	fileChanges := []*common.FileChange{}
+	commit.LineChanges = common.LineChanges{}
+
+	for _, fileChange := range commit.FileChanges {
+		if !options.matchesPath(fileChange.Path) {
+			continue
+		}
+
+		fileChanges = append(fileChanges, fileChange)
+		commit.NumInsertions += fileChange.NumInsertions
+		commit.NumDeletions += fileChange.NumDeletions
+	}
+
+	commit.FileChanges = fileChanges
+	commit.NumFilesChanged = len(fileChanges)
+
+	return len(fileChanges) > 0
+}
+
+// Filter applying the options to commits that have already been read, e.g. from a saved log
+func (options ReadOptions) commitFilter() (func(*common.Commit) bool, error) {
+	authorRegexps, err := options.authorRegexps()
+	if err != nil {
+		return nil, err
+	}
+
+	return func(commit *common.Commit) bool {
+		if commit.IsMerge() && !options.IncludeMerges {
+			return false
+		}
+
+		return options.matchesCommit(commit, authorRegexps) && options.filterFileChanges(commit)
+	}, nil
+}
@@ -0,0 +1,156 @@
+package logread
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/claucambra/commit-analysis-tool/pkg/common"
+	"github.com/go-git/go-git/v5"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestReadOptionsCommitFilter(t *testing.T) {
+	commitTime := time.Date(2018, 3, 1, 12, 0, 0, 0, time.UTC)
+	newTestCommit := func() *common.Commit {
+		return &common.Commit{
+			Changes: common.Changes{
+				LineChanges:     common.LineChanges{NumInsertions: 12, NumDeletions: 3},
+				NumFilesChanged: 3,
+			},
+			Author:        common.Person{Name: "Claudio Cambra", Email: "developer@claudiocambra.com"},
+			CommitterTime: commitTime.Unix(),
+			FileChanges: []*common.FileChange{
+				testFileChange("drivers/gpu/drm.c", 10, 2),
+				testFileChange("drivers/net/eth.c", 1, 1),
+				testFileChange("README.md", 1, 0),
+			},
+		}
+	}
+
+	tests := []struct {
+		name             string
+		options          ReadOptions
+		matches          bool
+		expectedNumFiles int
+	}{
+		{"no options", ReadOptions{}, true, 3},
+		{"since before", ReadOptions{Since: commitTime.Add(-time.Hour)}, true, 3},
+		{"since after", ReadOptions{Since: commitTime.Add(time.Hour)}, false, 0},
+		{"until at commit", ReadOptions{Until: commitTime}, true, 3},
+		{"until before", ReadOptions{Until: commitTime.Add(-time.Hour)}, false, 0},
+		{"matching author", ReadOptions{Authors: []string{"nobody", "@claudiocambra\\.com>$"}}, true, 3},
+		{"other author", ReadOptions{Authors: []string{"^Jean-Baptiste"}}, false, 0},
+		{"include directory", ReadOptions{IncludePaths: []string{"drivers/gpu/"}}, true, 1},
+		{"include pattern", ReadOptions{IncludePaths: []string{"*.md"}}, true, 1},
+		{"exclude directory", ReadOptions{IncludePaths: []string{"drivers"}, ExcludePaths: []string{"drivers/net"}}, true, 1},
+		{"include nothing", ReadOptions{IncludePaths: []string{"docs/"}}, false, 0},
+	}
+
+	for _, test := range tests {
+		filter, err := test.options.commitFilter()
+		if err != nil {
+			t.Fatalf("%s: error setting up filter: %s", test.name, err)
+		}
+
+		commit := newTestCommit()
+		if matches := filter(commit); matches != test.matches {
+			t.Fatalf("%s: expected filter to return %t", test.name, test.matches)
+		} else if matches && commit.NumFilesChanged != test.expectedNumFiles {
+			t.Fatalf("%s: expected %d files, received %d", test.name, test.expectedNumFiles, commit.NumFilesChanged)
+		}
+	}
+
+	commit := newTestCommit()
+	filter, err := ReadOptions{IncludePaths: []string{"drivers/gpu"}}.commitFilter()
+	if err != nil {
+		t.Fatalf("Error setting up filter: %s", err)
+	}
+
+	filter(commit)
+	expectedLineChanges := common.LineChanges{NumInsertions: 10, NumDeletions: 2}
+	if commit.LineChanges != expectedLineChanges {
+		t.Fatalf("Line changes were not recounted: %+v", commit.LineChanges)
+	}
+
+	// A typo in an author pattern must not quietly filter out every commit
+	if _, err := (ReadOptions{Authors: []string{"Claudio (Cambra"}}).commitFilter(); err == nil {
+		t.Fatalf("Expected an error setting up a filter with an invalid author pattern")
+	}
+}
+
+func TestReadOptionsGitLogArgs(t *testing.T) {
+	options := ReadOptions{
+		Refs:         []string{"main"},
+		Since:        time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC),
+		IncludePaths: []string{"drivers/gpu/"},
+		ExcludePaths: []string{"drivers/gpu/drm/"},
+		Authors:      []string{"@intel\\.com"},
+	}
+
+	expectedArgs := []string{"--no-merges", "--since=2018-01-01T00:00:00Z", "--full-history", "--extended-regexp", "--author=@intel\\.com"}
+	if args := options.gitLogArgs(); !cmp.Equal(expectedArgs, args) {
+		t.Fatalf("Unexpected git log arguments: %s", cmp.Diff(expectedArgs, args))
+	}
+
+	if args := options.gitLogRevisionArgs(); !cmp.Equal(options.Refs, args) {
+		t.Fatalf("Unexpected git log revisions: %s", cmp.Diff(options.Refs, args))
+	}
+
+	expectedPathArgs := []string{"--", "drivers/gpu/", ":(exclude)drivers/gpu/drm/"}
+	if args := options.gitLogPathArgs(); !cmp.Equal(expectedPathArgs, args) {
+		t.Fatalf("Unexpected git log pathspecs: %s", cmp.Diff(expectedPathArgs, args))
+	}
+}
+
+func TestReadOptionsSources(t *testing.T) {
+	repoPath := filepath.Join(t.TempDir(), "vlc")
+	repo, err := git.PlainInit(repoPath, false)
+	if err != nil {
+		t.Fatalf("Could not create test repository: %s", err)
+	}
+
+	testGoGitCommit(t, repo, repoPath, map[string]string{"README.md": "VLC\n"}, "Add readme\n", nil)
+	testGoGitCommit(t, repo, repoPath, map[string]string{"README.md": "VLC media player\n", "main.c": "int main() {}\n"}, "Add main\n", nil)
+	testGoGitCommit(t, repo, repoPath, map[string]string{"main.c": "int main() {\n}\n"}, "Format main\n", nil)
+
+	goGitSource, err := NewGoGitCommitSource(repoPath)
+	if err != nil {
+		t.Fatalf("Could not open test repository: %s", err)
+	}
+
+	tests := []struct {
+		options         ReadOptions
+		expectedNumFile []int
+	}{
+		{ReadOptions{Refs: []string{"HEAD~1"}}, []int{1, 2}},
+		{ReadOptions{Refs: []string{"HEAD~2..HEAD"}}, []int{2, 1}},
+		{ReadOptions{IncludePaths: []string{"main.c"}}, []int{1, 1}},
+		{ReadOptions{ExcludePaths: []string{"main.c"}}, []int{1, 1}},
+		{ReadOptions{Refs: []string{"HEAD~1"}, IncludePaths: []string{"*.c"}}, []int{1}},
+	}
+
+	for _, test := range tests {
+		gitCommits := readTestCommits(t, NewGitCommitSource(repoPath), test.options)
+		goGitCommits := readTestCommits(t, goGitSource, test.options)
+
+		numFiles := []int{}
+		for _, commit := range gitCommits {
+			numFiles = append(numFiles, commit.NumFilesChanged)
+		}
+
+		if !cmp.Equal(test.expectedNumFile, numFiles) {
+			t.Fatalf("Unexpected commits read with %+v: %s", test.options, cmp.Diff(test.expectedNumFile, numFiles))
+		} else if !cmp.Equal(gitCommits, goGitCommits) {
+			t.Fatalf("Sources read different commits with %+v: %s", test.options, cmp.Diff(gitCommits, goGitCommits))
+		}
+	}
+
+	invalidOptions := ReadOptions{Authors: []string{"Claudio (Cambra"}}
+	for _, source := range []CommitSource{NewGitCommitSource(repoPath), goGitSource} {
+		if commits, err := source.Commits(nil, invalidOptions); err == nil {
+			commits.Close()
+			t.Fatalf("Expected an error reading commits of %T with an invalid author pattern", source)
+		}
+	}
+}
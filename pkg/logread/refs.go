@@ -0,0 +1,75 @@
+package logread
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+const headRefName = "HEAD"
+
+// Returns the commit hash each branch, remote branch and HEAD currently points to, keyed by ref name.
+// These are the same refs that are read by ReadCommits.
+func RefTips(repoPath string) (map[string]string, error) {
+	cmd := exec.Command("git",
+		"-C", repoPath,
+		"for-each-ref",
+		"--format=%(objectname) %(refname)",
+		"refs/heads",
+		"refs/remotes")
+
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("error listing refs: %w", err)
+	}
+
+	tips := map[string]string{}
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+
+	for scanner.Scan() {
+		splitLine := strings.SplitN(scanner.Text(), " ", 2)
+		if len(splitLine) != 2 {
+			continue
+		}
+
+		tips[splitLine[1]] = splitLine[0]
+	}
+
+	headCmd := exec.Command("git", "-C", repoPath, "rev-parse", "--verify", "-q", headRefName)
+	headOut, err := headCmd.Output()
+	if err == nil {
+		tips[headRefName] = strings.TrimSpace(string(headOut))
+	}
+
+	return tips, nil
+}
+
+// Filters out commit hashes that are not present in the repository, e.g. after a force push
+// followed by garbage collection. git log fails when asked to exclude unknown revisions.
+func ExistingCommits(repoPath string, commitIds []string) ([]string, error) {
+	if len(commitIds) == 0 {
+		return commitIds, nil
+	}
+
+	cmd := exec.Command("git", "-C", repoPath, "cat-file", "--batch-check=%(objectname) %(objecttype)")
+	cmd.Stdin = strings.NewReader(strings.Join(commitIds, "\n") + "\n")
+
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("error checking for existing commits: %w", err)
+	}
+
+	existingIds := []string{}
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+
+	for scanner.Scan() {
+		splitLine := strings.Split(scanner.Text(), " ")
+		if len(splitLine) == 2 && splitLine[1] == "commit" {
+			existingIds = append(existingIds, splitLine[0])
+		}
+	}
+
+	return existingIds, nil
+}
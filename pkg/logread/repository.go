@@ -0,0 +1,56 @@
+package logread
+
+import (
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/claucambra/commit-analysis-tool/pkg/common"
+)
+
+const originRemoteName = "origin"
+
+// Name from the origin url when there is one, otherwise from the repository directory
+func repositoryName(originUrl string, localPath string) string {
+	if repoName := common.RepoNameFromUrl(originUrl); repoName != "" {
+		return repoName
+	}
+
+	return strings.TrimSuffix(filepath.Base(localPath), ".git")
+}
+
+func gitOutput(repoPath string, args ...string) (string, error) {
+	cmd := exec.Command("git", append([]string{"-C", repoPath}, args...)...)
+	out, err := cmd.Output()
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(string(out)), nil
+}
+
+// Reads the identity of the repository at repoPath. The name is taken from the origin url when
+// there is one, so that clones at different paths are recognised as the same repository.
+func ReadRepository(repoPath string) (*common.Repository, error) {
+	localPath, err := filepath.Abs(repoPath)
+	if err != nil {
+		return nil, err
+	}
+
+	// Fails if there is no origin remote, which is fine
+	originUrl, _ := gitOutput(repoPath, "config", "--get", "remote."+originRemoteName+".url")
+
+	defaultBranch, err := gitOutput(repoPath, "symbolic-ref", "--short", "refs/remotes/"+originRemoteName+"/HEAD")
+	if err == nil {
+		defaultBranch = strings.TrimPrefix(defaultBranch, originRemoteName+"/")
+	} else if defaultBranch, err = gitOutput(repoPath, "symbolic-ref", "--short", "HEAD"); err != nil {
+		defaultBranch = ""
+	}
+
+	return &common.Repository{
+		Name:          repositoryName(originUrl, localPath),
+		OriginUrl:     originUrl,
+		LocalPath:     localPath,
+		DefaultBranch: defaultBranch,
+	}, nil
+}
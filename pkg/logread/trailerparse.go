@@ -0,0 +1,90 @@
+package logread
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/claucambra/commit-analysis-tool/pkg/common"
+)
+
+// Same rule git uses: trailers are in the last paragraph of the message, which must consist of at
+// least this proportion of trailer lines if it also contains other lines
+const minTrailerLineRatio = 0.25
+
+var trailerLineRegex = regexp.MustCompile(`^([A-Za-z0-9][A-Za-z0-9-]*)\s*:\s*(.*)$`)
+var trailerPersonRegex = regexp.MustCompile(`^(.*?)\s*<([^<>]*)>$`)
+
+// Parse the trailers at the end of a commit body, e.g.
+//
+//	Some description of the change.
+//
+//	Co-authored-by: Jean-Baptiste Kempf <jb@videolan.org>
+//	Signed-off-by: Claudio Cambra <developer@claudiocambra.com>
+//
+// Lines starting with whitespace continue the value of the previous trailer.
+func ParseTrailers(body string) []*common.Trailer {
+	paragraphs := strings.Split(strings.TrimSpace(strings.ReplaceAll(body, "\r\n", "\n")), "\n\n")
+	lastParagraph := paragraphs[len(paragraphs)-1]
+
+	var trailers []*common.Trailer
+	var lastTrailer *common.Trailer
+	numLines := 0
+	numOtherLines := 0
+	hasKnownTrailer := false
+
+	for _, line := range strings.Split(lastParagraph, "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		if lastTrailer != nil && (line[0] == ' ' || line[0] == '\t') {
+			lastTrailer.Value += " " + strings.TrimSpace(line)
+			continue
+		}
+
+		numLines++
+
+		match := trailerLineRegex.FindStringSubmatch(line)
+		if match == nil {
+			numOtherLines++
+			lastTrailer = nil
+			continue
+		}
+
+		lastTrailer = &common.Trailer{
+			Kind:  common.TrailerKindForKey(match[1]),
+			Key:   match[1],
+			Value: strings.TrimSpace(match[2]),
+		}
+
+		hasKnownTrailer = hasKnownTrailer || lastTrailer.Kind != common.OtherTrailer
+		trailers = append(trailers, lastTrailer)
+	}
+
+	if len(trailers) == 0 {
+		return nil
+	} else if numOtherLines > 0 {
+		trailerRatio := float64(len(trailers)) / float64(numLines)
+		if !hasKnownTrailer || trailerRatio < minTrailerLineRatio {
+			return nil
+		}
+	}
+
+	for _, trailer := range trailers {
+		trailer.Person = parseTrailerPerson(trailer.Value)
+	}
+
+	return trailers
+}
+
+func parseTrailerPerson(value string) common.Person {
+	match := trailerPersonRegex.FindStringSubmatch(value)
+	if match == nil || !strings.Contains(match[2], "@") {
+		return common.Person{}
+	}
+
+	return common.Person{
+		Name:  match[1],
+		Email: strings.TrimSpace(match[2]),
+	}
+}
@@ -0,0 +1,65 @@
+package logread
+
+import (
+	"testing"
+
+	"github.com/claucambra/commit-analysis-tool/pkg/common"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseTrailers(t *testing.T) {
+	testBody := `Breakpad.xib is in the old Xcode3 format: upgrade it.
+
+Co-authored-by: Jean-Baptiste Kempf <jb@videolan.org>
+Reviewed-by: Felix Paul Kühne
+ <fkuehne@videolan.org>
+Fixes: #1234
+Signed-off-by: Claudio Cambra <developer@claudiocambra.com>
+`
+
+	expectedTrailers := []*common.Trailer{
+		{
+			Kind:   common.CoAuthoredByTrailer,
+			Key:    "Co-authored-by",
+			Value:  "Jean-Baptiste Kempf <jb@videolan.org>",
+			Person: common.Person{Name: "Jean-Baptiste Kempf", Email: "jb@videolan.org"},
+		},
+		{
+			Kind:   common.ReviewedByTrailer,
+			Key:    "Reviewed-by",
+			Value:  "Felix Paul Kühne <fkuehne@videolan.org>",
+			Person: common.Person{Name: "Felix Paul Kühne", Email: "fkuehne@videolan.org"},
+		},
+		{
+			Kind:  common.OtherTrailer,
+			Key:   "Fixes",
+			Value: "#1234",
+		},
+		{
+			Kind:   common.SignedOffByTrailer,
+			Key:    "Signed-off-by",
+			Value:  "Claudio Cambra <developer@claudiocambra.com>",
+			Person: common.Person{Name: "Claudio Cambra", Email: "developer@claudiocambra.com"},
+		},
+	}
+
+	trailers := ParseTrailers(testBody)
+	if !cmp.Equal(trailers, expectedTrailers) {
+		t.Fatalf("Parsed trailers do not equal expected trailers. %s", cmp.Diff(expectedTrailers, trailers))
+	}
+}
+
+func TestParseTrailersWithoutTrailerParagraph(t *testing.T) {
+	testBodies := []string{
+		"",
+		"This is a commit body",
+		"Signed-off-by: Claudio Cambra <developer@claudiocambra.com>\n\nThe trailers are not in the last paragraph.",
+		"Note: this paragraph\nis mostly text\nwithout any\nknown trailers",
+	}
+
+	for _, testBody := range testBodies {
+		if trailers := ParseTrailers(testBody); trailers != nil {
+			t.Fatalf("Received trailers for body without trailers %q: %+v", testBody, trailers)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package authorgroups
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/claucambra/commit-analysis-tool/pkg/common"
+)
+
+const (
+	// Active in consecutive months, see ContinuousActivity
+	ContinuousActivityKind = "continuous"
+	// Active from the first to the last commit, see FirstToLastActivity
+	FirstToLastActivityKind = "first-to-last"
+	// Active in consecutive windows with enough commits, see WindowActivity
+	WindowActivityKind = "window"
+)
+
+var ActivityKinds = []string{ContinuousActivityKind, FirstToLastActivityKind, WindowActivityKind}
+
+// How long an author stays active, which survival reports treat as the author's lifetime
+type ActivityDefinition interface {
+	// First month the author was active in (see monthNumber) and the number of months they stayed
+	// active for, given the number of commits they made in each month. Authors who were never
+	// active stay so for 0 months.
+	Tenure(monthCommits common.YearMonthCount) (int, int)
+	// Number of months after an author's activity ends in which a commit would still extend it, so
+	// authors whose activity ended this recently before the data ends may still be active
+	PendingMonths() int
+}
+
+// Settings of the activity definitions, each using those relevant to it
+type ActivityOptions struct {
+	// See ContinuousActivity.GapMonths
+	GapMonths int
+	// See WindowActivity
+	WindowMonths     int
+	MinWindowCommits int
+}
+
+func NewActivityDefinition(kind string, options ActivityOptions) (ActivityDefinition, error) {
+	switch kind {
+	case ContinuousActivityKind, "":
+		if options.GapMonths < 0 {
+			return nil, fmt.Errorf("activity gap of %d months is negative", options.GapMonths)
+		}
+
+		return &ContinuousActivity{GapMonths: options.GapMonths}, nil
+	case FirstToLastActivityKind:
+		return &FirstToLastActivity{}, nil
+	case WindowActivityKind:
+		if options.WindowMonths < 1 || options.MinWindowCommits < 1 {
+			return nil, fmt.Errorf("activity windows of %d months with %d commits need at least one month and one commit", options.WindowMonths, options.MinWindowCommits)
+		}
+
+		return &WindowActivity{WindowMonths: options.WindowMonths, MinCommits: options.MinWindowCommits}, nil
+	default:
+		return nil, fmt.Errorf("unknown activity definition %q, expected one of %v", kind, ActivityKinds)
+	}
+}
+
+// Number of commits in each month with commits, keyed by monthNumber, and those months in order
+func monthNumberCommits(monthCommits common.YearMonthCount) (map[int]int, []int) {
+	commits := map[int]int{}
+	for year, months := range monthCommits {
+		for month, count := range months {
+			if count > 0 {
+				commits[monthNumber(year, month)] = count
+			}
+		}
+	}
+
+	activeMonths := make([]int, 0, len(commits))
+	for month := range commits {
+		activeMonths = append(activeMonths, month)
+	}
+
+	sort.Ints(activeMonths)
+	return commits, activeMonths
+}
+
+// Active from the first month with commits until a run of more than GapMonths months without
+// commits. Without a gap, an author stops being active at the first month they did not commit in.
+type ContinuousActivity struct {
+	GapMonths int
+}
+
+func (activity *ContinuousActivity) Tenure(monthCommits common.YearMonthCount) (int, int) {
+	_, activeMonths := monthNumberCommits(monthCommits)
+	if len(activeMonths) == 0 {
+		return 0, 0
+	}
+
+	firstMonth, lastMonth := activeMonths[0], activeMonths[0]
+	for _, month := range activeMonths[1:] {
+		if month-lastMonth-1 > activity.GapMonths {
+			break
+		}
+
+		lastMonth = month
+	}
+
+	return firstMonth, lastMonth - firstMonth + 1
+}
+
+func (activity *ContinuousActivity) PendingMonths() int {
+	return activity.GapMonths
+}
+
+// Active from the month of the first commit to the month of the last, however long the gaps
+// between them
+type FirstToLastActivity struct{}
+
+func (activity *FirstToLastActivity) Tenure(monthCommits common.YearMonthCount) (int, int) {
+	_, activeMonths := monthNumberCommits(monthCommits)
+	if len(activeMonths) == 0 {
+		return 0, 0
+	}
+
+	return activeMonths[0], activeMonths[len(activeMonths)-1] - activeMonths[0] + 1
+}
+
+// Any later commit extends the activity, so how long to wait for one is left to the survival
+// report's CensorMonths
+func (activity *FirstToLastActivity) PendingMonths() int {
+	return 0
+}
+
+// Active in consecutive windows of WindowMonths months with at least MinCommits commits each.
+// Windows start at the author's first commit, and activity at the first window with enough
+// commits.
+type WindowActivity struct {
+	WindowMonths int
+	MinCommits   int
+}
+
+func (activity *WindowActivity) Tenure(monthCommits common.YearMonthCount) (int, int) {
+	commits, activeMonths := monthNumberCommits(monthCommits)
+	if len(activeMonths) == 0 {
+		return 0, 0
+	}
+
+	windowMonths := common.MaxInt(activity.WindowMonths, 1)
+	firstMonth := -1
+	numWindows := 0
+
+	for windowStart := activeMonths[0]; windowStart <= activeMonths[len(activeMonths)-1]; windowStart += windowMonths {
+		windowCommits := 0
+		for month := windowStart; month < windowStart+windowMonths; month++ {
+			windowCommits += commits[month]
+		}
+
+		if windowCommits >= activity.MinCommits {
+			if firstMonth < 0 {
+				firstMonth = windowStart
+			}
+
+			numWindows++
+		} else if firstMonth >= 0 {
+			break
+		}
+	}
+
+	if firstMonth < 0 {
+		return 0, 0
+	}
+
+	return firstMonth, numWindows * windowMonths
+}
+
+// The window after the last active one may still get enough commits until it is over
+func (activity *WindowActivity) PendingMonths() int {
+	return common.MaxInt(activity.WindowMonths, 1)
+}
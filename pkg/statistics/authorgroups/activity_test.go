@@ -0,0 +1,54 @@
+package authorgroups
+
+import (
+	"testing"
+
+	"github.com/claucambra/commit-analysis-tool/pkg/common"
+)
+
+func TestActivityTenure(t *testing.T) {
+	monthCommits := common.YearMonthCount{
+		2022: {1: 3, 2: 1, 4: 2, 5: 1, 9: 1},
+		2023: {1: 4},
+	}
+
+	firstMonth := monthNumber(2022, 1)
+	testCases := []struct {
+		activity           ActivityDefinition
+		expectedFirstMonth int
+		expectedNumMonths  int
+	}{
+		{&ContinuousActivity{}, firstMonth, 2},
+		{&ContinuousActivity{GapMonths: 1}, firstMonth, 5},
+		{&ContinuousActivity{GapMonths: 3}, firstMonth, 13},
+		{&FirstToLastActivity{}, firstMonth, 13},
+		{&WindowActivity{WindowMonths: 3, MinCommits: 2}, firstMonth, 6},
+		{&WindowActivity{WindowMonths: 2, MinCommits: 4}, firstMonth, 2},
+		// Activity starts at the first window with enough commits
+		{&WindowActivity{WindowMonths: 1, MinCommits: 4}, monthNumber(2023, 1), 1},
+		{&WindowActivity{WindowMonths: 3, MinCommits: 5}, 0, 0},
+	}
+
+	for i, testCase := range testCases {
+		firstMonth, numMonths := testCase.activity.Tenure(monthCommits)
+		if firstMonth != testCase.expectedFirstMonth || numMonths != testCase.expectedNumMonths {
+			t.Fatalf("Unexpected tenure of %d months from month %d for case %d, expected %d months from month %d",
+				numMonths, firstMonth, i, testCase.expectedNumMonths, testCase.expectedFirstMonth)
+		}
+	}
+
+	for _, kind := range ActivityKinds {
+		activity, err := NewActivityDefinition(kind, ActivityOptions{WindowMonths: 1, MinWindowCommits: 1})
+		if err != nil {
+			t.Fatalf("Error creating %s activity definition: %s", kind, err)
+		} else if _, numMonths := activity.Tenure(common.YearMonthCount{}); numMonths != 0 {
+			t.Fatalf("Unexpected tenure of %d months without commits for %s activity", numMonths, kind)
+		}
+	}
+
+	if _, err := NewActivityDefinition(WindowActivityKind, ActivityOptions{}); err == nil {
+		t.Fatalf("Expected an error creating an activity definition with empty windows")
+	} else if _, err := NewActivityDefinition("unknown", ActivityOptions{}); err == nil {
+		t.Fatalf("Expected an error creating an unknown activity definition")
+	}
+}
@@ -1,15 +1,25 @@
 package corpimpact
 
 import (
+	"context"
+	"fmt"
+	"log"
+	"math"
 	"strconv"
 	"time"
 
-	"github.com/claucambra/commit-analysis-tool/internal/db"
 	"github.com/claucambra/commit-analysis-tool/pkg/common"
+	"github.com/claucambra/commit-analysis-tool/pkg/statistics"
 	"github.com/claucambra/commit-analysis-tool/pkg/statistics/authorgroups"
 	"github.com/claucambra/commit-analysis-tool/pkg/statistics/commitimpact"
+	"github.com/claucambra/commit-analysis-tool/pkg/store"
 )
 
+// Months the groups' monthly figures are shifted by at most to find the lag they correlate best at,
+// and months of past figures Granger tests predict from, by default
+const DefaultCorrelMaxLag = 12
+const DefaultGrangerLags = 3
+
 type CorporateReport struct {
 	CorporateGroupName string
 	GroupsOfDomains    map[string][]string
@@ -21,32 +31,74 @@ type CorporateReport struct {
 	InsertionsCorrel float64
 	DeletionsCorrel  float64
 	AuthorsCorrel    float64
+	// Lagged correlations of the groups' monthly figures, at positive lags of the community group
+	// following the corporate group
+	InsertionsCrossCorrel *statistics.CrossCorrelation
+	DeletionsCrossCorrel  *statistics.CrossCorrelation
+	AuthorsCrossCorrel    *statistics.CrossCorrelation
+	// Whether the corporate group's monthly figures help predict the community group's
+	InsertionsGranger *statistics.GrangerTest
+	DeletionsGranger  *statistics.GrangerTest
+	AuthorsGranger    *statistics.GrangerTest
+
+	// How the lagged correlations and Granger tests compare the monthly figures: the lags
+	// correlations are measured at, from -CorrelMaxLag to CorrelMaxLag months, how correlation is
+	// measured, how the figures are transformed first, differencing them by default, and how many
+	// months of past figures Granger tests predict from
+	CorrelMaxLag    int
+	CorrelMethod    statistics.CorrelationMethod
+	CorrelTransform statistics.SeriesTransform
+	GrangerLags     int
 
 	DomainGroupsReport           *authorgroups.DomainGroupsReport
 	CorporateGroupSurvivalReport *authorgroups.GroupSurvivalReport
 	CommunityGroupSurvivalReport *authorgroups.GroupSurvivalReport
+	// Whether corporate and community authors stay active for different lengths of time
+	SurvivalLogRank *statistics.LogRankTest
+	// Weighting of SurvivalLogRank, unweighted by default
+	SurvivalWeighting statistics.LogRankWeighting
+	// See GroupSurvivalReport.Activity and GroupSurvivalReport.CensorMonths
+	SurvivalActivity     authorgroups.ActivityDefinition
+	SurvivalCensorMonths int
 
 	CorporateCommitImpactReport *commitimpact.CommitImpactReport
 	CommunityCommitImpactReport *commitimpact.CommitImpactReport
 
-	sqlb *db.SQLiteBackend
+	TopologyReport *authorgroups.TopologyReport
+
+	// Only commits of this repository are reported on, or of all repositories if empty
+	RepoName string
+	// See DomainGroupsReport.CreditCoAuthors
+	CreditCoAuthors bool
+
+	store store.Store
 }
 
-func NewCorporateReport(groupsOfDomains map[string][]string, sqlb *db.SQLiteBackend, corporateGroupName string) *CorporateReport {
+func NewCorporateReport(groupsOfDomains map[string][]string, commitStore store.Store, corporateGroupName string, repoName string) *CorporateReport {
 	if corporateGroupName == "" {
 		corporateGroupName = "Corporate"
 	}
 
 	return &CorporateReport{
-		CorporateGroupName: corporateGroupName,
-		GroupsOfDomains:    groupsOfDomains,
-		sqlb:               sqlb,
+		CorporateGroupName:   corporateGroupName,
+		GroupsOfDomains:      groupsOfDomains,
+		CorrelMaxLag:         DefaultCorrelMaxLag,
+		CorrelTransform:      statistics.DifferenceTransform,
+		GrangerLags:          DefaultGrangerLags,
+		SurvivalActivity:     &authorgroups.ContinuousActivity{},
+		SurvivalCensorMonths: authorgroups.DefaultCensorMonths,
+		RepoName:             repoName,
+		store:                commitStore,
 	}
 }
 
-func (cr *CorporateReport) Generate() {
-	domainGroupsReport := authorgroups.NewDomainGroupsReport(cr.GroupsOfDomains, cr.sqlb)
-	domainGroupsReport.Generate()
+func (cr *CorporateReport) Generate(ctx context.Context) error {
+	domainGroupsReport := authorgroups.NewDomainGroupsReport(cr.GroupsOfDomains, cr.store, cr.RepoName)
+	domainGroupsReport.CreditCoAuthors = cr.CreditCoAuthors
+	if err := domainGroupsReport.Generate(ctx); err != nil {
+		return err
+	}
+
 	cr.DomainGroupsReport = domainGroupsReport
 
 	corpGroup := domainGroupsReport.GroupData(cr.CorporateGroupName)
@@ -55,28 +107,119 @@ func (cr *CorporateReport) Generate() {
 	commGroup := domainGroupsReport.UnknownGroupData()
 	cr.CommunityGroup = commGroup
 
-	corpYearMonthInsertsMap, corpYearMonthDeletesMap, corpYearMonthAuthorsMap := cr.CorporateGroup.Commits.YearMonthCounts()
-	commYearMonthInsertsMap, commYearMonthDeletesMap, commYearMonthAuthorsMap := cr.CommunityGroup.Commits.YearMonthCounts()
+	cr.InsertionsCorrel = common.CorrelateYearMonthCounts(corpGroup.YearMonthInsertions, commGroup.YearMonthInsertions)
+	cr.DeletionsCorrel = common.CorrelateYearMonthCounts(corpGroup.YearMonthDeletions, commGroup.YearMonthDeletions)
+	cr.AuthorsCorrel = common.CorrelateYearMonthCounts(corpGroup.YearMonthAuthors, commGroup.YearMonthAuthors)
+
+	var err error
+	if cr.InsertionsCrossCorrel, cr.InsertionsGranger, err = cr.compareMonthlyCounts("insertions", corpGroup.YearMonthInsertions, commGroup.YearMonthInsertions); err != nil {
+		return err
+	} else if cr.DeletionsCrossCorrel, cr.DeletionsGranger, err = cr.compareMonthlyCounts("deletions", corpGroup.YearMonthDeletions, commGroup.YearMonthDeletions); err != nil {
+		return err
+	} else if cr.AuthorsCrossCorrel, cr.AuthorsGranger, err = cr.compareMonthlyCounts("authors", corpGroup.YearMonthAuthors, commGroup.YearMonthAuthors); err != nil {
+		return err
+	}
 
-	cr.InsertionsCorrel = common.CorrelateYearMonthCounts(corpYearMonthInsertsMap, commYearMonthInsertsMap)
-	cr.DeletionsCorrel = common.CorrelateYearMonthCounts(corpYearMonthDeletesMap, commYearMonthDeletesMap)
-	cr.AuthorsCorrel = common.CorrelateYearMonthCounts(corpYearMonthAuthorsMap, commYearMonthAuthorsMap)
+	corpGroupSurvival := authorgroups.NewGroupSurvivalReport(cr.store, corpGroup.Authors, cr.RepoName)
+	corpGroupSurvival.Activity = cr.SurvivalActivity
+	corpGroupSurvival.CensorMonths = cr.SurvivalCensorMonths
+	if err := corpGroupSurvival.Generate(ctx); err != nil {
+		return fmt.Errorf("corporate group survival: %w", err)
+	}
 
-	corpGroupSurvival := authorgroups.NewGroupSurvivalReport(cr.sqlb, corpGroup.Authors)
-	corpGroupSurvival.Generate()
 	cr.CorporateGroupSurvivalReport = corpGroupSurvival
 
-	commGroupSurvival := authorgroups.NewGroupSurvivalReport(cr.sqlb, commGroup.Authors)
-	commGroupSurvival.Generate()
+	commGroupSurvival := authorgroups.NewGroupSurvivalReport(cr.store, commGroup.Authors, cr.RepoName)
+	commGroupSurvival.Activity = cr.SurvivalActivity
+	commGroupSurvival.CensorMonths = cr.SurvivalCensorMonths
+	if err := commGroupSurvival.Generate(ctx); err != nil {
+		return fmt.Errorf("community group survival: %w", err)
+	}
+
 	cr.CommunityGroupSurvivalReport = commGroupSurvival
 
-	corpGroupImpact := commitimpact.NewCommitImpactReport(corpGroup.Commits)
-	corpGroupImpact.Generate()
+	survivalGroups := [][]statistics.SurvivalObservation{corpGroupSurvival.Observations(), commGroupSurvival.Observations()}
+	survivalLogRank, err := statistics.LogRank(survivalGroups, cr.SurvivalWeighting)
+	if err != nil {
+		return fmt.Errorf("comparing group survival: %w", err)
+	}
+
+	cr.SurvivalLogRank = survivalLogRank
+	log.Printf("Compared corporate and community author survival, chi-square %f with p-value %f", survivalLogRank.Statistic, survivalLogRank.PValue)
+
+	// Impacts are scored from commit messages, so these are the only figures needing the commits,
+	// which are read a page at a time
+	corpCommits, err := domainGroupsReport.GroupCommitIterator(ctx, cr.CorporateGroupName)
+	if err != nil {
+		return fmt.Errorf("retrieving corporate group commits: %w", err)
+	}
+
+	corpGroupImpact := commitimpact.NewCommitImpactReport(corpCommits)
+	if err := corpGroupImpact.Generate(ctx); err != nil {
+		return fmt.Errorf("corporate group impact: %w", err)
+	}
+
 	cr.CorporateCommitImpactReport = corpGroupImpact
 
-	commGroupImpact := commitimpact.NewCommitImpactReport(commGroup.Commits)
-	commGroupImpact.Generate()
+	commCommits, err := domainGroupsReport.GroupCommitIterator(ctx, "")
+	if err != nil {
+		return fmt.Errorf("retrieving community group commits: %w", err)
+	}
+
+	commGroupImpact := commitimpact.NewCommitImpactReport(commCommits)
+	if err := commGroupImpact.Generate(ctx); err != nil {
+		return fmt.Errorf("community group impact: %w", err)
+	}
+
 	cr.CommunityCommitImpactReport = commGroupImpact
+
+	topologyReport := authorgroups.NewTopologyReport(cr.GroupsOfDomains, cr.store, cr.RepoName)
+	if err := topologyReport.Generate(ctx); err != nil {
+		return err
+	}
+
+	cr.TopologyReport = topologyReport
+	return nil
+}
+
+// Lagged correlation and Granger test of the corporate and community groups' monthly counts
+func (cr *CorporateReport) compareMonthlyCounts(name string, corpCounts common.YearMonthCount, commCounts common.YearMonthCount) (
+	*statistics.CrossCorrelation,
+	*statistics.GrangerTest,
+	error,
+) {
+	corpSeries, commSeries := common.EqualisedYearMonthSeries(corpCounts, commCounts)
+
+	crossCorrel, err := statistics.CrossCorrelate(corpSeries, commSeries, cr.CorrelMaxLag, cr.CorrelMethod, cr.CorrelTransform)
+	if err != nil {
+		return nil, nil, fmt.Errorf("correlating group %s: %w", name, err)
+	}
+
+	granger, err := statistics.GrangerCausality(corpSeries, commSeries, cr.GrangerLags, cr.CorrelTransform)
+	if err != nil {
+		return nil, nil, fmt.Errorf("testing group %s for Granger causality: %w", name, err)
+	}
+
+	if best := crossCorrel.Best; best != nil {
+		log.Printf("Monthly %s of the groups correlate best at a lag of %d months, correlation %f with adjusted p-value %f", name, best.Lag, best.Correlation, crossCorrel.BestAdjustedPValue)
+	}
+
+	return crossCorrel, granger, nil
+}
+
+// Best lag, its correlation and adjusted p-value, and the Granger test's p-value, as CSV values
+func crossCorrelCSVValues(crossCorrel *statistics.CrossCorrelation, granger *statistics.GrangerTest) []string {
+	lag, correl := math.NaN(), math.NaN()
+	if crossCorrel.Best != nil {
+		lag, correl = float64(crossCorrel.Best.Lag), crossCorrel.Best.Correlation
+	}
+
+	return []string{
+		strconv.FormatFloat(lag, 'f', -1, 64),
+		strconv.FormatFloat(correl, 'f', -1, 64),
+		strconv.FormatFloat(crossCorrel.BestAdjustedPValue, 'f', -1, 64),
+		strconv.FormatFloat(granger.PValue, 'f', -1, 64),
+	}
 }
 
 func (cr *CorporateReport) CSVString(name string, includeHeader bool) [][]string {
@@ -104,7 +247,7 @@ func (cr *CorporateReport) CSVString(name string, includeHeader bool) [][]string
 
 	csvfiedReport := []string{
 		name,
-		strconv.FormatInt(int64(len(cr.DomainGroupsReport.TotalCommits)), 10),
+		strconv.FormatInt(int64(cr.DomainGroupsReport.TotalNumCommits), 10),
 		strconv.FormatInt(int64(len(cr.DomainGroupsReport.TotalAuthors)), 10),
 		strconv.FormatInt(int64(cr.DomainGroupsReport.TotalChanges.NumInsertions), 10),
 		strconv.FormatInt(int64(cr.DomainGroupsReport.TotalChanges.NumDeletions), 10),
@@ -123,9 +266,20 @@ func (cr *CorporateReport) CSVString(name string, includeHeader bool) [][]string
 		strconv.FormatFloat(cr.InsertionsCorrel, 'f', -1, 64),
 		strconv.FormatFloat(cr.DeletionsCorrel, 'f', -1, 64),
 		strconv.FormatFloat(cr.AuthorsCorrel, 'f', -1, 64),
+	}
+
+	csvfiedReport = append(csvfiedReport, crossCorrelCSVValues(cr.InsertionsCrossCorrel, cr.InsertionsGranger)...)
+	csvfiedReport = append(csvfiedReport, crossCorrelCSVValues(cr.DeletionsCrossCorrel, cr.DeletionsGranger)...)
+	csvfiedReport = append(csvfiedReport, crossCorrelCSVValues(cr.AuthorsCrossCorrel, cr.AuthorsGranger)...)
+	csvfiedReport = append(csvfiedReport,
 		strconv.FormatFloat(cr.CorporateCommitImpactReport.MeanImpact, 'f', -1, 64),
 		strconv.FormatFloat(cr.CommunityCommitImpactReport.MeanImpact, 'f', -1, 64),
-	}
+		strconv.FormatFloat(cr.TopologyReport.MergeFrequency, 'f', -1, 64),
+		strconv.FormatFloat(cr.TopologyReport.GroupMergeShare(cr.CorporateGroupName), 'f', -1, 64),
+		strconv.FormatFloat(cr.TopologyReport.GroupMergeShare(""), 'f', -1, 64),
+		strconv.FormatFloat(cr.SurvivalLogRank.Statistic, 'f', -1, 64),
+		strconv.FormatFloat(cr.SurvivalLogRank.PValue, 'f', -1, 64),
+	)
 
 	for i := 0; i < numSurvValuesToWrite; i++ {
 		csvfiedReport = append(csvfiedReport, strconv.FormatFloat(safeCorpSurvivalValues[i], 'f', -1, 64))
@@ -158,9 +312,26 @@ func (cr *CorporateReport) CSVString(name string, includeHeader bool) [][]string
 			"insertions_correl",
 			"deletions_correl",
 			"authors_correl",
+		}
+
+		for _, figure := range []string{"insertions", "deletions", "authors"} {
+			header = append(header,
+				figure+"_best_lag",
+				figure+"_best_lag_correl",
+				figure+"_best_lag_p",
+				figure+"_granger_p",
+			)
+		}
+
+		header = append(header,
 			"mean_corp_impact",
 			"mean_comm_impact",
-		}
+			"merge_freq",
+			"corp_merges_pc",
+			"comm_merges_pc",
+			"surv_logrank_chisq",
+			"surv_logrank_p",
+		)
 
 		for i := 0; i < numSurvValuesToWrite; i++ {
 			header = append(header, "corp_surv_"+strconv.FormatInt(int64(i), 10))
@@ -190,11 +361,15 @@ func setNumIfChildMap(childMap map[int]int, childMapIsInMap bool, childIndex int
 
 func (cr *CorporateReport) CSVChangesString(repoName string) [][]string {
 	// map[Year]map[Month]NumberOfChanges
-	commYearMonthInsertsMap, commYearMonthDeletesMap, commYearMonthAuthorsMap := cr.CommunityGroup.Commits.YearMonthCounts()
-	corpYearMonthInsertsMap, corpYearMonthDeletesMap, corpYearMonthAuthorsMap := cr.CorporateGroup.Commits.YearMonthCounts()
-
-	sortedCorpYears := cr.CommunityGroup.Commits.YearRange(false)
-	sortedCommYears := cr.CommunityGroup.Commits.YearRange(false)
+	commYearMonthInsertsMap := cr.CommunityGroup.YearMonthInsertions
+	commYearMonthDeletesMap := cr.CommunityGroup.YearMonthDeletions
+	commYearMonthAuthorsMap := cr.CommunityGroup.YearMonthAuthors
+	corpYearMonthInsertsMap := cr.CorporateGroup.YearMonthInsertions
+	corpYearMonthDeletesMap := cr.CorporateGroup.YearMonthDeletions
+	corpYearMonthAuthorsMap := cr.CorporateGroup.YearMonthAuthors
+
+	sortedCorpYears := cr.CorporateGroup.YearRange()
+	sortedCommYears := cr.CommunityGroup.YearRange()
 	var firstYear int
 
 	if len(sortedCorpYears) == 0 && len(sortedCommYears) == 0 {
@@ -0,0 +1,104 @@
+package corpimpact
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/claucambra/commit-analysis-tool/pkg/common"
+	"github.com/claucambra/commit-analysis-tool/pkg/store"
+)
+
+func TestCorporateReportLaggedCorrelation(t *testing.T) {
+	ctx := context.Background()
+	commitStore := store.NewMemoryStore()
+
+	// Community authors commit as much as corporate authors did two months before
+	corpMonthCommits := []int{1, 4, 2, 6, 3, 1, 5, 2, 7, 3, 4, 1, 6, 2, 5, 3, 1, 4, 2, 6, 3, 5, 1, 2}
+	for month, numCommits := range corpMonthCommits {
+		for i := 0; i < numCommits; i++ {
+			for domain, monthOffset := range map[string]int{"corp.com": 0, "community.org": 2} {
+				email := fmt.Sprintf("dev%d@%s", i, domain)
+				commitMonth := month + monthOffset
+				commit := &common.Commit{
+					Id:         fmt.Sprintf("%s-%d", email, commitMonth),
+					Author:     common.Person{Email: email},
+					AuthorTime: time.Date(2021, time.Month(1+commitMonth), 10, 12, 0, 0, 0, time.UTC).Unix(),
+					Changes:    common.Changes{LineChanges: common.LineChanges{NumInsertions: 10}},
+				}
+
+				if err := commitStore.AddCommit(ctx, commit); err != nil {
+					t.Fatalf("Error adding commit: %s", err)
+				}
+			}
+		}
+	}
+
+	groups := map[string][]string{"Corporate": {`^corp\.com$`}}
+	report := NewCorporateReport(groups, commitStore, "Corporate", "")
+	if err := report.Generate(ctx); err != nil {
+		t.Fatalf("Error generating report: %s", err)
+	}
+
+	if len(report.AuthorsCrossCorrel.Lags) != 2*DefaultCorrelMaxLag+1 {
+		t.Fatalf("Unexpected number of lags %d", len(report.AuthorsCrossCorrel.Lags))
+	} else if best := report.AuthorsCrossCorrel.Best; best == nil || best.Lag != 2 {
+		t.Fatalf("Unexpected best lag %+v of community authors following corporate authors", best)
+	} else if report.AuthorsCrossCorrel.BestAdjustedPValue > 0.01 {
+		t.Fatalf("Unexpected adjusted p-value %f of the best lag", report.AuthorsCrossCorrel.BestAdjustedPValue)
+	} else if report.AuthorsGranger.PValue > 0.01 {
+		t.Fatalf("Unexpected Granger test p-value %f of community authors following corporate authors", report.AuthorsGranger.PValue)
+	}
+
+	csvReport := report.CSVString("test", true)
+	if len(csvReport) != 2 || len(csvReport[0]) != len(csvReport[1]) {
+		t.Fatalf("CSV header of %d columns does not match values of %d columns", len(csvReport[0]), len(csvReport[1]))
+	}
+}
+
+// Impacts are scored from commits read a page at a time, so more than a page of commits is scored
+func TestCorporateReportCommitImpact(t *testing.T) {
+	ctx := context.Background()
+	commitStore := store.NewMemoryStore()
+
+	numCorpCommits := common.DefaultCommitPageSize + 200
+	subjects := map[string]int{"corp.com": numCorpCommits, "community.org": 300}
+	for domain, numCommits := range subjects {
+		for i := 0; i < numCommits; i++ {
+			subject := "player: fix crash on startup"
+			if domain == "community.org" && i%3 == 0 {
+				subject = "Update readme"
+			} else if domain == "community.org" {
+				subject = "parser: add test data for the tests"
+			}
+
+			commit := &common.Commit{
+				Id:         fmt.Sprintf("%s-%d", domain, i),
+				Author:     common.Person{Email: fmt.Sprintf("dev%d@%s", i%7, domain)},
+				AuthorTime: time.Date(2021, time.Month(1+i%12), 10, 12, 0, 0, 0, time.UTC).Unix(),
+				Subject:    subject,
+				Changes:    common.Changes{LineChanges: common.LineChanges{NumInsertions: 10}},
+			}
+
+			if err := commitStore.AddCommit(ctx, commit); err != nil {
+				t.Fatalf("Error adding commit: %s", err)
+			}
+		}
+	}
+
+	groups := map[string][]string{"Corporate": {`^corp\.com$`}}
+	report := NewCorporateReport(groups, commitStore, "Corporate", "")
+	if err := report.Generate(ctx); err != nil {
+		t.Fatalf("Error generating report: %s", err)
+	}
+
+	// Bug fixes weigh 0.8, and adding test data outweighs testing at 0
+	corpImpact, commImpact := report.CorporateCommitImpactReport, report.CommunityCommitImpactReport
+	if corpImpact.NumScored != numCorpCommits || math.Abs(corpImpact.MeanImpact-10*0.9*0.8) > 1e-9 {
+		t.Fatalf("Unexpected corporate impact: %d commits scored, mean %f", corpImpact.NumScored, corpImpact.MeanImpact)
+	} else if commImpact.NumScored != 200 || commImpact.MeanImpact != 0 {
+		t.Fatalf("Unexpected community impact: %d commits scored, mean %f", commImpact.NumScored, commImpact.MeanImpact)
+	}
+}
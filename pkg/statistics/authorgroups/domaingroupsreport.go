@@ -1,154 +1,296 @@
 package authorgroups
 
 import (
+	"context"
+	"fmt"
 	"log"
 	"regexp"
-	"strings"
 
-	"github.com/claucambra/commit-analysis-tool/internal/db"
 	"github.com/claucambra/commit-analysis-tool/pkg/common"
+	"github.com/claucambra/commit-analysis-tool/pkg/store"
 )
 
 const fallbackDomain = "unknown-domain"
 const fallbackGroupName = "unknown"
 
-// Report of the organised raw data around a grouping of domains
+// Report of the organised raw data around a grouping of domains. Totals are summed up by the store,
+// so commits are only loaded to credit co-authors or when asked for with GroupCommitIterator.
 type DomainGroupsReport struct {
-	TotalAuthors common.EmailSet
-	TotalChanges *common.LineChanges
-	TotalCommits common.CommitMap
+	TotalAuthors    common.EmailSet
+	TotalChanges    *common.LineChanges
+	TotalNumCommits int
 
 	GroupsOfDomains map[string][]string
 
 	DomainTotalAuthors     map[string]common.EmailSet
 	DomainTotalLineChanges map[string]*common.LineChanges
-
-	DomainCommits map[string]common.CommitMap
-
-	sqlb *db.SQLiteBackend
+	DomainNumCommits       map[string]int
+
+	// Only commits of this repository are reported on, or of all repositories if empty
+	RepoName string
+	// Give co-authors named in Co-authored-by trailers an equal share of their commits' changes,
+	// instead of attributing all changes to the commit author's domain
+	CreditCoAuthors bool
+
+	store store.Store
+
+	// Aggregates of each domain's commits by month and author
+	domainMonthAggregates map[string][]*common.Aggregate
+	// Co-authored commits and the domains they are credited to, the author's included, when
+	// crediting co-authors
+	coAuthoredCommits       common.CommitMap
+	coAuthoredCommitDomains map[string]map[string]bool
 }
 
-func NewDomainGroupsReport(domainGroups map[string][]string, sqlb *db.SQLiteBackend) *DomainGroupsReport {
+func NewDomainGroupsReport(domainGroups map[string][]string, commitStore store.Store, repoName string) *DomainGroupsReport {
 	return &DomainGroupsReport{
-		TotalAuthors:           common.EmailSet{},
-		TotalChanges:           &common.LineChanges{},
-		TotalCommits:           common.CommitMap{},
-		GroupsOfDomains:        domainGroups,
-		DomainTotalAuthors:     map[string]common.EmailSet{},
-		DomainTotalLineChanges: map[string]*common.LineChanges{},
-		DomainCommits:          map[string]common.CommitMap{},
-		sqlb:                   sqlb,
+		TotalAuthors:            common.EmailSet{},
+		TotalChanges:            &common.LineChanges{},
+		GroupsOfDomains:         domainGroups,
+		DomainTotalAuthors:      map[string]common.EmailSet{},
+		DomainTotalLineChanges:  map[string]*common.LineChanges{},
+		DomainNumCommits:        map[string]int{},
+		RepoName:                repoName,
+		store:                   commitStore,
+		domainMonthAggregates:   map[string][]*common.Aggregate{},
+		coAuthoredCommits:       common.CommitMap{},
+		coAuthoredCommitDomains: map[string]map[string]bool{},
 	}
 }
 
 func (report *DomainGroupsReport) resetStats() {
 	report.TotalAuthors = common.EmailSet{}
 	report.TotalChanges = &common.LineChanges{}
-	report.TotalCommits = common.CommitMap{}
+	report.TotalNumCommits = 0
 	report.DomainTotalAuthors = map[string]common.EmailSet{}
 	report.DomainTotalLineChanges = map[string]*common.LineChanges{}
-	report.DomainCommits = map[string]common.CommitMap{}
+	report.DomainNumCommits = map[string]int{}
+	report.domainMonthAggregates = map[string][]*common.Aggregate{}
+	report.coAuthoredCommits = common.CommitMap{}
+	report.coAuthoredCommitDomains = map[string]map[string]bool{}
 }
 
-func (report *DomainGroupsReport) updateDomainChanges() {
-	for authorDomain := range report.DomainTotalAuthors {
-		log.Printf("Updating domain groups report data for domain: %s", authorDomain)
+// Domain as stored for commits whose author email has none
+func aggregateDomain(aggregate *common.Aggregate) string {
+	if aggregate.Domain == "" {
+		return fallbackDomain
+	}
+
+	return aggregate.Domain
+}
+
+func (report *DomainGroupsReport) updateDomainTotals(ctx context.Context) error {
+	log.Printf("Updating domain groups report totals.")
+
+	authorAggregates, err := report.store.Aggregates(ctx, report.RepoName, common.AggregateByDomain, common.AggregateByAuthor)
+	if err != nil {
+		return fmt.Errorf("retrieving domain totals: %w", err)
+	}
 
-		lineChanges, err := domainLineChanges(report.sqlb, authorDomain)
-		if err != nil {
-			log.Fatalf("Error retrieving line changes for domain %s, received error: %s", authorDomain, err)
-			return
+	for _, aggregate := range authorAggregates {
+		domain := aggregateDomain(aggregate)
+
+		if aggregate.AuthorEmail != "" {
+			report.addAuthor(aggregate.AuthorEmail)
 		}
 
-		report.TotalChanges = common.AddLineChanges(report.TotalChanges, lineChanges)
+		report.TotalChanges = common.AddLineChanges(report.TotalChanges, &aggregate.LineChanges)
+		report.TotalNumCommits += aggregate.NumCommits
+		report.DomainNumCommits[domain] += aggregate.NumCommits
 
-		if existingDomainLineChanges, ok := report.DomainTotalLineChanges[authorDomain]; ok {
-			summedDomainLineChanges := common.AddLineChanges(existingDomainLineChanges, lineChanges)
-			report.DomainTotalLineChanges[authorDomain] = summedDomainLineChanges
+		if existingDomainLineChanges, ok := report.DomainTotalLineChanges[domain]; ok {
+			report.DomainTotalLineChanges[domain] = common.AddLineChanges(existingDomainLineChanges, &aggregate.LineChanges)
 		} else {
-			report.DomainTotalLineChanges[authorDomain] = lineChanges
+			report.DomainTotalLineChanges[domain] = common.AddLineChanges(&common.LineChanges{}, &aggregate.LineChanges)
 		}
+	}
+
+	monthAggregates, err := report.store.Aggregates(ctx, report.RepoName,
+		common.AggregateByDomain,
+		common.AggregateByMonth,
+		common.AggregateByAuthor)
+	if err != nil {
+		return fmt.Errorf("retrieving domain monthly totals: %w", err)
+	}
+
+	for _, aggregate := range monthAggregates {
+		domain := aggregateDomain(aggregate)
+		report.domainMonthAggregates[domain] = append(report.domainMonthAggregates[domain], aggregate)
+	}
+
+	return nil
+}
+
+// Lower-cased domain of the email, as stored in the database
+func emailDomain(email string) string {
+	if domain := common.EmailDomain(email); domain != "" {
+		return domain
+	}
+
+	return fallbackDomain
+}
+
+func (report *DomainGroupsReport) addAuthor(author string) {
+	authorDomain := emailDomain(author)
+	currentDomainAuthors := report.DomainTotalAuthors[authorDomain]
+	report.DomainTotalAuthors[authorDomain] = common.AddEmailSet(currentDomainAuthors, common.EmailSet{author: true})
+	report.TotalAuthors[author] = true
+}
+
+// Moves a share of each co-authored commit's changes from the author's domain to the domains of
+// its co-authors, so that the author and every co-author are credited with an equal share
+func (report *DomainGroupsReport) creditCoAuthors(ctx context.Context) error {
+	log.Printf("Crediting co-authors in domain groups report.")
+
+	coAuthoredCommits, err := report.store.CoAuthoredCommits(ctx, report.RepoName)
+	if err != nil {
+		return fmt.Errorf("retrieving co-authored commits: %w", err)
+	}
+
+	for _, commit := range coAuthoredCommits {
+		authorDomain := emailDomain(commit.Author.Email)
+		authorDomainLineChanges, ok := report.DomainTotalLineChanges[authorDomain]
+		coAuthorEmails := commit.CoAuthorEmails()
+
+		if !ok || len(coAuthorEmails) == 0 {
+			continue
+		}
+
+		report.coAuthoredCommits[commit.Id] = commit
+		creditedDomains := map[string]bool{authorDomain: true}
+		report.coAuthoredCommitDomains[commit.Id] = creditedDomains
 
-		domainCommits, err := domainCommits(report.sqlb, authorDomain)
-		if err != nil {
-			log.Fatalf("Error retrieving commits for domain %s, received error: %s", authorDomain, err)
-			return
+		numCredited := len(coAuthorEmails) + 1
+		share := &common.LineChanges{
+			NumInsertions: commit.NumInsertions / numCredited,
+			NumDeletions:  commit.NumDeletions / numCredited,
 		}
 
-		for _, commit := range domainCommits {
-			report.TotalCommits[commit.Id] = commit
+		for _, coAuthorEmail := range coAuthorEmails {
+			coAuthorDomain := emailDomain(coAuthorEmail)
+			report.addAuthor(coAuthorEmail)
 
-			if _, ok := report.DomainCommits[authorDomain]; !ok {
-				report.DomainCommits[authorDomain] = common.CommitMap{commit.Id: commit}
+			if !creditedDomains[coAuthorDomain] {
+				creditedDomains[coAuthorDomain] = true
+				report.DomainNumCommits[coAuthorDomain]++
+			}
+
+			if coAuthorDomain == authorDomain {
+				continue
+			}
+
+			authorDomainLineChanges, _ = common.SubtractLineChanges(authorDomainLineChanges, share)
+
+			if coAuthorDomainLineChanges, ok := report.DomainTotalLineChanges[coAuthorDomain]; ok {
+				report.DomainTotalLineChanges[coAuthorDomain] = common.AddLineChanges(coAuthorDomainLineChanges, share)
 			} else {
-				report.DomainCommits[authorDomain][commit.Id] = commit
+				report.DomainTotalLineChanges[coAuthorDomain] = common.AddLineChanges(&common.LineChanges{}, share)
 			}
 		}
+
+		report.DomainTotalLineChanges[authorDomain] = authorDomainLineChanges
 	}
+
+	return nil
 }
 
-func (report *DomainGroupsReport) updateAuthors(authors []string) {
-	log.Printf("Updating domain groups report authors.")
+func (report *DomainGroupsReport) Generate(ctx context.Context) error {
+	log.Println("Generating domain groups report.")
 
-	for _, author := range authors {
-		if author == "" {
-			continue
+	report.resetStats()
+	if err := report.updateDomainTotals(ctx); err != nil {
+		return err
+	}
+
+	if report.CreditCoAuthors {
+		return report.creditCoAuthors(ctx)
+	}
+
+	return nil
+}
+
+// Domains of the report matching the group's domains, which are treated as potential regexes
+func (report *DomainGroupsReport) groupDomains(groupName string) map[string]bool {
+	matchingDomains := map[string]bool{}
+
+	for _, groupDomainString := range report.GroupsOfDomains[groupName] {
+		groupDomainStringRegex := regexp.MustCompile(groupDomainString)
+
+		for domain := range report.DomainTotalAuthors {
+			if groupDomainStringRegex.MatchString(domain) {
+				matchingDomains[domain] = true
+			}
 		}
+	}
 
-		authorDomain := fallbackDomain
-		splitAuthorEmail := strings.Split(author, "@")
+	return matchingDomains
+}
 
-		if len(splitAuthorEmail) >= 2 {
-			authorDomain = splitAuthorEmail[1]
+// Domains of the report matching the domains of any group
+func (report *DomainGroupsReport) knownGroupDomains() map[string]bool {
+	knownDomains := map[string]bool{}
+	for groupName := range report.GroupsOfDomains {
+		for domain := range report.groupDomains(groupName) {
+			knownDomains[domain] = true
 		}
+	}
+
+	return knownDomains
+}
 
-		currentDomainAuthors := report.DomainTotalAuthors[authorDomain]
-		report.DomainTotalAuthors[authorDomain] = common.AddEmailSet(currentDomainAuthors, common.EmailSet{author: true})
-		report.TotalAuthors[author] = true
+// Domains of the report not matching the domains of any group
+func (report *DomainGroupsReport) unknownGroupDomains(knownDomains map[string]bool) map[string]bool {
+	unknownDomains := map[string]bool{}
+	for domain := range report.DomainTotalLineChanges {
+		if !knownDomains[domain] {
+			unknownDomains[domain] = true
+		}
 	}
+
+	return unknownDomains
 }
 
-func (report *DomainGroupsReport) Generate() {
-	authors, err := report.sqlb.Authors()
-	if err != nil {
-		return
+// Number of commits of the domains. Co-authored commits credited to several of the domains are
+// counted once.
+func (report *DomainGroupsReport) domainsNumCommits(domains map[string]bool) int {
+	numCommits := 0
+	for domain := range domains {
+		numCommits += report.DomainNumCommits[domain]
 	}
 
-	log.Println("Generating domain groups report.")
+	for _, creditedDomains := range report.coAuthoredCommitDomains {
+		numMatching := 0
+		for domain := range creditedDomains {
+			if domains[domain] {
+				numMatching++
+			}
+		}
 
-	report.resetStats()
-	report.updateAuthors(authors)
-	report.updateDomainChanges()
+		if numMatching > 1 {
+			numCommits -= numMatching - 1
+		}
+	}
+
+	return numCommits
 }
 
-// Returns authors, insertions, deletions
-func (report *DomainGroupsReport) accumulateGroupCounts(groupName string) (
+// Returns authors, line changes, number of commits and monthly aggregates of the domains
+func (report *DomainGroupsReport) accumulateDomainCounts(domains map[string]bool) (
 	common.EmailSet,
 	*common.LineChanges,
-	common.CommitMap) {
+	int,
+	[]*common.Aggregate) {
 
 	totalGroupAuthors := common.EmailSet{}
 	totalGroupLineChanges := &common.LineChanges{
 		NumInsertions: 0,
 		NumDeletions:  0,
 	}
-	totalGroupCommits := common.CommitMap{}
-
-	// Slice of input group of domains string that matches actual domains extracted from emails
-	matchingDomains := []string{}
+	totalGroupMonthAggregates := []*common.Aggregate{}
 
-	// Find all domains that match domains in group, treat domains in group as potential regexes
-	for _, groupDomainString := range report.GroupsOfDomains[groupName] {
-		groupDomainStringRegex := regexp.MustCompile(groupDomainString)
+	for domain := range domains {
+		totalGroupMonthAggregates = append(totalGroupMonthAggregates, report.domainMonthAggregates[domain]...)
 
-		for domain := range report.DomainTotalAuthors {
-			if groupDomainStringRegex.MatchString(domain) {
-				matchingDomains = append(matchingDomains, domain)
-			}
-		}
-	}
-
-	for _, domain := range matchingDomains {
 		reportChanges, ok := report.DomainTotalLineChanges[domain]
 		if !ok {
 			continue
@@ -156,41 +298,27 @@ func (report *DomainGroupsReport) accumulateGroupCounts(groupName string) (
 
 		totalGroupLineChanges = common.AddLineChanges(totalGroupLineChanges, reportChanges)
 		totalGroupAuthors = common.AddEmailSet(totalGroupAuthors, report.DomainTotalAuthors[domain])
-
-		totalGroupCommits.AddCommitMap(report.DomainCommits[domain])
 	}
 
 	return totalGroupAuthors,
 		totalGroupLineChanges,
-		totalGroupCommits
+		report.domainsNumCommits(domains),
+		totalGroupMonthAggregates
 }
 
 func (report *DomainGroupsReport) UnknownGroupData() *GroupData {
-	totalGroupAuthors := common.EmailSet{}
-	totalGroupChanges := &common.LineChanges{
-		NumInsertions: 0,
-		NumDeletions:  0,
-	}
-	totalGroupCommits := common.CommitMap{}
-
-	for groupName := range report.GroupsOfDomains {
-		groupAuthors, groupLineChanges, groupCommits := report.accumulateGroupCounts(groupName)
-		totalGroupAuthors = common.AddEmailSet(totalGroupAuthors, groupAuthors)
-		totalGroupChanges = common.AddLineChanges(totalGroupChanges, groupLineChanges)
-		totalGroupCommits.AddCommitMap(groupCommits)
-	}
-
-	unknownGroupTotalAuthors, _ := common.SubtractEmailSet(report.TotalAuthors, totalGroupAuthors)
-	unknownGroupTotalLineChanges, _ := common.SubtractLineChanges(report.TotalChanges, totalGroupChanges)
-
-	var unknownGroupCommits common.CommitMap = common.CopyMap(report.TotalCommits)
-	unknownGroupCommits.SubtractCommitMap(totalGroupCommits)
+	knownDomains := report.knownGroupDomains()
+	knownAuthors, knownLineChanges, knownNumCommits, _ := report.accumulateDomainCounts(knownDomains)
+	_, _, _, unknownMonthAggregates := report.accumulateDomainCounts(report.unknownGroupDomains(knownDomains))
+	unknownGroupTotalAuthors, _ := common.SubtractEmailSet(report.TotalAuthors, knownAuthors)
+	unknownGroupTotalLineChanges, _ := common.SubtractLineChanges(report.TotalChanges, knownLineChanges)
 
 	return NewGroupData(report,
 		fallbackGroupName,
 		unknownGroupTotalAuthors,
 		unknownGroupTotalLineChanges,
-		unknownGroupCommits)
+		report.TotalNumCommits-knownNumCommits,
+		unknownMonthAggregates)
 }
 
 func (report *DomainGroupsReport) GroupData(groupName string) *GroupData {
@@ -198,11 +326,112 @@ func (report *DomainGroupsReport) GroupData(groupName string) *GroupData {
 		return report.UnknownGroupData()
 	}
 
-	totalGroupAuthors, totalGroupLineChanges, totalGroupCommits := report.accumulateGroupCounts(groupName)
+	totalGroupAuthors, totalGroupLineChanges, totalGroupNumCommits, totalGroupMonthAggregates :=
+		report.accumulateDomainCounts(report.groupDomains(groupName))
 
 	return NewGroupData(report,
 		groupName,
 		totalGroupAuthors,
 		totalGroupLineChanges,
-		totalGroupCommits)
+		totalGroupNumCommits,
+		totalGroupMonthAggregates)
+}
+
+// Decides which commits are part of a group, see GroupCommitIterator
+type groupCommitFilter struct {
+	report         *DomainGroupsReport
+	isUnknownGroup bool
+	domains        map[string]bool
+	knownDomains   map[string]bool
+}
+
+func (report *DomainGroupsReport) newGroupCommitFilter(groupName string) *groupCommitFilter {
+	filter := &groupCommitFilter{
+		report:         report,
+		isUnknownGroup: groupName == "" || groupName == fallbackGroupName,
+		knownDomains:   report.knownGroupDomains(),
+	}
+
+	if filter.isUnknownGroup {
+		filter.domains = report.unknownGroupDomains(filter.knownDomains)
+	} else {
+		filter.domains = report.groupDomains(groupName)
+	}
+
+	return filter
+}
+
+func (filter *groupCommitFilter) matches(commit *common.Commit) bool {
+	creditedDomains, coAuthored := filter.report.coAuthoredCommitDomains[commit.Id]
+	if !coAuthored {
+		return filter.domains[emailDomain(commit.Author.Email)]
+	}
+
+	// Like in GroupData, commits credited to a group are not part of the unknown group
+	creditedToGroup := false
+	creditedToKnownGroup := false
+
+	for domain := range creditedDomains {
+		creditedToGroup = creditedToGroup || filter.domains[domain]
+		creditedToKnownGroup = creditedToKnownGroup || filter.knownDomains[domain]
+	}
+
+	return creditedToGroup && !(filter.isUnknownGroup && creditedToKnownGroup)
+}
+
+// CommitIterator over the commits of a query that are part of a group
+type groupCommitIterator struct {
+	commits common.CommitIterator
+	filter  *groupCommitFilter
+}
+
+func (gci *groupCommitIterator) Next() bool {
+	for gci.commits.Next() {
+		if gci.filter.matches(gci.commits.Commit()) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (gci *groupCommitIterator) Commit() *common.Commit {
+	return gci.commits.Commit()
+}
+
+func (gci *groupCommitIterator) Err() error {
+	return gci.commits.Err()
+}
+
+// Commits of the group, or of the unknown group if groupName is empty, without their file changes,
+// trailers or parents. Unlike GroupData, this reads the commits from the store, e.g. to analyse
+// their messages, a page at a time so that only a page of commits is held in memory at a time.
+func (report *DomainGroupsReport) GroupCommitIterator(ctx context.Context, groupName string) (common.CommitIterator, error) {
+	commits, err := report.store.QueryCommits(ctx, common.NewCommitQuery().WithRepo(report.RepoName))
+	if err != nil {
+		return nil, fmt.Errorf("querying commits: %w", err)
+	}
+
+	return &groupCommitIterator{commits: commits, filter: report.newGroupCommitFilter(groupName)}, nil
+}
+
+// Commits of the group, or of the unknown group if groupName is empty, loaded all at once. See
+// GroupCommitIterator.
+func (report *DomainGroupsReport) GroupCommits(ctx context.Context, groupName string) (common.CommitMap, error) {
+	commits, err := report.GroupCommitIterator(ctx, groupName)
+	if err != nil {
+		return nil, err
+	}
+
+	groupCommits := common.CommitMap{}
+	for commits.Next() {
+		commit := commits.Commit()
+		groupCommits[commit.Id] = commit
+	}
+
+	if err := commits.Err(); err != nil {
+		return nil, fmt.Errorf("reading group commits: %w", err)
+	}
+
+	return groupCommits, nil
 }
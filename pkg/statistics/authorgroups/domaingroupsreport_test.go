@@ -1,13 +1,20 @@
 package authorgroups
 
 import (
+	"context"
+	"errors"
 	"testing"
+	"time"
 
 	dbtesting "github.com/claucambra/commit-analysis-tool/internal/db/testing"
+	"github.com/claucambra/commit-analysis-tool/pkg/common"
+	"github.com/claucambra/commit-analysis-tool/pkg/store"
 	"github.com/google/go-cmp/cmp"
 )
 
 func TestDomainGroupsReportGroupData(t *testing.T) {
+	ctx := context.Background()
+
 	dbtesting.TestLogFilePath = testCommitsFile
 	sqlb := dbtesting.InitTestDB(t)
 	cleanup := func() { dbtesting.CleanupTestDB(sqlb) }
@@ -15,8 +22,10 @@ func TestDomainGroupsReportGroupData(t *testing.T) {
 
 	dbtesting.IngestTestCommits(sqlb, t)
 
-	report := NewDomainGroupsReport(testEmailGroups(), sqlb)
-	report.Generate()
+	report := NewDomainGroupsReport(testEmailGroups(), sqlb, "")
+	if err := report.Generate(ctx); err != nil {
+		t.Fatalf("Error generating report: %s", err)
+	}
 
 	groupData := report.GroupData(testGroupName)
 	expectedGroupData := testGroupData(t)
@@ -32,3 +41,129 @@ func TestDomainGroupsReportGroupData(t *testing.T) {
 		t.Fatalf(`Retrieved group data does not match test group data: %s`, cmp.Diff(expectedUnknownGroupData, unknownGroupData))
 	}
 }
+
+func TestDomainGroupsReportAggregates(t *testing.T) {
+	ctx := context.Background()
+
+	commitStore := store.NewMemoryStore()
+
+	januaryTime := time.Date(2023, 1, 10, 12, 0, 0, 0, time.UTC).Unix()
+	februaryTime := time.Date(2023, 2, 10, 12, 0, 0, 0, time.UTC).Unix()
+	commits := []*common.Commit{
+		{
+			Id:         "a",
+			Author:     common.Person{Email: "jb@videolan.org"},
+			AuthorTime: januaryTime,
+			Changes:    common.Changes{LineChanges: common.LineChanges{NumInsertions: 10, NumDeletions: 2}},
+		},
+		{
+			Id:         "b",
+			Author:     common.Person{Email: "dev@notvideolan.org"},
+			AuthorTime: januaryTime,
+			Changes:    common.Changes{LineChanges: common.LineChanges{NumInsertions: 5}},
+		},
+		{
+			Id:         "c",
+			Author:     common.Person{Email: "jb@VideoLAN.org"},
+			AuthorTime: februaryTime,
+			Changes:    common.Changes{LineChanges: common.LineChanges{NumInsertions: 3, NumDeletions: 1}},
+			Trailers: []*common.Trailer{
+				{Kind: common.CoAuthoredByTrailer, Person: common.Person{Email: "someone@example.com"}},
+			},
+		},
+		{
+			Id:         "d",
+			Author:     common.Person{Email: "someone@example.com"},
+			AuthorTime: februaryTime,
+			Changes:    common.Changes{LineChanges: common.LineChanges{NumInsertions: 4}},
+		},
+	}
+
+	if err := commitStore.AddCommits(ctx, commits); err != nil {
+		t.Fatalf("Error adding commits: %s", err)
+	}
+
+	groups := map[string][]string{testGroupName: {`^videolan\.org$`}}
+	report := NewDomainGroupsReport(groups, commitStore, "")
+	if err := report.Generate(ctx); err != nil {
+		t.Fatalf("Error generating report: %s", err)
+	}
+
+	if report.TotalNumCommits != 4 || len(report.TotalAuthors) != 4 {
+		t.Fatalf("Unexpected totals: %d commits, %d authors", report.TotalNumCommits, len(report.TotalAuthors))
+	}
+
+	// Commits of lookalike domains are not counted towards the domain
+	expectedDomainChanges := &common.LineChanges{NumInsertions: 13, NumDeletions: 3}
+	if !cmp.Equal(expectedDomainChanges, report.DomainTotalLineChanges["videolan.org"]) {
+		t.Fatalf("Unexpected domain changes: %s", cmp.Diff(expectedDomainChanges, report.DomainTotalLineChanges["videolan.org"]))
+	}
+
+	groupData := report.GroupData(testGroupName)
+	if groupData.NumCommits != 2 || len(groupData.Authors) != 2 {
+		t.Fatalf("Unexpected group data: %d commits, %d authors", groupData.NumCommits, len(groupData.Authors))
+	}
+
+	expectedInsertions := common.YearMonthCount{2023: {1: 10, 2: 3}}
+	if !cmp.Equal(expectedInsertions, groupData.YearMonthInsertions) {
+		t.Fatalf("Unexpected monthly insertions: %s", cmp.Diff(expectedInsertions, groupData.YearMonthInsertions))
+	}
+
+	unknownGroupData := report.GroupData("")
+	expectedUnknownChanges := &common.LineChanges{NumInsertions: 9}
+	if unknownGroupData.NumCommits != 2 || !cmp.Equal(expectedUnknownChanges, unknownGroupData.LineChanges) {
+		t.Fatalf("Unexpected unknown group data: %d commits, %+v changes", unknownGroupData.NumCommits, unknownGroupData.LineChanges)
+	}
+
+	expectedUnknownAuthors := common.YearMonthCount{2023: {1: 1, 2: 1}}
+	if !cmp.Equal(expectedUnknownAuthors, unknownGroupData.YearMonthAuthors) {
+		t.Fatalf("Unexpected monthly unknown authors: %s", cmp.Diff(expectedUnknownAuthors, unknownGroupData.YearMonthAuthors))
+	}
+
+	// Co-authors are credited with a share of the changes, and their domains with the commit
+	report.CreditCoAuthors = true
+	if err := report.Generate(ctx); err != nil {
+		t.Fatalf("Error generating report: %s", err)
+	}
+
+	expectedCreditedChanges := &common.LineChanges{NumInsertions: 12, NumDeletions: 3}
+	if groupData := report.GroupData(testGroupName); !cmp.Equal(expectedCreditedChanges, groupData.LineChanges) {
+		t.Fatalf("Unexpected credited group changes: %s", cmp.Diff(expectedCreditedChanges, groupData.LineChanges))
+	} else if report.DomainNumCommits["example.com"] != 2 {
+		t.Fatalf("Unexpected number of co-author domain commits %d", report.DomainNumCommits["example.com"])
+	}
+
+	groupCommits, err := report.GroupCommits(ctx, testGroupName)
+	if err != nil {
+		t.Fatalf("Error retrieving group commits: %s", err)
+	}
+
+	unknownGroupCommits, err := report.GroupCommits(ctx, "")
+	if err != nil {
+		t.Fatalf("Error retrieving unknown group commits: %s", err)
+	}
+
+	expectedGroupCommitIds := []string{"a", "c"}
+	expectedUnknownGroupCommitIds := []string{"b", "d"}
+	if groupCommitIds := common.SortedMapKeys(groupCommits); !cmp.Equal(expectedGroupCommitIds, groupCommitIds) {
+		t.Fatalf("Unexpected group commits: %s", cmp.Diff(expectedGroupCommitIds, groupCommitIds))
+	} else if unknownGroupCommitIds := common.SortedMapKeys(unknownGroupCommits); !cmp.Equal(expectedUnknownGroupCommitIds, unknownGroupCommitIds) {
+		t.Fatalf("Unexpected unknown group commits: %s", cmp.Diff(expectedUnknownGroupCommitIds, unknownGroupCommitIds))
+	}
+}
+
+func TestDomainGroupsReportCancelled(t *testing.T) {
+	commitStore := store.NewMemoryStore()
+	commit := &common.Commit{Id: "a", Author: common.Person{Email: "jb@videolan.org"}}
+	if err := commitStore.AddCommit(context.Background(), commit); err != nil {
+		t.Fatalf("Error adding commit: %s", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	report := NewDomainGroupsReport(testEmailGroups(), commitStore, "")
+	if err := report.Generate(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Unexpected error %v generating report with a cancelled context, expected %v", err, context.Canceled)
+	}
+}
@@ -10,7 +10,14 @@ type GroupData struct {
 
 	Authors     common.EmailSet
 	LineChanges *common.LineChanges
-	Commits     common.CommitMap
+	NumCommits  int
+
+	// Insertions, deletions and distinct authors of the group's commits in each month (by author
+	// time, in UTC). Commits count towards their author's group only, even when crediting
+	// co-authors.
+	YearMonthInsertions common.YearMonthCount
+	YearMonthDeletions  common.YearMonthCount
+	YearMonthAuthors    common.YearMonthCount
 
 	AuthorsPercent    float64
 	InsertionsPercent float64
@@ -21,16 +28,61 @@ func NewGroupData(report *DomainGroupsReport,
 	groupName string,
 	groupAuthors common.EmailSet,
 	groupLineChanges *common.LineChanges,
-	groupCommits common.CommitMap) *GroupData {
+	groupNumCommits int,
+	groupMonthAggregates []*common.Aggregate) *GroupData {
 
 	groupData := new(GroupData)
 	groupData.GroupName = groupName
 	groupData.Authors = groupAuthors
 	groupData.LineChanges = groupLineChanges
-	groupData.Commits = groupCommits
+	groupData.NumCommits = groupNumCommits
 	groupData.AuthorsPercent = (float64(len(groupAuthors)) / float64(len(report.TotalAuthors))) * 100
 	groupData.InsertionsPercent = (float64(groupLineChanges.NumInsertions) / float64(report.TotalChanges.NumInsertions)) * 100
 	groupData.DeletionsPercent = (float64(groupLineChanges.NumDeletions) / float64(report.TotalChanges.NumDeletions)) * 100
+	groupData.updateYearMonthCounts(groupMonthAggregates)
 
 	return groupData
 }
+
+// Sums up aggregates of the group's commits by domain, month and author
+func (groupData *GroupData) updateYearMonthCounts(groupMonthAggregates []*common.Aggregate) {
+	groupData.YearMonthInsertions = common.YearMonthCount{}
+	groupData.YearMonthDeletions = common.YearMonthCount{}
+	groupData.YearMonthAuthors = common.YearMonthCount{}
+
+	monthAuthors := map[int]map[int]common.EmailSet{}
+
+	for _, aggregate := range groupMonthAggregates {
+		if _, ok := groupData.YearMonthInsertions[aggregate.Year]; !ok {
+			groupData.YearMonthInsertions[aggregate.Year] = common.MonthCount{}
+			groupData.YearMonthDeletions[aggregate.Year] = common.MonthCount{}
+			groupData.YearMonthAuthors[aggregate.Year] = common.MonthCount{}
+			monthAuthors[aggregate.Year] = map[int]common.EmailSet{}
+		}
+
+		groupData.YearMonthInsertions[aggregate.Year][aggregate.Month] += aggregate.NumInsertions
+		groupData.YearMonthDeletions[aggregate.Year][aggregate.Month] += aggregate.NumDeletions
+
+		if _, ok := monthAuthors[aggregate.Year][aggregate.Month]; !ok {
+			monthAuthors[aggregate.Year][aggregate.Month] = common.EmailSet{}
+		}
+
+		monthAuthors[aggregate.Year][aggregate.Month][aggregate.AuthorEmail] = true
+		groupData.YearMonthAuthors[aggregate.Year][aggregate.Month] = len(monthAuthors[aggregate.Year][aggregate.Month])
+	}
+}
+
+// Years from the first to the last one the group has commits in
+func (groupData *GroupData) YearRange() []int {
+	years := common.SortedMapKeys(groupData.YearMonthInsertions)
+	if len(years) == 0 {
+		return years
+	}
+
+	filledYears := []int{}
+	for year := years[0]; year <= years[len(years)-1]; year++ {
+		filledYears = append(filledYears, year)
+	}
+
+	return filledYears
+}
@@ -1,47 +1,122 @@
 package authorgroups
 
 import (
+	"context"
+	"fmt"
 	"log"
+	"time"
 
-	"github.com/claucambra/commit-analysis-tool/internal/db"
 	"github.com/claucambra/commit-analysis-tool/pkg/common"
 	"github.com/claucambra/commit-analysis-tool/pkg/statistics"
+	"github.com/claucambra/commit-analysis-tool/pkg/store"
 )
 
+// Authors whose run of active months ends this many months or fewer before the month of the last
+// commit are considered still active, by default
+const DefaultCensorMonths = 3
+
 // This should ideally be an independent struct that we sub-struct with author
 // group-specific functionality
 type GroupSurvivalReport struct {
 	Authors           common.EmailSet
 	AuthorsInTimeStep statistics.TimeStepPopulation
-	AuthorsSurvival   statistics.TimeStepSurvival
+	// Survival beyond each number of months, from Estimate
+	AuthorsSurvival statistics.TimeStepSurvival
+
+	// Survival of the authors' active months, allowing for censored authors
+	Estimate *statistics.KaplanMeierEstimate
+	// Authors still active when the data ends, see CensorMonths
+	CensoredAuthors common.EmailSet
+	// Active months of each author, which Estimate is made from
+	AuthorObservations map[string]statistics.SurvivalObservation
 
-	sqlb *db.SQLiteBackend
+	// Only commits of this repository are taken into account, or of all repositories if empty
+	RepoName string
+	// How long authors stay active for, for as many months as they commit in without a gap by
+	// default
+	Activity ActivityDefinition
+	// Authors whose run of active months ends this many months or fewer before the month of the
+	// last commit may well still be active, so their survival is censored rather than ended. Those
+	// within the activity definition's PendingMonths are censored too, as later commits could
+	// still extend their activity. Negative to censor no authors
+	CensorMonths int
+
+	store store.Store
 }
 
-func NewGroupSurvivalReport(sqlb *db.SQLiteBackend, yearlyAuthors common.EmailSet) *GroupSurvivalReport {
+func NewGroupSurvivalReport(commitStore store.Store, yearlyAuthors common.EmailSet, repoName string) *GroupSurvivalReport {
 	return &GroupSurvivalReport{
 		Authors:           yearlyAuthors,
 		AuthorsInTimeStep: statistics.TimeStepPopulation{},
 		AuthorsSurvival:   statistics.TimeStepSurvival{},
-		sqlb:              sqlb,
+		CensoredAuthors:   common.EmailSet{},
+		RepoName:          repoName,
+		Activity:          &ContinuousActivity{},
+		CensorMonths:      DefaultCensorMonths,
+		store:             commitStore,
 	}
 }
 
+// Months since year 0, to count months across years
+func monthNumber(year int, month int) int {
+	return year*12 + month - 1
+}
+
+// Month of the latest commit, by author time in UTC, or -1 if there are no commits
+func lastCommitMonth(ctx context.Context, commitStore store.Store, repoName string) (int, error) {
+	query := common.NewCommitQuery().WithRepo(repoName).WithOrder(common.OrderByAuthorTime, true).WithLimit(1)
+	commits, err := commitStore.QueryCommits(ctx, query)
+	if err != nil {
+		return 0, fmt.Errorf("retrieving last commit: %w", err)
+	}
+
+	lastCommits, err := common.CollectCommits(commits)
+	if err != nil {
+		return 0, fmt.Errorf("retrieving last commit: %w", err)
+	} else if len(lastCommits) == 0 {
+		return -1, nil
+	}
+
+	lastTime := time.Unix(lastCommits[0].AuthorTime, 0).UTC()
+	return monthNumber(lastTime.Year(), int(lastTime.Month())), nil
+}
+
 // See how long each author lasts for on average
-func (gsp *GroupSurvivalReport) Generate() {
+func (gsp *GroupSurvivalReport) Generate(ctx context.Context) error {
 	gsp.AuthorsInTimeStep = statistics.TimeStepPopulation{}
 	gsp.AuthorsSurvival = statistics.TimeStepSurvival{}
+	gsp.CensoredAuthors = common.EmailSet{}
+	gsp.AuthorObservations = map[string]statistics.SurvivalObservation{}
+
+	lastMonth, err := lastCommitMonth(ctx, gsp.store, gsp.RepoName)
+	if err != nil {
+		return err
+	}
+
+	censorMonths := gsp.CensorMonths
+	if censorMonths >= 0 {
+		censorMonths = common.MaxInt(censorMonths, gsp.Activity.PendingMonths())
+	}
 
 	for author := range gsp.Authors {
-		timeSteps, err := authorContinuousMonths(gsp.sqlb, author)
+		firstMonth, timeSteps, err := authorTenure(ctx, gsp.store, gsp.Activity, author, gsp.RepoName)
 		if err != nil {
-			log.Printf("Author %s did not have retrievable months", author)
-			continue
+			return err
 		} else if timeSteps < 1 {
 			log.Printf("Author %s active for less than one month. Can't analyse.", author)
 			continue
 		}
 
+		censored := lastMonth-(firstMonth+timeSteps-1) <= censorMonths
+		if censored {
+			gsp.CensoredAuthors[author] = true
+		}
+
+		gsp.AuthorObservations[author] = statistics.SurvivalObservation{
+			Duration: float64(timeSteps),
+			Censored: censored,
+		}
+
 		for i := 0; i < timeSteps; i++ {
 			if len(gsp.AuthorsInTimeStep) < i+1 {
 				gsp.AuthorsInTimeStep = append(gsp.AuthorsInTimeStep, 1)
@@ -51,5 +126,34 @@ func (gsp *GroupSurvivalReport) Generate() {
 		}
 	}
 
-	gsp.AuthorsSurvival = gsp.AuthorsInTimeStep.KaplanMeierSurvival()
+	gsp.Estimate = statistics.KaplanMeier(gsp.Observations())
+	gsp.AuthorsSurvival = gsp.Estimate.TimeStepSurvival()
+	return nil
+}
+
+// Observations of the authors in order of their emails, e.g. to compare groups with
+// statistics.LogRank
+func (gsp *GroupSurvivalReport) Observations() []statistics.SurvivalObservation {
+	observations := make([]statistics.SurvivalObservation, 0, len(gsp.AuthorObservations))
+	for _, author := range common.SortedMapKeys(gsp.AuthorObservations) {
+		observations = append(observations, gsp.AuthorObservations[author])
+	}
+
+	return observations
+}
+
+// First month the author was active in and for how many months, see ActivityDefinition
+func authorTenure(ctx context.Context, commitStore store.Store, activity ActivityDefinition, authorEmail string, repoName string) (int, int, error) {
+	yearsMap, err := commitStore.AuthorYearMonthCommits(ctx, authorEmail, repoName)
+	if err != nil {
+		return 0, 0, fmt.Errorf("retrieving monthly commits of author %s: %w", authorEmail, err)
+	}
+
+	if len(yearsMap) == 0 {
+		log.Printf("Author %s active for no years, can't return number of active months", authorEmail)
+		return 0, 0, nil
+	}
+
+	firstMonth, numMonths := activity.Tenure(yearsMap)
+	return firstMonth, numMonths, nil
 }
@@ -0,0 +1,110 @@
+package authorgroups
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/claucambra/commit-analysis-tool/pkg/common"
+	"github.com/claucambra/commit-analysis-tool/pkg/statistics"
+	"github.com/claucambra/commit-analysis-tool/pkg/store"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+func TestGroupSurvivalReportCensoring(t *testing.T) {
+	ctx := context.Background()
+	commitStore := store.NewMemoryStore()
+
+	// Months of 2023 each author committed in
+	authorMonths := map[string][]time.Month{
+		"left@example.com":     {time.January, time.February, time.March},
+		"active@example.com":   {time.October, time.November, time.December},
+		"longtime@example.com": {time.January, time.February, time.March, time.April, time.May, time.June},
+	}
+
+	authors := common.EmailSet{}
+	for email, months := range authorMonths {
+		authors[email] = true
+
+		for _, month := range months {
+			commit := &common.Commit{
+				Id:         fmt.Sprintf("%s-%d", email, month),
+				Author:     common.Person{Email: email},
+				AuthorTime: time.Date(2023, month, 10, 12, 0, 0, 0, time.UTC).Unix(),
+			}
+
+			if err := commitStore.AddCommit(ctx, commit); err != nil {
+				t.Fatalf("Error adding commit: %s", err)
+			}
+		}
+	}
+
+	report := NewGroupSurvivalReport(commitStore, authors, "")
+	if err := report.Generate(ctx); err != nil {
+		t.Fatalf("Error generating report: %s", err)
+	}
+
+	expectedCensoredAuthors := common.EmailSet{"active@example.com": true}
+	if !cmp.Equal(expectedCensoredAuthors, report.CensoredAuthors) {
+		t.Fatalf("Unexpected censored authors: %s", cmp.Diff(expectedCensoredAuthors, report.CensoredAuthors))
+	}
+
+	expectedSurvival := statistics.TimeStepSurvival{1, 1, 1, 2. / 3., 2. / 3., 2. / 3.}
+	if !cmp.Equal(expectedSurvival, report.AuthorsSurvival, cmpopts.EquateApprox(0, 1e-9)) {
+		t.Fatalf("Unexpected survival: %s", cmp.Diff(expectedSurvival, report.AuthorsSurvival))
+	} else if len(report.AuthorObservations) != len(authors) {
+		t.Fatalf("Unexpected number of observations %d, expected %d", len(report.AuthorObservations), len(authors))
+	} else if report.Estimate.Median != 6 {
+		t.Fatalf("Unexpected median survival %f, expected 6", report.Estimate.Median)
+	}
+
+	// A negative censoring window censors no authors
+	report.CensorMonths = -1
+	if err := report.Generate(ctx); err != nil {
+		t.Fatalf("Error generating report: %s", err)
+	}
+
+	if len(report.CensoredAuthors) != 0 {
+		t.Fatalf("Unexpected censored authors %v with a negative censoring window", report.CensoredAuthors)
+	}
+
+	// Authors stay active through gaps the activity definition tolerates
+	gapCommit := &common.Commit{
+		Id:         "left@example.com-gap",
+		Author:     common.Person{Email: "left@example.com"},
+		AuthorTime: time.Date(2023, time.June, 10, 12, 0, 0, 0, time.UTC).Unix(),
+	}
+
+	if err := commitStore.AddCommit(ctx, gapCommit); err != nil {
+		t.Fatalf("Error adding commit: %s", err)
+	}
+
+	report.Activity = &ContinuousActivity{GapMonths: 2}
+	if err := report.Generate(ctx); err != nil {
+		t.Fatalf("Error generating report: %s", err)
+	} else if duration := report.AuthorObservations["left@example.com"].Duration; duration != 6 {
+		t.Fatalf("Unexpected duration %f of an author with a gap, expected 6", duration)
+	}
+
+	// Authors whose activity ended within a gap the activity definition tolerates may still be
+	// active, however short the censoring window
+	report.CensorMonths = 0
+	report.Activity = &ContinuousActivity{GapMonths: 6}
+	if err := report.Generate(ctx); err != nil {
+		t.Fatalf("Error generating report: %s", err)
+	}
+
+	expectedCensoredAuthors = common.EmailSet{"active@example.com": true, "left@example.com": true, "longtime@example.com": true}
+	if !cmp.Equal(expectedCensoredAuthors, report.CensoredAuthors) {
+		t.Fatalf("Unexpected censored authors within the activity gap: %s", cmp.Diff(expectedCensoredAuthors, report.CensoredAuthors))
+	}
+
+	report.Activity = &WindowActivity{WindowMonths: 6, MinCommits: 1}
+	if err := report.Generate(ctx); err != nil {
+		t.Fatalf("Error generating report: %s", err)
+	} else if !cmp.Equal(expectedCensoredAuthors, report.CensoredAuthors) {
+		t.Fatalf("Unexpected censored authors within the activity window: %s", cmp.Diff(expectedCensoredAuthors, report.CensoredAuthors))
+	}
+}
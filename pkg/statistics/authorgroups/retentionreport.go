@@ -0,0 +1,180 @@
+package authorgroups
+
+import (
+	"context"
+	"fmt"
+	"math"
+
+	"github.com/claucambra/commit-analysis-tool/pkg/common"
+	"github.com/claucambra/commit-analysis-tool/pkg/statistics"
+	"github.com/claucambra/commit-analysis-tool/pkg/store"
+)
+
+// Names of the covariates of RetentionReport besides the group, which is named after the group
+const logCommitsCovariate = "log_commits"
+const firstYearCovariate = "first_year"
+const repoCovariatePrefix = "repo:"
+
+// Cox proportional hazards model of how long authors stay active, see GroupSurvivalReport. Tests
+// whether belonging to a group of domains predicts authors leaving once their number of commits,
+// the year of their first commit and their main repository are accounted for.
+type RetentionReport struct {
+	GroupsOfDomains map[string][]string
+	GroupName       string
+
+	// Only commits of this repository are taken into account, or of all repositories if empty, in
+	// which case the repository each author made most commits to is a covariate
+	RepoName string
+	// See GroupSurvivalReport.Activity and GroupSurvivalReport.CensorMonths
+	Activity     ActivityDefinition
+	CensorMonths int
+
+	// Covariates are whether the author is in the group, the natural logarithm of their number
+	// of commits, the year of their first commit and, for each main repository but the most
+	// common one, whether it is the author's
+	Model *statistics.CoxModel
+
+	store store.Store
+}
+
+func NewRetentionReport(groupsOfDomains map[string][]string, commitStore store.Store, groupName string, repoName string) *RetentionReport {
+	return &RetentionReport{
+		GroupsOfDomains: groupsOfDomains,
+		GroupName:       groupName,
+		RepoName:        repoName,
+		Activity:        &ContinuousActivity{},
+		CensorMonths:    DefaultCensorMonths,
+		store:           commitStore,
+	}
+}
+
+func (report *RetentionReport) Generate(ctx context.Context) error {
+	authors, err := report.store.Authors(ctx, report.RepoName)
+	if err != nil {
+		return fmt.Errorf("retrieving authors: %w", err)
+	}
+
+	authorSet := common.EmailSet{}
+	for _, author := range authors {
+		authorSet[author] = true
+	}
+
+	survivalReport := NewGroupSurvivalReport(report.store, authorSet, report.RepoName)
+	survivalReport.Activity = report.Activity
+	survivalReport.CensorMonths = report.CensorMonths
+	if err := survivalReport.Generate(ctx); err != nil {
+		return fmt.Errorf("author survival: %w", err)
+	}
+
+	authorAggregates, err := report.store.Aggregates(ctx, report.RepoName, common.AggregateByAuthor)
+	if err != nil {
+		return fmt.Errorf("retrieving author commit counts: %w", err)
+	}
+
+	authorNumCommits := map[string]int{}
+	for _, aggregate := range authorAggregates {
+		authorNumCommits[aggregate.AuthorEmail] = aggregate.NumCommits
+	}
+
+	// Sorted by year for each author, so the first is the year of their first commit
+	yearAggregates, err := report.store.Aggregates(ctx, report.RepoName, common.AggregateByAuthor, common.AggregateByYear)
+	if err != nil {
+		return fmt.Errorf("retrieving author years: %w", err)
+	}
+
+	authorFirstYear := map[string]int{}
+	for _, aggregate := range yearAggregates {
+		if _, ok := authorFirstYear[aggregate.AuthorEmail]; !ok {
+			authorFirstYear[aggregate.AuthorEmail] = aggregate.Year
+		}
+	}
+
+	authorRepos, otherRepos, err := report.authorMainRepos(ctx)
+	if err != nil {
+		return err
+	}
+
+	covariateNames := []string{report.GroupName, logCommitsCovariate, firstYearCovariate}
+	for _, repoName := range otherRepos {
+		covariateNames = append(covariateNames, repoCovariatePrefix+repoName)
+	}
+
+	observations := []statistics.CoxObservation{}
+	for _, author := range common.SortedMapKeys(survivalReport.AuthorObservations) {
+		inGroup := 0.
+		for _, groupName := range DomainGroupNames(report.GroupsOfDomains, emailDomain(author)) {
+			if groupName == report.GroupName {
+				inGroup = 1
+			}
+		}
+
+		covariates := []float64{inGroup, math.Log(float64(authorNumCommits[author])), float64(authorFirstYear[author])}
+		for _, repoName := range otherRepos {
+			inRepo := 0.
+			if authorRepos[author] == repoName {
+				inRepo = 1
+			}
+
+			covariates = append(covariates, inRepo)
+		}
+
+		observations = append(observations, statistics.CoxObservation{
+			SurvivalObservation: survivalReport.AuthorObservations[author],
+			Covariates:          covariates,
+		})
+	}
+
+	model, err := statistics.FitCox(covariateNames, observations)
+	if err != nil {
+		return fmt.Errorf("fitting retention model: %w", err)
+	}
+
+	report.Model = model
+	return nil
+}
+
+// Repository each author made most commits to, and the main repositories in order of their names
+// without the one that is most often an author's main repository, when reporting on all
+// repositories
+func (report *RetentionReport) authorMainRepos(ctx context.Context) (map[string]string, []string, error) {
+	authorRepos := map[string]string{}
+	if report.RepoName != "" {
+		return authorRepos, []string{}, nil
+	}
+
+	// Sorted by repository for each author, so ties go to the first repository by name
+	repoAggregates, err := report.store.Aggregates(ctx, "", common.AggregateByAuthor, common.AggregateByRepo)
+	if err != nil {
+		return nil, nil, fmt.Errorf("retrieving author repositories: %w", err)
+	}
+
+	authorRepoNumCommits := map[string]int{}
+	for _, aggregate := range repoAggregates {
+		if aggregate.NumCommits > authorRepoNumCommits[aggregate.AuthorEmail] {
+			authorRepos[aggregate.AuthorEmail] = aggregate.RepoName
+			authorRepoNumCommits[aggregate.AuthorEmail] = aggregate.NumCommits
+		}
+	}
+
+	repoNumAuthors := map[string]int{}
+	for _, repoName := range authorRepos {
+		repoNumAuthors[repoName]++
+	}
+
+	repoNames := common.SortedMapKeys(repoNumAuthors)
+	referenceRepo := ""
+	for _, repoName := range repoNames {
+		if referenceRepo == "" || repoNumAuthors[repoName] > repoNumAuthors[referenceRepo] {
+			referenceRepo = repoName
+		}
+	}
+
+	otherRepos := []string{}
+	for _, repoName := range repoNames {
+		if repoName != referenceRepo {
+			otherRepos = append(otherRepos, repoName)
+		}
+	}
+
+	return authorRepos, otherRepos, nil
+}
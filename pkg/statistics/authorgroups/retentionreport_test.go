@@ -0,0 +1,84 @@
+package authorgroups
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/claucambra/commit-analysis-tool/pkg/common"
+	"github.com/claucambra/commit-analysis-tool/pkg/store"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestRetentionReport(t *testing.T) {
+	ctx := context.Background()
+	commitStore := store.NewMemoryStore()
+
+	// Month each author started in, for how many months and with how many commits a month
+	type authorActivity struct {
+		email          string
+		repoName       string
+		firstMonth     time.Month
+		firstYear      int
+		numMonths      int
+		commitsInMonth int
+	}
+
+	activities := []authorActivity{
+		{"a1@corp.com", "a", time.January, 2022, 2, 1},
+		{"a2@corp.com", "a", time.March, 2022, 4, 3},
+		{"a3@corp.com", "b", time.February, 2023, 3, 2},
+		{"a4@corp.com", "a", time.May, 2022, 7, 1},
+		{"a5@corp.com", "b", time.January, 2022, 24, 2},
+		{"c1@comm.org", "a", time.January, 2022, 3, 2},
+		{"c2@comm.org", "b", time.April, 2022, 9, 1},
+		{"c3@comm.org", "a", time.March, 2023, 5, 4},
+		{"c4@comm.org", "a", time.June, 2022, 12, 2},
+		{"c5@comm.org", "b", time.January, 2023, 12, 1},
+		{"c6@comm.org", "a", time.February, 2022, 6, 3},
+	}
+
+	for _, activity := range activities {
+		for i := 0; i < activity.numMonths; i++ {
+			for j := 0; j < activity.commitsInMonth; j++ {
+				commit := &common.Commit{
+					Id:         fmt.Sprintf("%s-%d-%d", activity.email, i, j),
+					RepoName:   activity.repoName,
+					Author:     common.Person{Email: activity.email},
+					AuthorTime: time.Date(activity.firstYear, activity.firstMonth+time.Month(i), 10+j, 12, 0, 0, 0, time.UTC).Unix(),
+				}
+
+				if err := commitStore.AddCommit(ctx, commit); err != nil {
+					t.Fatalf("Error adding commit: %s", err)
+				}
+			}
+		}
+	}
+
+	groups := map[string][]string{testGroupName: {`^corp\.com$`}}
+	report := NewRetentionReport(groups, commitStore, testGroupName, "")
+	if err := report.Generate(ctx); err != nil {
+		t.Fatalf("Error generating report: %s", err)
+	}
+
+	// Most authors' main repository is a, which all other repositories are compared to
+	expectedCovariateNames := []string{testGroupName, logCommitsCovariate, firstYearCovariate, repoCovariatePrefix + "b"}
+	covariateNames := []string{}
+	for _, covariate := range report.Model.Covariates {
+		covariateNames = append(covariateNames, covariate.Name)
+	}
+
+	if !cmp.Equal(expectedCovariateNames, covariateNames) {
+		t.Fatalf("Unexpected covariates: %s", cmp.Diff(expectedCovariateNames, covariateNames))
+	} else if report.Model.NumObservations != len(activities) || report.Model.NumEvents != len(activities)-2 {
+		t.Fatalf("Unexpected %d observations with %d events", report.Model.NumObservations, report.Model.NumEvents)
+	}
+
+	report.RepoName = "a"
+	if err := report.Generate(ctx); err != nil {
+		t.Fatalf("Error generating report: %s", err)
+	} else if len(report.Model.Covariates) != 3 {
+		t.Fatalf("Unexpected %d covariates reporting on a single repository", len(report.Model.Covariates))
+	}
+}
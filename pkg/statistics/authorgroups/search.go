@@ -0,0 +1,65 @@
+package authorgroups
+
+import (
+	"context"
+	"regexp"
+	"sort"
+
+	"github.com/claucambra/commit-analysis-tool/pkg/common"
+	"github.com/claucambra/commit-analysis-tool/pkg/store"
+)
+
+// Commit matching a search, with the domain groups of its author
+type SearchResult struct {
+	Commit *common.Commit
+	Groups []string
+}
+
+// Sorted names of the groups whose domains match the given domain, treating group domains as
+// regexes, or the unknown group if none match
+func DomainGroupNames(groupsOfDomains map[string][]string, domain string) []string {
+	groupNames := []string{}
+
+	for groupName, groupDomains := range groupsOfDomains {
+		for _, groupDomainString := range groupDomains {
+			if regexp.MustCompile(groupDomainString).MatchString(domain) {
+				groupNames = append(groupNames, groupName)
+				break
+			}
+		}
+	}
+
+	if len(groupNames) == 0 {
+		groupNames = append(groupNames, fallbackGroupName)
+	}
+
+	sort.Strings(groupNames)
+	return groupNames
+}
+
+// Commits matching the query, e.g. a full-text query with common.CommitQuery.Text, with the groups
+// of their authors
+func Search(ctx context.Context, commitStore store.Store, groupsOfDomains map[string][]string, query *common.CommitQuery) ([]*SearchResult, error) {
+	commits, err := commitStore.QueryCommits(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	results := []*SearchResult{}
+	domainGroups := map[string][]string{}
+
+	for commits.Next() {
+		commit := commits.Commit()
+		domain := emailDomain(commit.Author.Email)
+		if _, ok := domainGroups[domain]; !ok {
+			domainGroups[domain] = DomainGroupNames(groupsOfDomains, domain)
+		}
+
+		results = append(results, &SearchResult{
+			Commit: commit,
+			Groups: domainGroups[domain],
+		})
+	}
+
+	return results, commits.Err()
+}
@@ -0,0 +1,43 @@
+package authorgroups
+
+import (
+	"context"
+	"testing"
+
+	"github.com/claucambra/commit-analysis-tool/pkg/common"
+	"github.com/claucambra/commit-analysis-tool/pkg/store"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestSearch(t *testing.T) {
+	ctx := context.Background()
+
+	commitStore := store.NewMemoryStore()
+
+	commits := []*common.Commit{
+		{Id: "a", RepoName: "vlc", Author: common.Person{Email: "jb@videolan.org"}, Subject: "Fix memory leak"},
+		{Id: "b", RepoName: "vlc", Author: common.Person{Email: "someone@example.com"}, Subject: "Fix crash"},
+		{Id: "c", RepoName: "vlc", Author: common.Person{Email: "jb@videolan.org"}, Subject: "Add decoder"},
+	}
+
+	if err := commitStore.AddCommits(ctx, commits); err != nil {
+		t.Fatalf("Error adding commits: %s", err)
+	}
+
+	results, err := Search(ctx, commitStore, testEmailGroups(), common.NewCommitQuery().WithText("fix"))
+	if err != nil {
+		t.Fatalf("Error searching commits: %s", err)
+	}
+
+	expectedResults := []*SearchResult{
+		{Commit: &common.Commit{Id: "a", RepoName: "vlc", Author: common.Person{Email: "jb@videolan.org"}, Subject: "Fix memory leak"}, Groups: []string{testGroupName}},
+		{Commit: &common.Commit{Id: "b", RepoName: "vlc", Author: common.Person{Email: "someone@example.com"}, Subject: "Fix crash"}, Groups: []string{fallbackGroupName}},
+	}
+	if !cmp.Equal(expectedResults, results) {
+		t.Fatalf("Unexpected search results: %s", cmp.Diff(expectedResults, results))
+	}
+
+	if _, err := Search(ctx, commitStore, testEmailGroups(), common.NewCommitQuery().WithText("fix OR")); err == nil {
+		t.Fatalf("Malformed query should fail")
+	}
+}
@@ -0,0 +1,99 @@
+package authorgroups
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/claucambra/commit-analysis-tool/pkg/store"
+)
+
+// Report on the shape of the history: how often merges happen and which domain groups perform
+// them. Merges are only known for commits ingested with merges included.
+type TopologyReport struct {
+	TotalCommits int
+	TotalMerges  int
+	// Proportion of commits that are merges
+	MergeFrequency float64
+
+	YearlyMerges map[int]int
+
+	GroupsOfDomains map[string][]string
+
+	// Merges by the authors of each domain group. Merges by authors that are not part of any
+	// group are counted in the unknown group.
+	GroupMerges        map[string]int
+	GroupMergesPercent map[string]float64
+
+	// Only commits of this repository are reported on, or of all repositories if empty
+	RepoName string
+
+	store store.Store
+}
+
+func NewTopologyReport(domainGroups map[string][]string, commitStore store.Store, repoName string) *TopologyReport {
+	return &TopologyReport{
+		YearlyMerges:       map[int]int{},
+		GroupsOfDomains:    domainGroups,
+		GroupMerges:        map[string]int{},
+		GroupMergesPercent: map[string]float64{},
+		RepoName:           repoName,
+		store:              commitStore,
+	}
+}
+
+// Names of the groups whose domains match the given domain, treating group domains as regexes
+func (report *TopologyReport) domainGroupNames(domain string) []string {
+	return DomainGroupNames(report.GroupsOfDomains, domain)
+}
+
+func (report *TopologyReport) Generate(ctx context.Context) error {
+	log.Println("Generating topology report.")
+
+	report.YearlyMerges = map[int]int{}
+	report.GroupMerges = map[string]int{}
+	report.GroupMergesPercent = map[string]float64{}
+
+	totalCommits, err := report.store.CommitCount(ctx, report.RepoName, false)
+	if err != nil {
+		return fmt.Errorf("counting commits for topology report: %w", err)
+	}
+
+	mergeCommits, err := report.store.MergeCommits(ctx, report.RepoName)
+	if err != nil {
+		return fmt.Errorf("retrieving merge commits for topology report: %w", err)
+	}
+
+	report.TotalCommits = totalCommits
+	report.TotalMerges = len(mergeCommits)
+
+	if totalCommits > 0 {
+		report.MergeFrequency = float64(report.TotalMerges) / float64(totalCommits)
+	}
+
+	for _, commit := range mergeCommits {
+		mergeYear := time.Unix(commit.AuthorTime, 0).UTC().Year()
+		report.YearlyMerges[mergeYear]++
+
+		for _, groupName := range report.domainGroupNames(emailDomain(commit.Author.Email)) {
+			report.GroupMerges[groupName]++
+		}
+	}
+
+	for groupName, groupMerges := range report.GroupMerges {
+		report.GroupMergesPercent[groupName] = (float64(groupMerges) / float64(report.TotalMerges)) * 100
+	}
+
+	return nil
+}
+
+// Percentage of merges performed by the group's authors, or by authors in no group if the group
+// name is empty
+func (report *TopologyReport) GroupMergeShare(groupName string) float64 {
+	if groupName == "" {
+		groupName = fallbackGroupName
+	}
+
+	return report.GroupMergesPercent[groupName]
+}
@@ -0,0 +1,56 @@
+package authorgroups
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/claucambra/commit-analysis-tool/pkg/common"
+	"github.com/claucambra/commit-analysis-tool/pkg/store"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestTopologyReport(t *testing.T) {
+	ctx := context.Background()
+
+	commitStore := store.NewMemoryStore()
+
+	mergeTime := time.Date(2023, 4, 8, 17, 47, 43, 0, time.UTC).Unix()
+	commits := []*common.Commit{
+		{Id: "a", Author: common.Person{Email: "jb@videolan.org"}},
+		{Id: "b", Author: common.Person{Email: "someone@example.com"}, ParentIds: []string{"a"}},
+		{Id: "c", Author: common.Person{Email: "jb@videolan.org"}, ParentIds: []string{"a", "b"}, AuthorTime: mergeTime},
+		{Id: "d", Author: common.Person{Email: "jb@videolan.org"}, ParentIds: []string{"c", "b"}, AuthorTime: mergeTime},
+		{Id: "e", Author: common.Person{Email: "someone@example.com"}, ParentIds: []string{"d", "b"}, AuthorTime: mergeTime},
+	}
+
+	if err := commitStore.AddCommits(ctx, commits); err != nil {
+		t.Fatalf("Error adding commits: %s", err)
+	}
+
+	report := NewTopologyReport(testEmailGroups(), commitStore, "")
+	if err := report.Generate(ctx); err != nil {
+		t.Fatalf("Error generating report: %s", err)
+	}
+
+	if report.TotalCommits != 5 || report.TotalMerges != 3 {
+		t.Fatalf("Unexpected commit counts: %d commits, %d merges", report.TotalCommits, report.TotalMerges)
+	} else if report.MergeFrequency != 0.6 {
+		t.Fatalf("Unexpected merge frequency: %f", report.MergeFrequency)
+	}
+
+	expectedYearlyMerges := map[int]int{2023: 3}
+	if !cmp.Equal(expectedYearlyMerges, report.YearlyMerges) {
+		t.Fatalf("Unexpected yearly merges: %s", cmp.Diff(expectedYearlyMerges, report.YearlyMerges))
+	}
+
+	expectedGroupMerges := map[string]int{testGroupName: 2, fallbackGroupName: 1}
+	if !cmp.Equal(expectedGroupMerges, report.GroupMerges) {
+		t.Fatalf("Unexpected group merges: %s", cmp.Diff(expectedGroupMerges, report.GroupMerges))
+	}
+
+	if share := report.GroupMergeShare(""); math.Abs(share-100./3.) > 1e-9 {
+		t.Fatalf("Unexpected merge share of unknown group: %f", share)
+	}
+}
@@ -1,6 +1,8 @@
 package commitcoding
 
 import (
+	"context"
+	"fmt"
 	"log"
 	"regexp"
 
@@ -21,14 +23,21 @@ func NewCommitCodingReport(commits common.CommitMap, codeMap map[string][]string
 	}
 }
 
-func (ccr *CommitCodingReport) Generate() {
+func (ccr *CommitCodingReport) Generate(ctx context.Context) error {
 	for codeCategory, regexStringSlice := range ccr.CodeMap {
 		log.Printf("Finding commit matches for coding analysis category: %s", codeCategory)
 
 		codeCategoryCommits := []*common.Commit{}
 
 		for _, regexString := range regexStringSlice {
-			regex := regexp.MustCompile(regexString)
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+
+			regex, err := regexp.Compile(regexString)
+			if err != nil {
+				return fmt.Errorf("compiling pattern of coding category %s: %w", codeCategory, err)
+			}
 
 			for _, commit := range ccr.Commits {
 				fullCommitBody := commit.Subject + "\n" + commit.Body
@@ -41,4 +50,6 @@ func (ccr *CommitCodingReport) Generate() {
 
 		ccr.CodeMatchCommits[codeCategory] = codeCategoryCommits
 	}
+
+	return nil
 }
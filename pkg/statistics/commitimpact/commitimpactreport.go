@@ -1,11 +1,13 @@
 package commitimpact
 
 import (
+	"context"
+	"fmt"
 	"log"
+	"math"
 
 	"github.com/claucambra/commit-analysis-tool/pkg/common"
 	"github.com/claucambra/commit-analysis-tool/pkg/statistics/commitcoding"
-	"gonum.org/v1/gonum/stat"
 )
 
 const featureKey = "feature"
@@ -19,16 +21,21 @@ const deletionWeight = 0.7
 
 const suspiciouslyHighImpactThreshold = 5000
 
+// Mean impact of commits scored from their messages and line changes. Commits are read from the
+// iterator and scored a batch at a time, so only a batch of commits is held in memory at a time.
 type CommitImpactReport struct {
-	Commits    common.CommitMap
-	Impact     map[string]float64
+	// Number of commits given an impact score, and their mean score
+	NumScored  int
 	MeanImpact float64
+
+	commits     common.CommitIterator
+	totalImpact float64
 }
 
-func NewCommitImpactReport(commits common.CommitMap) *CommitImpactReport {
+func NewCommitImpactReport(commits common.CommitIterator) *CommitImpactReport {
 	return &CommitImpactReport{
-		Commits: commits,
-		Impact:  map[string]float64{},
+		MeanImpact: math.NaN(),
+		commits:    commits,
 	}
 }
 
@@ -52,29 +59,25 @@ func codingWeightMap() map[string]float64 {
 	}
 }
 
-func (cir *CommitImpactReport) generateImpacts(codeMatchCommits map[string][]*common.Commit) {
-	log.Printf("Generating commit impact scores.")
-
+// Scores the commits of a batch matching coding categories. Commits matching several categories
+// are weighted by the category weighing least, so that e.g. adding test data is not weighted as
+// testing.
+func (cir *CommitImpactReport) scoreCommits(commits common.CommitMap, codeMatchCommits map[string][]*common.Commit) {
 	codeWeightMap := codingWeightMap()
 	commitWeights := map[string]float64{}
-	commitImpacts := []float64{}
 
-	for codeCategory, commits := range codeMatchCommits {
-		for _, commit := range commits {
-			commitWeights[commit.Id] = codeWeightMap[codeCategory]
+	for codeCategory, categoryCommits := range codeMatchCommits {
+		for _, commit := range categoryCommits {
+			if weight, ok := commitWeights[commit.Id]; !ok || codeWeightMap[codeCategory] < weight {
+				commitWeights[commit.Id] = codeWeightMap[codeCategory]
+			}
 		}
 	}
 
 	for commitId, weight := range commitWeights {
-		commit, ok := cir.Commits[commitId]
-		if !ok {
-			log.Fatalf("Could not find commit with id %s in commit impact report commits.", commitId)
-			continue
-		}
-
+		commit := commits[commitId]
 		insertScore := float64(commit.NumInsertions) * insertionWeight
 		deleteScore := float64(commit.NumDeletions) * deletionWeight
-
 		impactScore := (insertScore + deleteScore) * weight
 
 		if impactScore > suspiciouslyHighImpactThreshold {
@@ -82,20 +85,49 @@ func (cir *CommitImpactReport) generateImpacts(codeMatchCommits map[string][]*co
 			continue
 		}
 
-		// Bias towards lower impact
-		commitImpacts = append(commitImpacts, impactScore)
-		cir.Impact[commitId] = impactScore
+		cir.NumScored++
+		cir.totalImpact += impactScore
 	}
+}
 
-	cir.MeanImpact = stat.Mean(commitImpacts, nil)
+func (cir *CommitImpactReport) scoreBatch(ctx context.Context, commits common.CommitMap) error {
+	codingReport := commitcoding.NewCommitCodingReport(commits, codeMap())
+	if err := codingReport.Generate(ctx); err != nil {
+		return err
+	}
 
-	log.Printf("Analysed %v commits, produced a mean impact score of %f", len(commitImpacts), cir.MeanImpact)
+	cir.scoreCommits(commits, codingReport.CodeMatchCommits)
+	return nil
 }
 
 // Not all commits we have will get impact scores, this depends on the CommitCodingReport
-func (cir *CommitImpactReport) Generate() {
-	codingReport := commitcoding.NewCommitCodingReport(cir.Commits, codeMap())
-	codingReport.Generate()
+func (cir *CommitImpactReport) Generate(ctx context.Context) error {
+	log.Printf("Generating commit impact scores.")
+
+	cir.NumScored = 0
+	cir.totalImpact = 0
+	batch := common.CommitMap{}
+
+	for cir.commits.Next() {
+		commit := cir.commits.Commit()
+		batch[commit.Id] = commit
+
+		if len(batch) < common.DefaultCommitPageSize {
+			continue
+		} else if err := cir.scoreBatch(ctx, batch); err != nil {
+			return err
+		}
+
+		batch = common.CommitMap{}
+	}
+
+	if err := cir.commits.Err(); err != nil {
+		return fmt.Errorf("reading commits to score: %w", err)
+	} else if err := cir.scoreBatch(ctx, batch); err != nil {
+		return err
+	}
 
-	cir.generateImpacts(codingReport.CodeMatchCommits)
+	cir.MeanImpact = cir.totalImpact / float64(cir.NumScored)
+	log.Printf("Analysed %v commits, produced a mean impact score of %f", cir.NumScored, cir.MeanImpact)
+	return nil
 }
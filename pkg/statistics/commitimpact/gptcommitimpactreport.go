@@ -3,6 +3,7 @@ package commitimpact
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"log"
 	"math/rand"
 	"strings"
@@ -53,7 +54,7 @@ func (gcir *GPTCommitImpactReport) randomCommits() []*common.Commit {
 	return randomCommitSlice
 }
 
-func (gcir *GPTCommitImpactReport) buildPromptString(commits []*common.Commit) string {
+func (gcir *GPTCommitImpactReport) buildPromptString(commits []*common.Commit) (string, error) {
 	promptString := "The following commit data is formatted as an array of JSON objects. "
 
 	promptString += "Commits containing bodies or subjects which describe new features are highly impactful. "
@@ -75,7 +76,7 @@ func (gcir *GPTCommitImpactReport) buildPromptString(commits []*common.Commit) s
 	for _, commit := range commits {
 		marshalledCommit, err := json.Marshal(commit)
 		if err != nil {
-			log.Fatalf("Could not marshal commit %+v, won't be used for impact analysis due to error: %s", commit, err)
+			return "", fmt.Errorf("marshalling commit %s for impact analysis: %w", commit.Id, err)
 		}
 
 		promptString += string(marshalledCommit) + ","
@@ -83,37 +84,40 @@ func (gcir *GPTCommitImpactReport) buildPromptString(commits []*common.Commit) s
 	promptString = strings.TrimSuffix(promptString, ",")
 	promptString += "]"
 
-	return promptString
+	return promptString, nil
 }
 
-func (gcir *GPTCommitImpactReport) generatePrompt() string {
+func (gcir *GPTCommitImpactReport) generatePrompt() (string, error) {
 	log.Printf("Generating prompt for openai request.")
 
 	promptCommits := gcir.randomCommits()
-	promptString := gcir.buildPromptString(promptCommits)
-
-	return promptString
+	return gcir.buildPromptString(promptCommits)
 }
 
-func (gcir *GPTCommitImpactReport) Generate() {
+func (gcir *GPTCommitImpactReport) Generate(ctx context.Context) error {
+	prompt, err := gcir.generatePrompt()
+	if err != nil {
+		return err
+	}
+
 	response, err := gcir.gptClient.CreateChatCompletion(
-		context.Background(),
+		ctx,
 		openai.ChatCompletionRequest{
 			Model:     gptModel,
 			MaxTokens: maxTokens,
 			Messages: []openai.ChatCompletionMessage{
 				{
 					Role:    openai.ChatMessageRoleUser,
-					Content: gcir.generatePrompt(),
+					Content: prompt,
 				},
 			},
 		},
 	)
 
 	if err != nil {
-		log.Fatalf("Error sending completion request to openai: %s", err)
-		return
+		return fmt.Errorf("sending completion request to openai: %w", err)
 	}
 
 	log.Printf("Received OpenAI response: %+v", response.Choices)
+	return nil
 }
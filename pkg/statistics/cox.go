@@ -0,0 +1,372 @@
+package statistics
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+
+	"gonum.org/v1/gonum/mat"
+	"gonum.org/v1/gonum/stat/distuv"
+)
+
+// Newton-Raphson iterations FitCox takes at most, and the relative change of the log partial
+// likelihood below which it stops
+const coxMaxIterations = 30
+const coxTolerance = 1e-9
+
+var errCoxNoEvents = errors.New("fitting a Cox model needs at least one death")
+var errCoxSingular = errors.New("covariates of the Cox model are collinear or constant")
+
+// Subject of a Cox proportional hazards model, with a value for each of the model's covariates
+type CoxObservation struct {
+	SurvivalObservation
+	Covariates []float64
+}
+
+type CoxCovariate struct {
+	Name string
+
+	Coefficient   float64
+	StandardError float64
+	// Factor the hazard is multiplied by for each unit of the covariate, with its confidence
+	// interval at SurvivalConfidenceLevel
+	HazardRatio float64
+	Lower       float64
+	Upper       float64
+	// Wald test of the covariate having no effect on the hazard
+	PValue float64
+
+	// Grambsch-Therneau test of the hazard ratio staying the same over time. Small p-values mean
+	// the proportional hazards assumption does not hold for the covariate.
+	ProportionalHazardsChiSquare float64
+	ProportionalHazardsPValue    float64
+}
+
+type CoxModel struct {
+	Covariates      []*CoxCovariate
+	NumObservations int
+	NumEvents       int
+	Iterations      int
+
+	// Log partial likelihood of the fitted model, and of the model without covariates
+	LogLikelihood     float64
+	NullLogLikelihood float64
+	// Likelihood ratio test of the covariates having no effect at all
+	LikelihoodRatioChiSquare float64
+	LikelihoodRatioPValue    float64
+
+	// Grambsch-Therneau test of the proportional hazards assumption for all covariates together
+	ProportionalHazardsChiSquare float64
+	ProportionalHazardsPValue    float64
+}
+
+// Sums over the subjects at risk, weighted by their risk exp(x·β): of the weights, of the
+// covariates and of the products of the covariates
+type coxRiskSums struct {
+	weights    float64
+	covariates []float64
+	products   *mat.SymDense
+}
+
+// Fits a Cox proportional hazards model by maximising the partial likelihood, handling tied death
+// times with the Breslow approximation
+func FitCox(covariateNames []string, observations []CoxObservation) (*CoxModel, error) {
+	numCovariates := len(covariateNames)
+	if numCovariates == 0 {
+		return nil, errors.New("fitting a Cox model needs at least one covariate")
+	}
+
+	numEvents := 0
+	for i, observation := range observations {
+		if len(observation.Covariates) != numCovariates {
+			return nil, fmt.Errorf("observation %d has %d covariates, expected %d", i, len(observation.Covariates), numCovariates)
+		} else if !observation.Censored {
+			numEvents++
+		}
+	}
+
+	if numEvents == 0 {
+		return nil, errCoxNoEvents
+	}
+
+	covariates := centredCovariates(observations, numCovariates)
+	// Longest durations first, so the subjects at risk at each time are accumulated in order
+	order := make([]int, len(observations))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return observations[order[i]].Duration > observations[order[j]].Duration
+	})
+
+	coefficients := mat.NewVecDense(numCovariates, nil)
+	logLikelihood, score, information := coxPartialLikelihood(observations, covariates, order, coefficients)
+	model := &CoxModel{
+		NumObservations:   len(observations),
+		NumEvents:         numEvents,
+		NullLogLikelihood: logLikelihood,
+	}
+
+	var variance mat.SymDense
+	for model.Iterations = 1; ; model.Iterations++ {
+		var cholesky mat.Cholesky
+		if ok := cholesky.Factorize(information); !ok {
+			return nil, errCoxSingular
+		}
+
+		if model.Iterations > coxMaxIterations {
+			return nil, fmt.Errorf("fitting a Cox model did not converge after %d iterations, a covariate may perfectly predict survival", coxMaxIterations)
+		}
+
+		var step mat.VecDense
+		if err := cholesky.SolveVecTo(&step, score); err != nil {
+			return nil, errCoxSingular
+		}
+
+		// Halve steps overshooting the maximum
+		var nextCoefficients *mat.VecDense
+		var nextLogLikelihood float64
+		var nextScore *mat.VecDense
+		var nextInformation *mat.SymDense
+		for halvings := 0; ; halvings++ {
+			nextCoefficients = mat.NewVecDense(numCovariates, nil)
+			nextCoefficients.AddVec(coefficients, &step)
+			nextLogLikelihood, nextScore, nextInformation = coxPartialLikelihood(observations, covariates, order, nextCoefficients)
+
+			if nextLogLikelihood >= logLikelihood || halvings == 10 {
+				break
+			}
+
+			step.ScaleVec(0.5, &step)
+		}
+
+		converged := math.Abs(nextLogLikelihood-logLikelihood) <= coxTolerance*math.Abs(nextLogLikelihood)
+		coefficients, logLikelihood, score, information = nextCoefficients, nextLogLikelihood, nextScore, nextInformation
+
+		if converged {
+			var cholesky mat.Cholesky
+			if ok := cholesky.Factorize(information); !ok {
+				return nil, errCoxSingular
+			} else if err := cholesky.InverseTo(&variance); err != nil {
+				return nil, errCoxSingular
+			}
+
+			break
+		}
+	}
+
+	model.LogLikelihood = logLikelihood
+	model.LikelihoodRatioChiSquare = 2 * (logLikelihood - model.NullLogLikelihood)
+	model.LikelihoodRatioPValue = distuv.ChiSquared{K: float64(numCovariates)}.Survival(model.LikelihoodRatioChiSquare)
+
+	z := distuv.UnitNormal.Quantile(1 - (1-SurvivalConfidenceLevel)/2)
+	for i, name := range covariateNames {
+		coefficient := coefficients.AtVec(i)
+		standardError := math.Sqrt(variance.At(i, i))
+
+		model.Covariates = append(model.Covariates, &CoxCovariate{
+			Name:          name,
+			Coefficient:   coefficient,
+			StandardError: standardError,
+			HazardRatio:   math.Exp(coefficient),
+			Lower:         math.Exp(coefficient - z*standardError),
+			Upper:         math.Exp(coefficient + z*standardError),
+			PValue:        2 * distuv.UnitNormal.Survival(math.Abs(coefficient/standardError)),
+		})
+	}
+
+	model.testProportionalHazards(observations, covariates, order, coefficients, &variance)
+	return model, nil
+}
+
+// Covariates minus their means, which leaves the coefficients unchanged but keeps the risks
+// exp(x·β) from overflowing
+func centredCovariates(observations []CoxObservation, numCovariates int) [][]float64 {
+	means := make([]float64, numCovariates)
+	for _, observation := range observations {
+		for j, value := range observation.Covariates {
+			means[j] += value / float64(len(observations))
+		}
+	}
+
+	covariates := make([][]float64, len(observations))
+	for i, observation := range observations {
+		covariates[i] = make([]float64, numCovariates)
+		for j, value := range observation.Covariates {
+			covariates[i][j] = value - means[j]
+		}
+	}
+
+	return covariates
+}
+
+// Calls eventTime for each distinct death time, latest first, with the observations dying at that
+// time and the sums over the observations at risk then
+func forEachCoxEventTime(
+	observations []CoxObservation,
+	covariates [][]float64,
+	order []int,
+	coefficients *mat.VecDense,
+	eventTime func(events []int, sums *coxRiskSums),
+) {
+	numCovariates := coefficients.Len()
+	sums := &coxRiskSums{
+		covariates: make([]float64, numCovariates),
+		products:   mat.NewSymDense(numCovariates, nil),
+	}
+
+	for i := 0; i < len(order); {
+		time := observations[order[i]].Duration
+		events := []int{}
+
+		for ; i < len(order) && observations[order[i]].Duration == time; i++ {
+			index := order[i]
+			weight := math.Exp(mat.Dot(mat.NewVecDense(numCovariates, covariates[index]), coefficients))
+
+			sums.weights += weight
+			for j := 0; j < numCovariates; j++ {
+				sums.covariates[j] += weight * covariates[index][j]
+				for k := j; k < numCovariates; k++ {
+					sums.products.SetSym(j, k, sums.products.At(j, k)+weight*covariates[index][j]*covariates[index][k])
+				}
+			}
+
+			if !observations[index].Censored {
+				events = append(events, index)
+			}
+		}
+
+		if len(events) > 0 {
+			eventTime(events, sums)
+		}
+	}
+}
+
+// Log partial likelihood of the coefficients, with its gradient and the information matrix (its
+// negated second derivatives)
+func coxPartialLikelihood(
+	observations []CoxObservation,
+	covariates [][]float64,
+	order []int,
+	coefficients *mat.VecDense,
+) (float64, *mat.VecDense, *mat.SymDense) {
+	numCovariates := coefficients.Len()
+	logLikelihood := 0.0
+	score := mat.NewVecDense(numCovariates, nil)
+	information := mat.NewSymDense(numCovariates, nil)
+
+	forEachCoxEventTime(observations, covariates, order, coefficients, func(events []int, sums *coxRiskSums) {
+		numEvents := float64(len(events))
+		for _, index := range events {
+			logLikelihood += mat.Dot(mat.NewVecDense(numCovariates, covariates[index]), coefficients)
+			for j := 0; j < numCovariates; j++ {
+				score.SetVec(j, score.AtVec(j)+covariates[index][j])
+			}
+		}
+
+		logLikelihood -= numEvents * math.Log(sums.weights)
+		for j := 0; j < numCovariates; j++ {
+			meanJ := sums.covariates[j] / sums.weights
+			score.SetVec(j, score.AtVec(j)-numEvents*meanJ)
+
+			for k := j; k < numCovariates; k++ {
+				meanK := sums.covariates[k] / sums.weights
+				covariance := sums.products.At(j, k)/sums.weights - meanJ*meanK
+				information.SetSym(j, k, information.At(j, k)+numEvents*covariance)
+			}
+		}
+	})
+
+	return logLikelihood, score, information
+}
+
+// Tests the correlation of the Schoenfeld residuals, each death's covariates minus the mean
+// covariates of those at risk, with the ranks of the death times, like R's cox.zph with
+// transform = "rank" and terms = FALSE
+func (model *CoxModel) testProportionalHazards(
+	observations []CoxObservation,
+	covariates [][]float64,
+	order []int,
+	coefficients *mat.VecDense,
+	variance *mat.SymDense,
+) {
+	numCovariates := coefficients.Len()
+	residuals := [][]float64{}
+	eventTimes := []float64{}
+
+	forEachCoxEventTime(observations, covariates, order, coefficients, func(events []int, sums *coxRiskSums) {
+		for _, index := range events {
+			residual := make([]float64, numCovariates)
+			for j := range residual {
+				residual[j] = covariates[index][j] - sums.covariates[j]/sums.weights
+			}
+
+			residuals = append(residuals, residual)
+			eventTimes = append(eventTimes, observations[index].Duration)
+		}
+	})
+
+	ranks := averageRanks(eventTimes)
+	meanRank := float64(len(ranks)+1) / 2
+	sumSquares := 0.0
+	rankResiduals := mat.NewVecDense(numCovariates, nil)
+	for i, rank := range ranks {
+		centredRank := rank - meanRank
+		sumSquares += centredRank * centredRank
+
+		for j := 0; j < numCovariates; j++ {
+			rankResiduals.SetVec(j, rankResiduals.AtVec(j)+centredRank*residuals[i][j])
+		}
+	}
+
+	model.ProportionalHazardsChiSquare = math.NaN()
+	model.ProportionalHazardsPValue = math.NaN()
+	if sumSquares == 0 {
+		for _, covariate := range model.Covariates {
+			covariate.ProportionalHazardsChiSquare = math.NaN()
+			covariate.ProportionalHazardsPValue = math.NaN()
+		}
+
+		return
+	}
+
+	numEvents := float64(len(ranks))
+	var scaled mat.VecDense
+	scaled.MulVec(variance, rankResiduals)
+
+	for j, covariate := range model.Covariates {
+		covariate.ProportionalHazardsChiSquare = numEvents * scaled.AtVec(j) * scaled.AtVec(j) / (variance.At(j, j) * sumSquares)
+		covariate.ProportionalHazardsPValue = distuv.ChiSquared{K: 1}.Survival(covariate.ProportionalHazardsChiSquare)
+	}
+
+	model.ProportionalHazardsChiSquare = numEvents * mat.Dot(rankResiduals, &scaled) / sumSquares
+	model.ProportionalHazardsPValue = distuv.ChiSquared{K: float64(numCovariates)}.Survival(model.ProportionalHazardsChiSquare)
+}
+
+// Ranks of the values starting from 1, with tied values sharing the mean of their ranks
+func averageRanks(values []float64) []float64 {
+	order := make([]int, len(values))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool { return values[order[i]] < values[order[j]] })
+
+	ranks := make([]float64, len(values))
+	for i := 0; i < len(order); {
+		j := i
+		for j < len(order) && values[order[j]] == values[order[i]] {
+			j++
+		}
+
+		// Mean of the ranks i + 1 to j
+		rank := float64(i+1+j) / 2
+		for k := i; k < j; k++ {
+			ranks[order[k]] = rank
+		}
+
+		i = j
+	}
+
+	return ranks
+}
@@ -0,0 +1,108 @@
+package statistics
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+// Acute myelogenous leukaemia remission times of R's survival package, with whether maintenance
+// chemotherapy was withheld and, as a second covariate, a tenth of the time for maintained patients
+// so the model has a hazard ratio changing over time. Negative times are censored.
+func amlObservations() []CoxObservation {
+	observations := []CoxObservation{}
+	add := func(notMaintained float64, times ...float64) {
+		for _, time := range times {
+			observation := CoxObservation{SurvivalObservation: SurvivalObservation{Duration: time}}
+			if time < 0 {
+				observation.Duration, observation.Censored = -time, true
+			}
+
+			observation.Covariates = []float64{notMaintained, (1 - notMaintained) * observation.Duration / 10}
+			observations = append(observations, observation)
+		}
+	}
+
+	add(0, 9, 13, -13, 18, 23, -28, 31, 34, -45, 48, -161)
+	add(1, 5, 5, 8, 8, 12, -16, 23, 27, 30, 33, 43, 45)
+	return observations
+}
+
+func TestFitCox(t *testing.T) {
+	observations := amlObservations()
+	singleCovariateObservations := make([]CoxObservation, len(observations))
+	for i, observation := range observations {
+		singleCovariateObservations[i] = observation
+		singleCovariateObservations[i].Covariates = observation.Covariates[:1]
+	}
+
+	// As fitted by R's coxph(Surv(time, status) ~ x, aml, ties = "breslow")
+	model, err := FitCox([]string{"not_maintained"}, singleCovariateObservations)
+	if err != nil {
+		t.Fatalf("Error fitting model: %s", err)
+	}
+
+	approx := cmpopts.EquateApprox(0, 1e-3)
+	covariate := model.Covariates[0]
+	if model.NumObservations != 23 || model.NumEvents != 18 {
+		t.Fatalf("Unexpected %d observations and %d events", model.NumObservations, model.NumEvents)
+	} else if !cmp.Equal(0.9042, covariate.Coefficient, approx) || !cmp.Equal(0.5122, covariate.StandardError, approx) {
+		t.Fatalf("Unexpected coefficient %f with standard error %f", covariate.Coefficient, covariate.StandardError)
+	} else if !cmp.Equal(2.470, covariate.HazardRatio, approx) || !cmp.Equal(0.9050, covariate.Lower, approx) || !cmp.Equal(6.741, covariate.Upper, approx) {
+		t.Fatalf("Unexpected hazard ratio %f in [%f, %f]", covariate.HazardRatio, covariate.Lower, covariate.Upper)
+	} else if !cmp.Equal(3.296, model.LikelihoodRatioChiSquare, approx) {
+		t.Fatalf("Unexpected likelihood ratio chi-square %f", model.LikelihoodRatioChiSquare)
+	} else if covariate.ProportionalHazardsPValue < 0.5 {
+		t.Fatalf("Unexpected proportional hazards p-value %f for a constant hazard ratio", covariate.ProportionalHazardsPValue)
+	}
+
+	model, err = FitCox([]string{"not_maintained", "maintained_time"}, observations)
+	if err != nil {
+		t.Fatalf("Error fitting model: %s", err)
+	}
+
+	expectedCoefficients := []float64{-2.4234, -1.0858}
+	expectedStandardErrors := []float64{1.1369, 0.4149}
+	expectedProportionalHazards := []float64{2.0849, 0.3183}
+	for i, covariate := range model.Covariates {
+		if !cmp.Equal(expectedCoefficients[i], covariate.Coefficient, approx) || !cmp.Equal(expectedStandardErrors[i], covariate.StandardError, approx) {
+			t.Fatalf("Unexpected coefficient %f of %s with standard error %f", covariate.Coefficient, covariate.Name, covariate.StandardError)
+		} else if !cmp.Equal(expectedProportionalHazards[i], covariate.ProportionalHazardsChiSquare, approx) {
+			t.Fatalf("Unexpected proportional hazards chi-square %f of %s", covariate.ProportionalHazardsChiSquare, covariate.Name)
+		}
+	}
+
+	if !cmp.Equal(4.1285, model.ProportionalHazardsChiSquare, approx) {
+		t.Fatalf("Unexpected global proportional hazards chi-square %f", model.ProportionalHazardsChiSquare)
+	}
+}
+
+func TestFitCoxErrors(t *testing.T) {
+	observations := amlObservations()
+
+	censored := make([]CoxObservation, len(observations))
+	for i, observation := range observations {
+		censored[i] = observation
+		censored[i].Censored = true
+	}
+
+	if _, err := FitCox([]string{"not_maintained", "maintained_time"}, censored); !errors.Is(err, errCoxNoEvents) {
+		t.Fatalf("Unexpected error %v fitting a model without deaths", err)
+	}
+
+	constant := make([]CoxObservation, len(observations))
+	for i, observation := range observations {
+		constant[i] = observation
+		constant[i].Covariates = []float64{observation.Covariates[0], 1}
+	}
+
+	if _, err := FitCox([]string{"not_maintained", "constant"}, constant); !errors.Is(err, errCoxSingular) {
+		t.Fatalf("Unexpected error %v fitting a model with a constant covariate", err)
+	}
+
+	if _, err := FitCox([]string{"not_maintained"}, observations); err == nil {
+		t.Fatalf("Expected an error fitting observations with more covariates than named")
+	}
+}
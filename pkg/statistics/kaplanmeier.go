@@ -0,0 +1,134 @@
+package statistics
+
+import (
+	"math"
+	"sort"
+
+	"gonum.org/v1/gonum/stat/distuv"
+)
+
+// Confidence level of the bands of Kaplan-Meier estimates
+const SurvivalConfidenceLevel = 0.95
+
+// How long a subject was observed for. Censored subjects were still alive when observation
+// ended, e.g. authors still active when the data ends, so their duration is a lower bound.
+type SurvivalObservation struct {
+	Duration float64
+	Censored bool
+}
+
+// Kaplan-Meier estimate at one of the observed durations
+type KaplanMeierStep struct {
+	Time float64
+	// Subjects observed for at least Time, and those of them that died or were censored at Time
+	AtRisk   int
+	Events   int
+	Censored int
+
+	// Probability of surviving beyond Time
+	Survival float64
+	// Greenwood estimate of the variance of Survival
+	Variance float64
+	// Confidence band of Survival at SurvivalConfidenceLevel, using the log(-log) transform so
+	// the band stays within 0 and 1
+	Lower float64
+	Upper float64
+}
+
+type KaplanMeierEstimate struct {
+	// One step per distinct observed duration, in order
+	Steps []*KaplanMeierStep
+	// Shortest duration at which survival falls to 0.5 or below, NaN if it never does
+	Median float64
+}
+
+// Estimates survival from right-censored observations. Subjects censored at the same time as
+// others die are considered at risk at that time.
+func KaplanMeier(observations []SurvivalObservation) *KaplanMeierEstimate {
+	sorted := append([]SurvivalObservation{}, observations...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Duration < sorted[j].Duration })
+
+	z := distuv.UnitNormal.Quantile(1 - (1-SurvivalConfidenceLevel)/2)
+	estimate := &KaplanMeierEstimate{Steps: []*KaplanMeierStep{}, Median: math.NaN()}
+	atRisk := len(sorted)
+	survival := 1.0
+	// Sum of the Greenwood terms d / (n * (n - d)) so far
+	greenwoodSum := 0.0
+
+	for i := 0; i < len(sorted); {
+		step := &KaplanMeierStep{Time: sorted[i].Duration, AtRisk: atRisk}
+		for ; i < len(sorted) && sorted[i].Duration == step.Time; i++ {
+			if sorted[i].Censored {
+				step.Censored++
+			} else {
+				step.Events++
+			}
+		}
+
+		if step.Events > 0 {
+			survival *= 1 - float64(step.Events)/float64(atRisk)
+			if step.Events < atRisk {
+				greenwoodSum += float64(step.Events) / float64(atRisk*(atRisk-step.Events))
+			}
+		}
+
+		step.Survival = survival
+		step.Variance = survival * survival * greenwoodSum
+		step.Lower, step.Upper = logLogConfidenceBand(survival, greenwoodSum, z)
+
+		if math.IsNaN(estimate.Median) && survival <= 0.5 {
+			estimate.Median = step.Time
+		}
+
+		estimate.Steps = append(estimate.Steps, step)
+		atRisk -= step.Events + step.Censored
+	}
+
+	return estimate
+}
+
+func logLogConfidenceBand(survival float64, greenwoodSum float64, z float64) (float64, float64) {
+	if survival <= 0 || survival >= 1 {
+		return survival, survival
+	}
+
+	logSurvival := math.Log(survival)
+	standardError := math.Sqrt(greenwoodSum) / math.Abs(logSurvival)
+
+	return math.Pow(survival, math.Exp(z*standardError)), math.Pow(survival, math.Exp(-z*standardError))
+}
+
+// Step in effect at the time, i.e. the last step at or before it, nil before the first step
+func (estimate *KaplanMeierEstimate) StepAt(time float64) *KaplanMeierStep {
+	index := sort.Search(len(estimate.Steps), func(i int) bool { return estimate.Steps[i].Time > time })
+	if index == 0 {
+		return nil
+	}
+
+	return estimate.Steps[index-1]
+}
+
+// Probability of surviving beyond the time
+func (estimate *KaplanMeierEstimate) SurvivalAt(time float64) float64 {
+	if step := estimate.StepAt(time); step != nil {
+		return step.Survival
+	}
+
+	return 1
+}
+
+// Survival beyond each whole time step up to the last observed duration, like
+// TimeStepPopulation.KaplanMeierSurvival
+func (estimate *KaplanMeierEstimate) TimeStepSurvival() TimeStepSurvival {
+	if len(estimate.Steps) == 0 {
+		return TimeStepSurvival{}
+	}
+
+	lastTime := int(math.Ceil(estimate.Steps[len(estimate.Steps)-1].Time))
+	survival := make(TimeStepSurvival, lastTime)
+	for i := range survival {
+		survival[i] = estimate.SurvivalAt(float64(i))
+	}
+
+	return survival
+}
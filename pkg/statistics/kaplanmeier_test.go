@@ -0,0 +1,82 @@
+package statistics
+
+import (
+	"math"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+func TestKaplanMeier(t *testing.T) {
+	observations := []SurvivalObservation{
+		{Duration: 5},
+		{Duration: 2, Censored: true},
+		{Duration: 1},
+		{Duration: 4, Censored: true},
+		{Duration: 2},
+		{Duration: 3},
+	}
+
+	estimate := KaplanMeier(observations)
+
+	expectedTimes := []float64{1, 2, 3, 4, 5}
+	expectedAtRisk := []int{6, 5, 3, 2, 1}
+	expectedSurvival := []float64{5. / 6., 2. / 3., 4. / 9., 4. / 9., 0}
+	// S(t)² times the sum of d / (n * (n - d)) over the events up to t
+	expectedVariance := []float64{
+		(25. / 36.) * (1. / 30.),
+		(4. / 9.) * (1./30. + 1./20.),
+		(16. / 81.) * (1./30. + 1./20. + 1./6.),
+		(16. / 81.) * (1./30. + 1./20. + 1./6.),
+		0,
+	}
+
+	if len(estimate.Steps) != len(expectedTimes) {
+		t.Fatalf("Unexpected number of steps %d, expected %d", len(estimate.Steps), len(expectedTimes))
+	}
+
+	approx := cmpopts.EquateApprox(0, 1e-9)
+	for i, step := range estimate.Steps {
+		if step.Time != expectedTimes[i] || step.AtRisk != expectedAtRisk[i] {
+			t.Fatalf("Unexpected step %d at time %f with %d at risk", i, step.Time, step.AtRisk)
+		} else if !cmp.Equal(expectedSurvival[i], step.Survival, approx) {
+			t.Fatalf("Unexpected survival %f at time %f, expected %f", step.Survival, step.Time, expectedSurvival[i])
+		} else if !cmp.Equal(expectedVariance[i], step.Variance, approx) {
+			t.Fatalf("Unexpected variance %f at time %f, expected %f", step.Variance, step.Time, expectedVariance[i])
+		} else if step.Survival > 0 && step.Survival < 1 && !(step.Lower < step.Survival && step.Survival < step.Upper) {
+			t.Fatalf("Survival %f at time %f outside of its confidence band [%f, %f]", step.Survival, step.Time, step.Lower, step.Upper)
+		}
+	}
+
+	if estimate.Median != 3 {
+		t.Fatalf("Unexpected median %f, expected 3", estimate.Median)
+	}
+
+	if survival := estimate.SurvivalAt(0.5); survival != 1 {
+		t.Fatalf("Unexpected survival %f before the first event", survival)
+	} else if survival := estimate.SurvivalAt(3.5); !cmp.Equal(4./9., survival, approx) {
+		t.Fatalf("Unexpected survival %f between events", survival)
+	}
+
+	if median := KaplanMeier(observations[1:2]).Median; !math.IsNaN(median) {
+		t.Fatalf("Unexpected median %f when all observations are censored", median)
+	}
+}
+
+func TestKaplanMeierWithoutCensoring(t *testing.T) {
+	population := TimeStepPopulation{5, 4, 4, 2, 1}
+	observations := []SurvivalObservation{
+		{Duration: 1},
+		{Duration: 3}, {Duration: 3},
+		{Duration: 4},
+		{Duration: 5},
+	}
+
+	expectedSurvival := population.KaplanMeierSurvival()
+	survival := KaplanMeier(observations).TimeStepSurvival()
+
+	if !cmp.Equal(expectedSurvival, survival, cmpopts.EquateApprox(0, 1e-9)) {
+		t.Fatalf("Survival without censoring does not match population survival: %s", cmp.Diff(expectedSurvival, survival))
+	}
+}
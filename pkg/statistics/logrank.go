@@ -0,0 +1,173 @@
+package statistics
+
+import (
+	"errors"
+	"math"
+	"sort"
+
+	"gonum.org/v1/gonum/mat"
+	"gonum.org/v1/gonum/stat/distuv"
+)
+
+// How the differences between observed and expected deaths are weighted at each time by LogRank
+type LogRankWeighting int
+
+const (
+	// Log-rank test, weighing all times equally
+	LogRankUnweighted LogRankWeighting = iota
+	// Gehan-Breslow generalised Wilcoxon test, weighing times by the number of subjects at risk,
+	// so early differences count more
+	LogRankWilcoxon
+	// Peto-Peto test, weighing times by the pooled Kaplan-Meier survival just before them, like R's
+	// survdiff with rho = 1
+	LogRankPeto
+)
+
+var errTooFewLogRankGroups = errors.New("comparing survival needs at least two groups")
+
+type LogRankTest struct {
+	Weighting LogRankWeighting
+
+	// Chi-square statistic, with one degree of freedom fewer than the number of groups
+	Statistic        float64
+	DegreesOfFreedom int
+	// Probability of a statistic at least this large if all groups share the same survival, NaN if
+	// the groups could not be compared, e.g. when there are no deaths
+	PValue float64
+
+	// Deaths in each group, and the deaths expected if all groups shared the same survival
+	Observed []float64
+	Expected []float64
+}
+
+type logRankTime struct {
+	time float64
+	// Of each group, subjects observed for at least time and those of them that died at time
+	atRisk []int
+	events []int
+}
+
+// Compares the survival of two or more groups of right-censored observations, testing whether
+// they all share the same survival
+func LogRank(groups [][]SurvivalObservation, weighting LogRankWeighting) (*LogRankTest, error) {
+	numGroups := len(groups)
+	if numGroups < 2 {
+		return nil, errTooFewLogRankGroups
+	}
+
+	test := &LogRankTest{
+		Weighting:        weighting,
+		Statistic:        math.NaN(),
+		DegreesOfFreedom: numGroups - 1,
+		PValue:           math.NaN(),
+		Observed:         make([]float64, numGroups),
+		Expected:         make([]float64, numGroups),
+	}
+
+	// Weighted observed minus expected deaths and their covariance, leaving out the last group as
+	// it is determined by the others
+	scores := mat.NewVecDense(numGroups-1, nil)
+	covariance := mat.NewDense(numGroups-1, numGroups-1, nil)
+	// Pooled Kaplan-Meier survival just before the current time, for LogRankPeto
+	pooledSurvival := 1.0
+
+	for _, rankTime := range logRankTimes(groups) {
+		atRisk, events := 0, 0
+		for i := range groups {
+			atRisk += rankTime.atRisk[i]
+			events += rankTime.events[i]
+		}
+
+		if events == 0 {
+			continue
+		}
+
+		weight := 1.0
+		if weighting == LogRankWilcoxon {
+			weight = float64(atRisk)
+		} else if weighting == LogRankPeto {
+			weight = pooledSurvival
+		}
+
+		eventRate := float64(events) / float64(atRisk)
+		varianceFactor := 0.0
+		if atRisk > 1 {
+			varianceFactor = weight * weight * float64(events) * float64(atRisk-events) / float64(atRisk-1)
+		}
+
+		for i := range groups {
+			expected := float64(rankTime.atRisk[i]) * eventRate
+			test.Observed[i] += float64(rankTime.events[i])
+			test.Expected[i] += expected
+
+			if i == numGroups-1 {
+				continue
+			}
+
+			scores.SetVec(i, scores.AtVec(i)+weight*(float64(rankTime.events[i])-expected))
+
+			share := float64(rankTime.atRisk[i]) / float64(atRisk)
+			for j := 0; j < numGroups-1; j++ {
+				otherShare := float64(rankTime.atRisk[j]) / float64(atRisk)
+				covariance.Set(i, j, covariance.At(i, j)-varianceFactor*share*otherShare)
+			}
+			covariance.Set(i, i, covariance.At(i, i)+varianceFactor*share)
+		}
+
+		pooledSurvival *= 1 - eventRate
+	}
+
+	var solution mat.VecDense
+	if err := solution.SolveVec(covariance, scores); err != nil {
+		// Singular covariance, e.g. without deaths or with a group without subjects at risk
+		return test, nil
+	}
+
+	test.Statistic = mat.Dot(scores, &solution)
+	test.PValue = distuv.ChiSquared{K: float64(test.DegreesOfFreedom)}.Survival(test.Statistic)
+	return test, nil
+}
+
+// Distinct observed durations of all groups, in order, with each group's subjects at risk and deaths
+func logRankTimes(groups [][]SurvivalObservation) []*logRankTime {
+	timesMap := map[float64]*logRankTime{}
+	for i, group := range groups {
+		for _, observation := range group {
+			rankTime, ok := timesMap[observation.Duration]
+			if !ok {
+				rankTime = &logRankTime{
+					time:   observation.Duration,
+					atRisk: make([]int, len(groups)),
+					events: make([]int, len(groups)),
+				}
+				timesMap[observation.Duration] = rankTime
+			}
+
+			if !observation.Censored {
+				rankTime.events[i]++
+			}
+		}
+	}
+
+	times := make([]*logRankTime, 0, len(timesMap))
+	for _, rankTime := range timesMap {
+		times = append(times, rankTime)
+	}
+
+	sort.Slice(times, func(i, j int) bool { return times[i].time < times[j].time })
+
+	for i, group := range groups {
+		for _, observation := range group {
+			// Subjects are at risk at every time up to and including their duration
+			for _, rankTime := range times {
+				if rankTime.time > observation.Duration {
+					break
+				}
+
+				rankTime.atRisk[i]++
+			}
+		}
+	}
+
+	return times
+}
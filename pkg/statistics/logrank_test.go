@@ -0,0 +1,75 @@
+package statistics
+
+import (
+	"math"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+func TestLogRank(t *testing.T) {
+	groups := [][]SurvivalObservation{
+		{{Duration: 1}, {Duration: 2}},
+		{{Duration: 3}, {Duration: 4}},
+	}
+
+	// Observed minus expected deaths of the first group, and its variance, are 7/6 and 17/36
+	// unweighted, 4 and 6 weighted by subjects at risk (4 then 3), and 1 and 3/8 weighted by the
+	// pooled survival (1 then 3/4)
+	expectedStatistics := map[LogRankWeighting]float64{
+		LogRankUnweighted: 49. / 17.,
+		LogRankWilcoxon:   8. / 3.,
+		LogRankPeto:       8. / 3.,
+	}
+	expectedPValues := map[LogRankWeighting]float64{
+		LogRankUnweighted: 0.08955507441364256,
+		LogRankWilcoxon:   0.10247043485974947,
+		LogRankPeto:       0.10247043485974947,
+	}
+
+	approx := cmpopts.EquateApprox(0, 1e-9)
+	for weighting, expectedStatistic := range expectedStatistics {
+		test, err := LogRank(groups, weighting)
+		if err != nil {
+			t.Fatalf("Error testing survival with weighting %d: %s", weighting, err)
+		} else if !cmp.Equal(expectedStatistic, test.Statistic, approx) {
+			t.Fatalf("Unexpected statistic %f with weighting %d, expected %f", test.Statistic, weighting, expectedStatistic)
+		} else if !cmp.Equal(expectedPValues[weighting], test.PValue, approx) {
+			t.Fatalf("Unexpected p-value %f with weighting %d, expected %f", test.PValue, weighting, expectedPValues[weighting])
+		} else if test.DegreesOfFreedom != 1 {
+			t.Fatalf("Unexpected degrees of freedom %d", test.DegreesOfFreedom)
+		}
+	}
+
+	test, _ := LogRank(groups, LogRankUnweighted)
+	expectedObserved := []float64{2, 2}
+	expectedExpected := []float64{5. / 6., 19. / 6.}
+	if !cmp.Equal(expectedObserved, test.Observed, approx) || !cmp.Equal(expectedExpected, test.Expected, approx) {
+		t.Fatalf("Unexpected observed %v and expected %v deaths", test.Observed, test.Expected)
+	}
+}
+
+func TestLogRankSameSurvival(t *testing.T) {
+	group := []SurvivalObservation{{Duration: 1}, {Duration: 2, Censored: true}, {Duration: 3}}
+
+	test, err := LogRank([][]SurvivalObservation{group, group, group}, LogRankUnweighted)
+	if err != nil {
+		t.Fatalf("Error testing survival: %s", err)
+	} else if test.DegreesOfFreedom != 2 {
+		t.Fatalf("Unexpected degrees of freedom %d", test.DegreesOfFreedom)
+	} else if math.Abs(test.Statistic) > 1e-9 || math.Abs(test.PValue-1) > 1e-9 {
+		t.Fatalf("Unexpected statistic %f and p-value %f for groups with the same survival", test.Statistic, test.PValue)
+	}
+
+	censored := []SurvivalObservation{{Duration: 1, Censored: true}}
+	if test, err := LogRank([][]SurvivalObservation{censored, censored}, LogRankUnweighted); err != nil {
+		t.Fatalf("Error testing survival: %s", err)
+	} else if !math.IsNaN(test.PValue) {
+		t.Fatalf("Unexpected p-value %f without deaths", test.PValue)
+	}
+
+	if _, err := LogRank([][]SurvivalObservation{group}, LogRankUnweighted); err == nil {
+		t.Fatalf("Expected an error testing the survival of a single group")
+	}
+}
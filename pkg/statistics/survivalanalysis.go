@@ -3,6 +3,9 @@ package statistics
 type TimeStepPopulation []int
 type TimeStepSurvival []float64
 
+// Survival from the number of subjects alive at each time step, counting every drop in population
+// as deaths. Subjects still alive when observation ended are not told apart, use KaplanMeier to
+// account for them.
 func (tsp *TimeStepPopulation) KaplanMeierSurvival() TimeStepSurvival {
 	tspLen := len(*tsp)
 	survival := make(TimeStepSurvival, tspLen)
@@ -0,0 +1,318 @@
+package statistics
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"gonum.org/v1/gonum/mat"
+	"gonum.org/v1/gonum/stat"
+	"gonum.org/v1/gonum/stat/distuv"
+)
+
+// How the correlation of two series is measured
+type CorrelationMethod int
+
+const (
+	// Linear correlation of the values
+	PearsonCorrelation CorrelationMethod = iota
+	// Linear correlation of the ranks of the values
+	SpearmanCorrelation
+	// Kendall's tau-b, from the pairs of values ordered the same way in both series
+	KendallCorrelation
+)
+
+// How series are transformed before being compared, so that trends they share, e.g. both growing
+// over the years, are not mistaken for the series moving together
+type SeriesTransform int
+
+const (
+	// Compare the values as they are
+	NoTransform SeriesTransform = iota
+	// Compare what is left of the values after fitting a straight line over time
+	DetrendTransform
+	// Compare the changes from each value to the next, which leaves the series one value shorter
+	DifferenceTransform
+)
+
+var errSeriesLengthMismatch = errors.New("series to compare are of different lengths")
+
+// Correlation of two series, one of them shifted by Lag
+type SeriesCorrelation struct {
+	Lag         int
+	Correlation float64
+	// Pairs of values the correlation is measured on
+	NumPairs int
+	// Probability of a correlation at least as strong if the series are unrelated, NaN with fewer
+	// than three pairs
+	PValue float64
+}
+
+type CrossCorrelation struct {
+	Method    CorrelationMethod
+	Transform SeriesTransform
+
+	// Correlations from the lowest to the highest lag. At positive lags, values of the first
+	// series are compared to later values of the second, i.e. the first series leads.
+	Lags []*SeriesCorrelation
+	// Lag with the strongest correlation, positive or negative, nil if no lag could be measured
+	Best *SeriesCorrelation
+	// P-value of Best corrected for having picked it among all lags (Bonferroni)
+	BestAdjustedPValue float64
+}
+
+// Granger causality test of whether past values of one series help predict another series beyond
+// what the other series' own past values predict
+type GrangerTest struct {
+	// Number of past values of each series the predictions are made from
+	Lags int
+
+	// F statistic comparing the predictions with and without the past values of the cause
+	FStatistic       float64
+	DegreesOfFreedom [2]int
+	// Probability of an F statistic at least this large if the cause does not help predict the
+	// effect, NaN if the series are too short for the number of lags
+	PValue float64
+}
+
+// Transformed copy of the series, see SeriesTransform
+func TransformSeries(series []float64, transform SeriesTransform) []float64 {
+	switch transform {
+	case DetrendTransform:
+		if len(series) < 2 {
+			return make([]float64, len(series))
+		}
+
+		times := make([]float64, len(series))
+		for i := range times {
+			times[i] = float64(i)
+		}
+
+		alpha, beta := stat.LinearRegression(times, series, nil, false)
+		residuals := make([]float64, len(series))
+		for i, value := range series {
+			residuals[i] = value - (alpha + beta*times[i])
+		}
+
+		return residuals
+	case DifferenceTransform:
+		if len(series) < 2 {
+			return []float64{}
+		}
+
+		differences := make([]float64, len(series)-1)
+		for i := range differences {
+			differences[i] = series[i+1] - series[i]
+		}
+
+		return differences
+	default:
+		return append([]float64{}, series...)
+	}
+}
+
+// Correlation of two series of the same length with the method, and its two-sided p-value
+func Correlate(x []float64, y []float64, method CorrelationMethod) (float64, float64, error) {
+	if len(x) != len(y) {
+		return math.NaN(), math.NaN(), errSeriesLengthMismatch
+	} else if len(x) < 3 {
+		return math.NaN(), math.NaN(), nil
+	}
+
+	if method == KendallCorrelation {
+		tau, pValue := kendallTauB(x, y)
+		return tau, pValue, nil
+	} else if method == SpearmanCorrelation {
+		x, y = averageRanks(x), averageRanks(y)
+	}
+
+	correlation := stat.Correlation(x, y, nil)
+	if math.IsNaN(correlation) {
+		return correlation, math.NaN(), nil
+	} else if math.Abs(correlation) >= 1 {
+		return correlation, 0, nil
+	}
+
+	// Student's t test of the correlation, which for Spearman's holds approximately
+	degreesOfFreedom := float64(len(x) - 2)
+	t := correlation * math.Sqrt(degreesOfFreedom/(1-correlation*correlation))
+	studentsT := distuv.StudentsT{Mu: 0, Sigma: 1, Nu: degreesOfFreedom}
+	return correlation, 2 * studentsT.Survival(math.Abs(t)), nil
+}
+
+// Kendall's tau-b with the p-value of its normal approximation, corrected for ties
+func kendallTauB(x []float64, y []float64) (float64, float64) {
+	concordant, discordant, xTies, yTies := 0., 0., 0., 0.
+	for i := 0; i < len(x); i++ {
+		for j := i + 1; j < len(x); j++ {
+			xSign, ySign := x[j]-x[i], y[j]-y[i]
+			switch {
+			case xSign == 0 && ySign == 0:
+			case xSign == 0:
+				xTies++
+			case ySign == 0:
+				yTies++
+			case (xSign > 0) == (ySign > 0):
+				concordant++
+			default:
+				discordant++
+			}
+		}
+	}
+
+	denominator := math.Sqrt((concordant + discordant + xTies) * (concordant + discordant + yTies))
+	if denominator == 0 {
+		return math.NaN(), math.NaN()
+	}
+
+	tau := (concordant - discordant) / denominator
+
+	n := float64(len(x))
+	variance := n * (n - 1) * (2*n + 5) / 18
+	xTieSums, yTieSums := tieGroupSums(x), tieGroupSums(y)
+	variance -= (xTieSums[2] + yTieSums[2]) / 18
+	variance += xTieSums[0] * yTieSums[0] / (2 * n * (n - 1))
+	variance += xTieSums[1] * yTieSums[1] / (9 * n * (n - 1) * (n - 2))
+
+	if variance <= 0 {
+		return tau, math.NaN()
+	}
+
+	z := (concordant - discordant) / math.Sqrt(variance)
+	return tau, 2 * distuv.UnitNormal.Survival(math.Abs(z))
+}
+
+// Sums of t(t-1), t(t-1)(t-2) and t(t-1)(2t+5) over the sizes t of the groups of tied values
+func tieGroupSums(values []float64) [3]float64 {
+	groupSizes := map[float64]float64{}
+	for _, value := range values {
+		groupSizes[value]++
+	}
+
+	sums := [3]float64{}
+	for _, t := range groupSizes {
+		sums[0] += t * (t - 1)
+		sums[1] += t * (t - 1) * (t - 2)
+		sums[2] += t * (t - 1) * (2*t + 5)
+	}
+
+	return sums
+}
+
+// Correlates the transformed series at each lag from -maxLag to maxLag
+func CrossCorrelate(x []float64, y []float64, maxLag int, method CorrelationMethod, transform SeriesTransform) (*CrossCorrelation, error) {
+	if len(x) != len(y) {
+		return nil, errSeriesLengthMismatch
+	} else if maxLag < 0 {
+		return nil, fmt.Errorf("maximum lag %d is negative", maxLag)
+	}
+
+	x, y = TransformSeries(x, transform), TransformSeries(y, transform)
+	crossCorrelation := &CrossCorrelation{
+		Method:             method,
+		Transform:          transform,
+		Lags:               []*SeriesCorrelation{},
+		BestAdjustedPValue: math.NaN(),
+	}
+
+	for lag := -maxLag; lag <= maxLag; lag++ {
+		laggedX, laggedY := laggedPairs(x, y, lag)
+		correlation, pValue, err := Correlate(laggedX, laggedY, method)
+		if err != nil {
+			return nil, err
+		}
+
+		seriesCorrelation := &SeriesCorrelation{Lag: lag, Correlation: correlation, NumPairs: len(laggedX), PValue: pValue}
+		crossCorrelation.Lags = append(crossCorrelation.Lags, seriesCorrelation)
+
+		if math.IsNaN(correlation) {
+			continue
+		} else if best := crossCorrelation.Best; best == nil || math.Abs(correlation) > math.Abs(best.Correlation) {
+			crossCorrelation.Best = seriesCorrelation
+		}
+	}
+
+	if crossCorrelation.Best != nil {
+		adjustedPValue := crossCorrelation.Best.PValue * float64(len(crossCorrelation.Lags))
+		crossCorrelation.BestAdjustedPValue = math.Min(adjustedPValue, 1)
+	}
+
+	return crossCorrelation, nil
+}
+
+// Values of x paired with the values of y lag steps later
+func laggedPairs(x []float64, y []float64, lag int) ([]float64, []float64) {
+	if lag >= len(x) || -lag >= len(x) {
+		return []float64{}, []float64{}
+	} else if lag >= 0 {
+		return x[:len(x)-lag], y[lag:]
+	}
+
+	return x[-lag:], y[:len(y)+lag]
+}
+
+// Tests whether the past values of cause help predict effect, comparing least-squares predictions
+// of each transformed value of effect from its lags previous values with and without the lags
+// previous values of cause
+func GrangerCausality(cause []float64, effect []float64, lags int, transform SeriesTransform) (*GrangerTest, error) {
+	if len(cause) != len(effect) {
+		return nil, errSeriesLengthMismatch
+	} else if lags < 1 {
+		return nil, fmt.Errorf("granger causality test needs at least one lag, not %d", lags)
+	}
+
+	cause, effect = TransformSeries(cause, transform), TransformSeries(effect, transform)
+	numObservations := len(effect) - lags
+	test := &GrangerTest{
+		Lags:             lags,
+		FStatistic:       math.NaN(),
+		DegreesOfFreedom: [2]int{lags, numObservations - 2*lags - 1},
+		PValue:           math.NaN(),
+	}
+
+	if test.DegreesOfFreedom[1] < 1 {
+		return test, nil
+	}
+
+	// Intercept and the previous values of the effect, then of the cause
+	predictors := mat.NewDense(numObservations, 1+2*lags, nil)
+	predicted := mat.NewVecDense(numObservations, nil)
+	for i := 0; i < numObservations; i++ {
+		predictors.Set(i, 0, 1)
+		for lag := 1; lag <= lags; lag++ {
+			predictors.Set(i, lag, effect[i+lags-lag])
+			predictors.Set(i, lags+lag, cause[i+lags-lag])
+		}
+
+		predicted.SetVec(i, effect[i+lags])
+	}
+
+	restrictedSquares, err := residualSumOfSquares(predictors.Slice(0, numObservations, 0, 1+lags), predicted)
+	if err != nil {
+		return test, nil
+	}
+
+	unrestrictedSquares, err := residualSumOfSquares(predictors, predicted)
+	if err != nil || unrestrictedSquares == 0 {
+		return test, nil
+	}
+
+	test.FStatistic = ((restrictedSquares - unrestrictedSquares) / float64(test.DegreesOfFreedom[0])) /
+		(unrestrictedSquares / float64(test.DegreesOfFreedom[1]))
+	fDistribution := distuv.F{D1: float64(test.DegreesOfFreedom[0]), D2: float64(test.DegreesOfFreedom[1])}
+	test.PValue = fDistribution.Survival(test.FStatistic)
+	return test, nil
+}
+
+// Sum of the squared residuals of the least-squares fit of predicted from predictors
+func residualSumOfSquares(predictors mat.Matrix, predicted *mat.VecDense) (float64, error) {
+	var coefficients mat.VecDense
+	if err := coefficients.SolveVec(predictors, predicted); err != nil {
+		return 0, err
+	}
+
+	var residuals mat.VecDense
+	residuals.MulVec(predictors, &coefficients)
+	residuals.SubVec(predicted, &residuals)
+	return mat.Dot(&residuals, &residuals), nil
+}
@@ -0,0 +1,124 @@
+package statistics
+
+import (
+	"math"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+// Deterministic pseudo-random values between 0 and 1
+func pseudoRandomSeries(seed int, length int) []float64 {
+	series := make([]float64, length)
+	state := seed
+	for i := range series {
+		state = (state*1103515245 + 12345) % (1 << 31)
+		series[i] = float64(state) / (1 << 31)
+	}
+
+	return series
+}
+
+func TestTransformSeries(t *testing.T) {
+	approx := cmpopts.EquateApprox(0, 1e-9)
+
+	expectedDifferences := []float64{3, 5, 7}
+	if differences := TransformSeries([]float64{1, 4, 9, 16}, DifferenceTransform); !cmp.Equal(expectedDifferences, differences, approx) {
+		t.Fatalf("Unexpected differences: %s", cmp.Diff(expectedDifferences, differences))
+	}
+
+	// Residuals of the line 0.3 + 0.8t
+	expectedResiduals := []float64{-0.3, 0.9, -0.9, 0.3}
+	if residuals := TransformSeries([]float64{0, 2, 1, 3}, DetrendTransform); !cmp.Equal(expectedResiduals, residuals, approx) {
+		t.Fatalf("Unexpected detrended series: %s", cmp.Diff(expectedResiduals, residuals))
+	}
+}
+
+func TestCorrelate(t *testing.T) {
+	approx := cmpopts.EquateApprox(0, 1e-6)
+
+	correlation, pValue, err := Correlate([]float64{1, 2, 3, 4, 5}, []float64{2, 1, 4, 3, 5}, PearsonCorrelation)
+	if err != nil {
+		t.Fatalf("Error correlating series: %s", err)
+	} else if !cmp.Equal(0.8, correlation, approx) || !cmp.Equal(0.104088, pValue, approx) {
+		t.Fatalf("Unexpected Pearson correlation %f with p-value %f", correlation, pValue)
+	}
+
+	// Monotonic but not linear
+	correlation, _, _ = Correlate([]float64{1, 2, 3, 4, 5}, []float64{1, 8, 27, 64, 125}, SpearmanCorrelation)
+	if !cmp.Equal(1., correlation, approx) {
+		t.Fatalf("Unexpected Spearman correlation %f", correlation)
+	}
+
+	// Four concordant pairs, one tied in each series
+	correlation, pValue, _ = Correlate([]float64{1, 2, 2, 3}, []float64{1, 3, 2, 3}, KendallCorrelation)
+	if !cmp.Equal(0.8, correlation, approx) || !cmp.Equal(0.125971, pValue, approx) {
+		t.Fatalf("Unexpected Kendall correlation %f with p-value %f", correlation, pValue)
+	}
+
+	if _, _, err := Correlate([]float64{1, 2, 3}, []float64{1, 2}, PearsonCorrelation); err == nil {
+		t.Fatalf("Expected an error correlating series of different lengths")
+	}
+}
+
+func TestCrossCorrelate(t *testing.T) {
+	x := pseudoRandomSeries(1, 40)
+	// y follows x two steps later
+	y := append([]float64{0, 0}, x[:len(x)-2]...)
+
+	for _, method := range []CorrelationMethod{PearsonCorrelation, SpearmanCorrelation, KendallCorrelation} {
+		crossCorrelation, err := CrossCorrelate(x, y, 4, method, NoTransform)
+		if err != nil {
+			t.Fatalf("Error cross-correlating series: %s", err)
+		} else if len(crossCorrelation.Lags) != 9 {
+			t.Fatalf("Unexpected number of lags %d", len(crossCorrelation.Lags))
+		} else if best := crossCorrelation.Best; best.Lag != 2 || best.NumPairs != 38 || math.Abs(best.Correlation-1) > 1e-9 {
+			t.Fatalf("Unexpected best lag %d with %d pairs and correlation %f for method %d", best.Lag, best.NumPairs, best.Correlation, method)
+		} else if crossCorrelation.BestAdjustedPValue > 1e-6 {
+			t.Fatalf("Unexpected adjusted p-value %f of the best lag", crossCorrelation.BestAdjustedPValue)
+		}
+	}
+
+	// Differencing shortens the series, leaving one pair fewer at each lag
+	crossCorrelation, _ := CrossCorrelate(x, y, 4, PearsonCorrelation, DifferenceTransform)
+	if best := crossCorrelation.Best; best.Lag != 2 || best.NumPairs != 37 {
+		t.Fatalf("Unexpected best lag %d with %d pairs of differenced series", best.Lag, best.NumPairs)
+	}
+
+	crossCorrelation, _ = CrossCorrelate([]float64{1, 2}, []float64{2, 1}, 1, PearsonCorrelation, NoTransform)
+	if crossCorrelation.Best != nil || !math.IsNaN(crossCorrelation.BestAdjustedPValue) {
+		t.Fatalf("Unexpected best lag %+v of series too short to correlate", crossCorrelation.Best)
+	}
+}
+
+func TestGrangerCausality(t *testing.T) {
+	cause := pseudoRandomSeries(1, 40)
+	noise := pseudoRandomSeries(7, 40)
+	effect := make([]float64, len(cause))
+	effect[0] = 0.5 * noise[0]
+	for i := 1; i < len(effect); i++ {
+		effect[i] = 0.5*noise[i] + 0.8*cause[i-1] + 0.3*effect[i-1]
+	}
+
+	approx := cmpopts.EquateApprox(1e-6, 0)
+
+	test, err := GrangerCausality(cause, effect, 2, NoTransform)
+	if err != nil {
+		t.Fatalf("Error testing Granger causality: %s", err)
+	} else if !cmp.Equal(26.660675, test.FStatistic, approx) || !cmp.Equal(1.286854e-7, test.PValue, approx) {
+		t.Fatalf("Unexpected F statistic %f with p-value %g", test.FStatistic, test.PValue)
+	} else if test.DegreesOfFreedom != [2]int{2, 33} {
+		t.Fatalf("Unexpected degrees of freedom %v", test.DegreesOfFreedom)
+	}
+
+	test, _ = GrangerCausality(effect, cause, 2, NoTransform)
+	if !cmp.Equal(0.011080, test.FStatistic, approx) || !cmp.Equal(0.988985, test.PValue, approx) {
+		t.Fatalf("Unexpected F statistic %f with p-value %f of the reverse direction", test.FStatistic, test.PValue)
+	}
+
+	test, _ = GrangerCausality(cause[:5], effect[:5], 2, NoTransform)
+	if !math.IsNaN(test.PValue) {
+		t.Fatalf("Unexpected p-value %f of series too short for the lags", test.PValue)
+	}
+}
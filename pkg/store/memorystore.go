@@ -0,0 +1,392 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/claucambra/commit-analysis-tool/pkg/common"
+)
+
+// Store keeping commits in memory, e.g. for tests or to report on commits that are not ingested
+// into a database. Safe for concurrent use. Methods check the context before reading or writing,
+// and between commits while ingesting them.
+type MemoryStore struct {
+	mutex sync.RWMutex
+
+	commits map[string]*common.Commit
+	// Ids of the commits in the order they were added
+	commitIds []string
+	// Names of the repositories each commit is part of, in the order it was added from them
+	commitRepos map[string][]string
+}
+
+var _ Store = (*MemoryStore)(nil)
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		commits:     map[string]*common.Commit{},
+		commitIds:   []string{},
+		commitRepos: map[string][]string{},
+	}
+}
+
+func (ms *MemoryStore) AddCommit(ctx context.Context, commit *common.Commit) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	} else if commit == nil {
+		return errors.New("received a nil commit, won't add to store")
+	}
+
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+
+	ms.addCommit(commit)
+	return nil
+}
+
+// Adds or replaces the commit, with the mutex locked
+func (ms *MemoryStore) addCommit(commit *common.Commit) {
+	// Like in the SQLite store, a replaced commit moves to the end
+	if _, ok := ms.commits[commit.Id]; ok {
+		for i, commitId := range ms.commitIds {
+			if commitId == commit.Id {
+				ms.commitIds = append(ms.commitIds[:i], ms.commitIds[i+1:]...)
+				break
+			}
+		}
+	}
+
+	storedCommit := *commit
+	ms.commits[commit.Id] = &storedCommit
+	ms.commitIds = append(ms.commitIds, commit.Id)
+	ms.addRepoCommit(commit.RepoName, commit.Id)
+}
+
+// Returns false if the commit was already recorded as part of the repository
+func (ms *MemoryStore) addRepoCommit(repoName string, commitId string) bool {
+	if repoName == "" {
+		return false
+	} else if found, _ := common.SliceContains(ms.commitRepos[commitId], repoName); found {
+		return false
+	}
+
+	ms.commitRepos[commitId] = append(ms.commitRepos[commitId], repoName)
+	return true
+}
+
+func (ms *MemoryStore) AddCommits(ctx context.Context, commits []*common.Commit) error {
+	for _, commit := range commits {
+		if err := ms.AddCommit(ctx, commit); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (ms *MemoryStore) IngestCommits(ctx context.Context, commits common.CommitIterator) (int, error) {
+	numAdded := 0
+
+	for commits.Next() {
+		if err := ctx.Err(); err != nil {
+			return numAdded, err
+		}
+
+		commit := commits.Commit()
+		if commit == nil {
+			return numAdded, errors.New("received a nil commit, won't add to store")
+		}
+
+		// Checking whether the commit exists and adding it under one lock, so concurrent ingests
+		// of the same commit add and count it once
+		ms.mutex.Lock()
+		if _, exists := ms.commits[commit.Id]; !exists {
+			ms.addCommit(commit)
+			numAdded++
+		} else if ms.addRepoCommit(commit.RepoName, commit.Id) {
+			numAdded++
+		}
+		ms.mutex.Unlock()
+	}
+
+	return numAdded, commits.Err()
+}
+
+// Copy of a stored commit named after the queried repository, or the first repository it was
+// added from
+func (ms *MemoryStore) queriedCommit(commit *common.Commit, repoName string, withDetails bool) *common.Commit {
+	queriedCommit := *commit
+	queriedCommit.RepoName = repoName
+
+	if repoName == "" && len(ms.commitRepos[commit.Id]) > 0 {
+		queriedCommit.RepoName = ms.commitRepos[commit.Id][0]
+	}
+
+	if !withDetails {
+		queriedCommit.FileChanges = nil
+		queriedCommit.Trailers = nil
+		queriedCommit.ParentIds = nil
+	}
+
+	return &queriedCommit
+}
+
+// Stored commits of the repository (or of all repositories if empty) matching the condition, in
+// the order they were added
+func (ms *MemoryStore) selectCommits(repoName string, condition func(*common.Commit) bool) []*common.Commit {
+	commits := []*common.Commit{}
+
+	for _, commitId := range ms.commitIds {
+		commit := ms.commits[commitId]
+
+		if repoName != "" {
+			if found, _ := common.SliceContains(ms.commitRepos[commitId], repoName); !found {
+				continue
+			}
+		}
+
+		if condition == nil || condition(commit) {
+			commits = append(commits, commit)
+		}
+	}
+
+	return commits
+}
+
+func (ms *MemoryStore) queryCommits(ctx context.Context, repoName string, withDetails bool, condition func(*common.Commit) bool) ([]*common.Commit, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	ms.mutex.RLock()
+	defer ms.mutex.RUnlock()
+
+	commits := []*common.Commit{}
+	for _, commit := range ms.selectCommits(repoName, condition) {
+		commits = append(commits, ms.queriedCommit(commit, repoName, withDetails))
+	}
+
+	return commits, nil
+}
+
+func (ms *MemoryStore) Commit(ctx context.Context, commitId string) (*common.Commit, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	ms.mutex.RLock()
+	defer ms.mutex.RUnlock()
+
+	commit, ok := ms.commits[commitId]
+	if !ok {
+		return nil, nil
+	}
+
+	return ms.queriedCommit(commit, "", true), nil
+}
+
+func (ms *MemoryStore) Commits(ctx context.Context, repoName string) ([]*common.Commit, error) {
+	return ms.queryCommits(ctx, repoName, true, nil)
+}
+
+func (ms *MemoryStore) QueryCommits(ctx context.Context, query *common.CommitQuery) (common.CommitIterator, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	textMatches := func(*common.Commit) bool { return true }
+	if query.Text != "" {
+		matcher, err := parseSearchQuery(query.Text)
+		if err != nil {
+			return nil, err
+		}
+
+		textMatches = func(commit *common.Commit) bool { return matcher(newSearchDocument(commit)) }
+	}
+
+	ms.mutex.RLock()
+	defer ms.mutex.RUnlock()
+
+	commits := ms.selectCommits(query.RepoName, func(commit *common.Commit) bool {
+		return query.Matches(commit) && textMatches(commit)
+	})
+	query.SortCommits(commits)
+
+	if query.Offset >= len(commits) {
+		commits = nil
+	} else if query.Offset > 0 {
+		commits = commits[query.Offset:]
+	}
+
+	if query.Limit > 0 && query.Limit < len(commits) {
+		commits = commits[:query.Limit]
+	}
+
+	queriedCommits := []*common.Commit{}
+	for _, commit := range commits {
+		queriedCommits = append(queriedCommits, ms.queriedCommit(commit, query.RepoName, query.WithDetails))
+	}
+
+	return common.NewCommitSliceIterator(queriedCommits), nil
+}
+
+func (ms *MemoryStore) CommitCount(ctx context.Context, repoName string, countPerRepo bool) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	ms.mutex.RLock()
+	defer ms.mutex.RUnlock()
+
+	if !countPerRepo {
+		return len(ms.selectCommits(repoName, nil)), nil
+	}
+
+	count := 0
+	for _, repoNames := range ms.commitRepos {
+		if repoName == "" {
+			count += len(repoNames)
+		} else if found, _ := common.SliceContains(repoNames, repoName); found {
+			count++
+		}
+	}
+
+	return count, nil
+}
+
+func (ms *MemoryStore) CoAuthoredCommits(ctx context.Context, repoName string) ([]*common.Commit, error) {
+	return ms.queryCommits(ctx, repoName, true, func(commit *common.Commit) bool {
+		for _, trailer := range commit.Trailers {
+			if trailer.Kind == common.CoAuthoredByTrailer {
+				return true
+			}
+		}
+
+		return false
+	})
+}
+
+func (ms *MemoryStore) MergeCommits(ctx context.Context, repoName string) ([]*common.Commit, error) {
+	return ms.queryCommits(ctx, repoName, true, func(commit *common.Commit) bool {
+		return commit.IsMerge()
+	})
+}
+
+func (ms *MemoryStore) Authors(ctx context.Context, repoName string) ([]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	ms.mutex.RLock()
+	defer ms.mutex.RUnlock()
+
+	authors := []string{}
+	for _, commit := range ms.selectCommits(repoName, nil) {
+		if found, _ := common.SliceContains(authors, commit.Author.Email); !found {
+			authors = append(authors, commit.Author.Email)
+		}
+	}
+
+	return authors, nil
+}
+
+func (ms *MemoryStore) AuthorCommits(ctx context.Context, authorEmail string, repoName string) ([]*common.Commit, error) {
+	return ms.queryCommits(ctx, repoName, true, func(commit *common.Commit) bool {
+		return commit.Author.Email == authorEmail
+	})
+}
+
+func (ms *MemoryStore) AuthorYearMonthCommits(ctx context.Context, authorEmail string, repoName string) (common.YearMonthCount, error) {
+	authorCommits, err := ms.AuthorCommits(ctx, authorEmail, repoName)
+	if err != nil {
+		return nil, err
+	}
+
+	yearMonthCommits := common.YearMonthCount{}
+
+	for _, commit := range authorCommits {
+		commitTime := time.Unix(commit.AuthorTime, 0).UTC()
+		commitYear := commitTime.Year()
+		commitMonth := int(commitTime.Month())
+
+		if _, ok := yearMonthCommits[commitYear]; !ok {
+			yearMonthCommits[commitYear] = common.MonthCount{}
+		}
+
+		yearMonthCommits[commitYear][commitMonth]++
+	}
+
+	return yearMonthCommits, nil
+}
+
+func (ms *MemoryStore) DomainCommits(ctx context.Context, domain string, repoName string, includeSubdomains bool) ([]*common.Commit, error) {
+	return ms.queryCommits(ctx, repoName, false, func(commit *common.Commit) bool {
+		return common.DomainMatches(commit.Author.Domain(), domain, includeSubdomains)
+	})
+}
+
+func (ms *MemoryStore) DomainLineChanges(ctx context.Context, domain string, repoName string, includeSubdomains bool) (*common.LineChanges, error) {
+	domainCommits, err := ms.DomainCommits(ctx, domain, repoName, includeSubdomains)
+	if err != nil {
+		return nil, err
+	}
+
+	lineChanges := &common.LineChanges{}
+
+	for _, commit := range domainCommits {
+		lineChanges.NumInsertions += commit.NumInsertions
+		lineChanges.NumDeletions += commit.NumDeletions
+	}
+
+	return lineChanges, nil
+}
+
+func (ms *MemoryStore) DomainYearlyLineChanges(ctx context.Context, domain string, repoName string, includeSubdomains bool) (common.YearlyLineChangeMap, error) {
+	domainCommits, err := ms.DomainCommits(ctx, domain, repoName, includeSubdomains)
+	if err != nil {
+		return nil, err
+	}
+
+	yearBuckets := common.YearlyLineChangeMap{}
+
+	for _, commit := range domainCommits {
+		commitYear := time.Unix(commit.AuthorTime, 0).UTC().Year()
+		yearBuckets.AddLineChanges(&(commit.LineChanges), commitYear)
+	}
+
+	return yearBuckets, nil
+}
+
+func (ms *MemoryStore) Aggregates(ctx context.Context, repoName string, dimensions ...common.AggregateDimension) ([]*common.Aggregate, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	ms.mutex.RLock()
+	defer ms.mutex.RUnlock()
+
+	repoNames := func(commit *common.Commit) []string {
+		if repoName != "" {
+			return []string{repoName}
+		}
+
+		return ms.commitRepos[commit.Id]
+	}
+
+	return common.AggregateCommits(ms.selectCommits(repoName, nil), repoNames, dimensions...), nil
+}
+
+func (ms *MemoryStore) SearchCommits(ctx context.Context, query string, repoName string) ([]*common.Commit, error) {
+	commits, err := ms.QueryCommits(ctx, common.NewCommitQuery().WithRepo(repoName).WithText(query))
+	if err != nil {
+		return nil, err
+	}
+
+	return common.CollectCommits(commits)
+}
+
+func (ms *MemoryStore) Close() error {
+	return nil
+}
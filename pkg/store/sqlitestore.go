@@ -0,0 +1,23 @@
+package store
+
+import (
+	"context"
+
+	"github.com/claucambra/commit-analysis-tool/internal/db"
+)
+
+// Store keeping commits in an SQLite database file
+type SQLiteStore = db.SQLiteBackend
+
+var _ Store = (*SQLiteStore)(nil)
+
+// Opens the SQLite database at the path, creating it and its tables if needed
+func NewSQLiteStore(ctx context.Context, path string) (*SQLiteStore, error) {
+	sqlb := new(db.SQLiteBackend)
+	// Brings the schema up to date too
+	if err := sqlb.Open(ctx, path); err != nil {
+		return nil, err
+	}
+
+	return sqlb, nil
+}
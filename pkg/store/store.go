@@ -0,0 +1,65 @@
+package store
+
+import (
+	"context"
+
+	"github.com/claucambra/commit-analysis-tool/pkg/common"
+)
+
+// Storage of ingested commits queried by the reports in pkg/statistics. SQLiteStore keeps commits
+// in a database file, MemoryStore keeps them in memory.
+//
+// Queries take the name of the repository to restrict them to, or query the commits of all
+// repositories if it is empty. A commit can be part of several repositories; without a repository
+// it is returned once, named after the first repository it was added from.
+//
+// Methods take a context and stop with its error once it is cancelled. Errors are returned rather
+// than logged, wrapping the underlying error.
+type Store interface {
+	// Adds or replaces a commit, recording it as part of its repository
+	AddCommit(ctx context.Context, commit *common.Commit) error
+	AddCommits(ctx context.Context, commits []*common.Commit) error
+	// Adds commits as they are produced by the iterator. Commits already stored are not added
+	// again, but are recorded as part of the commit's repository. Returns the number of commits
+	// added to the repository.
+	IngestCommits(ctx context.Context, commits common.CommitIterator) (int, error)
+
+	Commit(ctx context.Context, commitId string) (*common.Commit, error)
+	// Commits in the order they were added, with their file changes, trailers and parents
+	Commits(ctx context.Context, repoName string) ([]*common.Commit, error)
+	// Commits matching the query's filters in its order, read a page at a time rather than loaded
+	// all at once. Errors reading pages are reported by the iterator's Err.
+	QueryCommits(ctx context.Context, query *common.CommitQuery) (common.CommitIterator, error)
+	// With countPerRepo, commits shared by several repositories are counted once for each of them
+	CommitCount(ctx context.Context, repoName string, countPerRepo bool) (int, error)
+	// Commits crediting co-authors through Co-authored-by trailers
+	CoAuthoredCommits(ctx context.Context, repoName string) ([]*common.Commit, error)
+	// Commits with more than one parent
+	MergeCommits(ctx context.Context, repoName string) ([]*common.Commit, error)
+
+	// Distinct author emails
+	Authors(ctx context.Context, repoName string) ([]string, error)
+	AuthorCommits(ctx context.Context, authorEmail string, repoName string) ([]*common.Commit, error)
+	// Number of commits of an author in each month (by author time, in UTC) they committed in
+	AuthorYearMonthCommits(ctx context.Context, authorEmail string, repoName string) (common.YearMonthCount, error)
+
+	// Commits whose author email is of the domain, without their file changes, trailers or
+	// parents. Domains match exactly (ignoring case) unless includeSubdomains is set, in which case
+	// e.g. "ibm.com" also matches "research.ibm.com" but still not "notibm.com".
+	DomainCommits(ctx context.Context, domain string, repoName string, includeSubdomains bool) ([]*common.Commit, error)
+	DomainLineChanges(ctx context.Context, domain string, repoName string, includeSubdomains bool) (*common.LineChanges, error)
+	// Line changes of the domain's commits in each year (by author time, in UTC)
+	DomainYearlyLineChanges(ctx context.Context, domain string, repoName string, includeSubdomains bool) (common.YearlyLineChangeMap, error)
+
+	// Totals of the commits grouped by the dimensions, e.g. per domain and month, summed up by the
+	// store rather than by loading the commits. See common.AggregateCommits.
+	Aggregates(ctx context.Context, repoName string, dimensions ...common.AggregateDimension) ([]*common.Aggregate, error)
+
+	// Commits whose subject or body match the full-text query, in the order they were added and
+	// without their file changes, trailers or parents. Queries take terms, "quoted phrases",
+	// prefix* terms, AND, OR and NOT, parentheses (joined to other terms with an explicit AND), and
+	// subject: or body: before a term to search a single column.
+	SearchCommits(ctx context.Context, query string, repoName string) ([]*common.Commit, error)
+
+	Close() error
+}
@@ -0,0 +1,311 @@
+package store
+
+import (
+	"context"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/claucambra/commit-analysis-tool/pkg/common"
+	"github.com/google/go-cmp/cmp"
+)
+
+func testCommits() []*common.Commit {
+	firstTime := time.Date(2022, 12, 31, 23, 0, 0, 0, time.UTC).Unix()
+	secondTime := time.Date(2023, 1, 1, 1, 0, 0, 0, time.UTC).Unix()
+
+	return []*common.Commit{
+		{
+			Changes:    common.Changes{LineChanges: common.LineChanges{NumInsertions: 10, NumDeletions: 2}, NumFilesChanged: 1},
+			Id:         "a",
+			RepoName:   "vlc",
+			Author:     common.Person{Name: "Jean-Baptiste Kempf", Email: "jb@videolan.org"},
+			AuthorTime: firstTime,
+			Subject:    "Add readme",
+			FileChanges: []*common.FileChange{
+				{Path: "README.md", LineChanges: common.LineChanges{NumInsertions: 10, NumDeletions: 2}},
+			},
+		},
+		{
+			Changes:    common.Changes{LineChanges: common.LineChanges{NumInsertions: 5, NumDeletions: 1}},
+			Id:         "b",
+			RepoName:   "vlc",
+			Author:     common.Person{Name: "Claudio Cambra", Email: "developer@claudiocambra.com"},
+			AuthorTime: secondTime,
+			Subject:    "Add main",
+			Trailers: []*common.Trailer{
+				{
+					Kind:   common.CoAuthoredByTrailer,
+					Key:    "Co-authored-by",
+					Value:  "Jean-Baptiste Kempf <jb@videolan.org>",
+					Person: common.Person{Name: "Jean-Baptiste Kempf", Email: "jb@videolan.org"},
+				},
+			},
+			ParentIds: []string{"a"},
+		},
+		{
+			Id:         "c",
+			RepoName:   "vlc",
+			Author:     common.Person{Name: "Jean-Baptiste Kempf", Email: "jb@videolan.org"},
+			AuthorTime: secondTime,
+			Subject:    "Merge branch 'main'",
+			ParentIds:  []string{"b", "a"},
+		},
+		{
+			Changes:    common.Changes{LineChanges: common.LineChanges{NumInsertions: 3}},
+			Id:         "d",
+			RepoName:   "libvlc",
+			Author:     common.Person{Name: "Jean-Baptiste Kempf", Email: "jb@videolan.org"},
+			AuthorTime: secondTime,
+			Subject:    "Add bindings",
+		},
+		{
+			Changes:    common.Changes{LineChanges: common.LineChanges{NumInsertions: 7}},
+			Id:         "e",
+			RepoName:   "vlc",
+			Author:     common.Person{Name: "Not VideoLAN", Email: "someone@notvideolan.org"},
+			AuthorTime: secondTime,
+			Subject:    "Add lookalike",
+		},
+		{
+			Changes:    common.Changes{LineChanges: common.LineChanges{NumInsertions: 4}},
+			Id:         "f",
+			RepoName:   "vlc",
+			Author:     common.Person{Name: "VideoLAN Research", Email: "research@Labs.VideoLAN.org"},
+			AuthorTime: secondTime,
+			Subject:    "Add experiment",
+		},
+	}
+}
+
+func commitIds(value any) []string {
+	commitIds := []string{}
+	for _, commit := range value.([]*common.Commit) {
+		commitIds = append(commitIds, commit.Id)
+	}
+
+	return commitIds
+}
+
+// Runs the same queries on both stores, which should return the same results
+func TestStores(t *testing.T) {
+	ctx := context.Background()
+
+	sqliteStore, err := NewSQLiteStore(ctx, filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Could not open SQLite store: %s", err)
+	}
+
+	t.Cleanup(func() { sqliteStore.Close() })
+
+	memoryStore := NewMemoryStore()
+	stores := []Store{sqliteStore, memoryStore}
+	results := make([]map[string]any, len(stores))
+
+	for i, commitStore := range stores {
+		if err := commitStore.AddCommits(ctx, testCommits()); err != nil {
+			t.Fatalf("Error adding commits: %s", err)
+		}
+
+		// Shared with another repository
+		sharedCommit := testCommits()[0]
+		sharedCommit.RepoName = "libvlc"
+		numAdded, err := commitStore.IngestCommits(ctx, common.NewCommitSliceIterator([]*common.Commit{sharedCommit}))
+		if err != nil || numAdded != 1 {
+			t.Fatalf("Unexpected ingest of shared commit: %d added, error %v", numAdded, err)
+		}
+
+		result := map[string]any{}
+		check := func(name string, value any, err error) {
+			if err != nil {
+				t.Fatalf("Error querying %s: %s", name, err)
+			}
+
+			result[name] = value
+		}
+
+		commit, err := commitStore.Commit(ctx, "b")
+		check("commit", commit, err)
+		commits, err := commitStore.Commits(ctx, "")
+		check("commits", commits, err)
+		repoCommits, err := commitStore.Commits(ctx, "libvlc")
+		check("repo commits", repoCommits, err)
+		count, err := commitStore.CommitCount(ctx, "", false)
+		check("count", count, err)
+		perRepoCount, err := commitStore.CommitCount(ctx, "", true)
+		check("per repo count", perRepoCount, err)
+		coAuthored, err := commitStore.CoAuthoredCommits(ctx, "vlc")
+		check("co-authored", coAuthored, err)
+		merges, err := commitStore.MergeCommits(ctx, "")
+		check("merges", merges, err)
+		authors, err := commitStore.Authors(ctx, "vlc")
+		sort.Strings(authors)
+		check("authors", authors, err)
+		authorCommits, err := commitStore.AuthorCommits(ctx, "jb@videolan.org", "libvlc")
+		check("author commits", authorCommits, err)
+		yearMonthCommits, err := commitStore.AuthorYearMonthCommits(ctx, "jb@videolan.org", "")
+		check("author months", yearMonthCommits, err)
+		domainCommits, err := commitStore.DomainCommits(ctx, "videolan.org", "vlc", false)
+		check("domain commits", domainCommits, err)
+		subdomainCommits, err := commitStore.DomainCommits(ctx, "VideoLAN.org", "vlc", true)
+		check("subdomain commits", subdomainCommits, err)
+		lineChanges, err := commitStore.DomainLineChanges(ctx, "videolan.org", "", false)
+		check("domain changes", lineChanges, err)
+		subdomainLineChanges, err := commitStore.DomainLineChanges(ctx, "videolan.org", "", true)
+		check("subdomain changes", subdomainLineChanges, err)
+		yearlyLineChanges, err := commitStore.DomainYearlyLineChanges(ctx, "videolan.org", "", false)
+		check("domain yearly changes", yearlyLineChanges, err)
+		totals, err := commitStore.Aggregates(ctx, "")
+		check("totals", totals, err)
+		domainMonthTotals, err := commitStore.Aggregates(ctx, "vlc", common.AggregateByDomain, common.AggregateByMonth)
+		check("domain month totals", domainMonthTotals, err)
+		authorYearTotals, err := commitStore.Aggregates(ctx, "", common.AggregateByAuthor, common.AggregateByYear)
+		check("author year totals", authorYearTotals, err)
+		repoTotals, err := commitStore.Aggregates(ctx, "", common.AggregateByRepo)
+		check("repo totals", repoTotals, err)
+		emptyRepoTotals, err := commitStore.Aggregates(ctx, "none", common.AggregateByRepo)
+		check("empty repo totals", emptyRepoTotals, err)
+
+		for _, query := range []string{"add NOT main", `"merge branch" OR README`, "subject:bind*", "add AND (main OR bindings)"} {
+			searchCommits, err := commitStore.SearchCommits(ctx, query, "")
+			check("search "+query, commitIds(searchCommits), err)
+		}
+
+		repoSearchCommits, err := commitStore.SearchCommits(ctx, "main", "vlc")
+		check("repo search", commitIds(repoSearchCommits), err)
+
+		queries := map[string]*common.CommitQuery{
+			"query by size":   common.NewCommitQuery().WithOrder(common.OrderByLineChanges, true).WithPageSize(2),
+			"query by domain": common.NewCommitQuery().WithAuthorDomain("videolan.org", true).WithAuthorTimeRange(time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC), time.Time{}),
+			"query page":      common.NewCommitQuery().WithLineChangesRange(3, 7).WithOrder(common.OrderByAuthorTime, false).WithOffset(1).WithLimit(2),
+			"query text":      common.NewCommitQuery().WithText("add").WithAuthorEmail("jb@videolan.org").WithOrder(common.OrderByIngest, true),
+			"query details":   common.NewCommitQuery().WithRepo("libvlc").WithCommitDetails().WithPageSize(1),
+		}
+		for name, query := range queries {
+			queryCommits, err := commitStore.QueryCommits(ctx, query)
+			if err != nil {
+				t.Fatalf("Error running %s: %s", name, err)
+			}
+
+			collectedCommits, err := common.CollectCommits(queryCommits)
+			check(name, collectedCommits, err)
+		}
+
+		results[i] = result
+	}
+
+	if !cmp.Equal(results[0], results[1]) {
+		t.Fatalf("Stores returned different results: %s", cmp.Diff(results[0], results[1]))
+	}
+
+	expectedMonths := common.YearMonthCount{2022: {12: 1}, 2023: {1: 2}}
+	if !cmp.Equal(expectedMonths, results[1]["author months"]) {
+		t.Fatalf("Unexpected author months: %s", cmp.Diff(expectedMonths, results[1]["author months"]))
+	}
+
+	expectedDomainCommitIds := []string{"a", "c"}
+	if domainCommitIds := commitIds(results[1]["domain commits"]); !cmp.Equal(expectedDomainCommitIds, domainCommitIds) {
+		t.Fatalf("Unexpected domain commits: %s", cmp.Diff(expectedDomainCommitIds, domainCommitIds))
+	}
+
+	expectedSubdomainCommitIds := []string{"a", "c", "f"}
+	if subdomainCommitIds := commitIds(results[1]["subdomain commits"]); !cmp.Equal(expectedSubdomainCommitIds, subdomainCommitIds) {
+		t.Fatalf("Unexpected subdomain commits: %s", cmp.Diff(expectedSubdomainCommitIds, subdomainCommitIds))
+	}
+
+	expectedSearches := map[string][]string{
+		"search add NOT main":               {"a", "d", "e", "f"},
+		`search "merge branch" OR README`:   {"a", "c"},
+		"search subject:bind*":              {"d"},
+		"search add AND (main OR bindings)": {"b", "d"},
+		"repo search":                       {"b", "c"},
+	}
+	for name, expectedCommitIds := range expectedSearches {
+		if !cmp.Equal(expectedCommitIds, results[1][name]) {
+			t.Fatalf("Unexpected %s results: %s", name, cmp.Diff(expectedCommitIds, results[1][name]))
+		}
+	}
+
+	expectedQueries := map[string][]string{
+		"query by size":   {"a", "e", "b", "f", "d", "c"},
+		"query by domain": {"c", "d", "f"},
+		"query page":      {"d", "e"},
+		"query text":      {"d", "a"},
+		"query details":   {"a", "d"},
+	}
+	for name, expectedCommitIds := range expectedQueries {
+		if queryCommitIds := commitIds(results[1][name]); !cmp.Equal(expectedCommitIds, queryCommitIds) {
+			t.Fatalf("Unexpected %s results: %s", name, cmp.Diff(expectedCommitIds, queryCommitIds))
+		}
+	}
+
+	if queryCommits := results[1]["query details"].([]*common.Commit); len(queryCommits[0].FileChanges) != 1 {
+		t.Fatalf("Queried commit lacks its file changes: %+v", queryCommits[0])
+	}
+
+	expectedRepoTotals := []*common.Aggregate{
+		{RepoName: "libvlc", NumCommits: 2, NumAuthors: 1, LineChanges: common.LineChanges{NumInsertions: 13, NumDeletions: 2}},
+		{RepoName: "vlc", NumCommits: 5, NumAuthors: 4, LineChanges: common.LineChanges{NumInsertions: 26, NumDeletions: 3}},
+	}
+	if !cmp.Equal(expectedRepoTotals, results[1]["repo totals"]) {
+		t.Fatalf("Unexpected repository totals: %s", cmp.Diff(expectedRepoTotals, results[1]["repo totals"]))
+	}
+
+	expectedTotals := []*common.Aggregate{
+		{NumCommits: 6, NumAuthors: 4, LineChanges: common.LineChanges{NumInsertions: 29, NumDeletions: 3}},
+	}
+	if !cmp.Equal(expectedTotals, results[1]["totals"]) {
+		t.Fatalf("Unexpected totals: %s", cmp.Diff(expectedTotals, results[1]["totals"]))
+	}
+
+	expectedYearlyLineChanges := common.YearlyLineChangeMap{
+		2022: {NumInsertions: 10, NumDeletions: 2},
+		2023: {NumInsertions: 3},
+	}
+	if !cmp.Equal(expectedYearlyLineChanges, results[1]["domain yearly changes"]) {
+		t.Fatalf("Unexpected yearly changes: %s", cmp.Diff(expectedYearlyLineChanges, results[1]["domain yearly changes"]))
+	}
+}
+
+// Concurrent ingests of the same commits add and count each commit once
+func TestMemoryStoreConcurrentIngest(t *testing.T) {
+	ctx := context.Background()
+	memoryStore := NewMemoryStore()
+
+	const numIngests = 8
+	numAdded := make([]int, numIngests)
+	errs := make([]error, numIngests)
+
+	var wg sync.WaitGroup
+	for i := 0; i < numIngests; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			numAdded[i], errs[i] = memoryStore.IngestCommits(ctx, common.NewCommitSliceIterator(testCommits()))
+		}(i)
+	}
+
+	wg.Wait()
+
+	totalAdded := 0
+	for i := range numAdded {
+		if errs[i] != nil {
+			t.Fatalf("Error ingesting commits: %s", errs[i])
+		}
+
+		totalAdded += numAdded[i]
+	}
+
+	if numCommits := len(testCommits()); totalAdded != numCommits {
+		t.Fatalf("Unexpected number of commits added: expected %d, received %d", numCommits, totalAdded)
+	}
+
+	commits, err := memoryStore.Commits(ctx, "")
+	if err != nil {
+		t.Fatalf("Error querying commits: %s", err)
+	} else if len(commits) != len(testCommits()) {
+		t.Fatalf("Unexpected number of stored commits: expected %d, received %d", len(testCommits()), len(commits))
+	}
+}
@@ -0,0 +1,278 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/claucambra/commit-analysis-tool/pkg/common"
+)
+
+// Columns full-text queries can be restricted to, as in the SQLite search index
+var searchColumns = map[string]func(*common.Commit) string{
+	"subject": func(commit *common.Commit) string { return commit.Subject },
+	"body":    func(commit *common.Commit) string { return commit.Body },
+}
+
+// Words of a text, lower-cased, like SQLite's full-text tokenizers split them
+func searchTokens(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// Tokens of each searchable column of a commit
+type searchDocument map[string][]string
+
+func newSearchDocument(commit *common.Commit) searchDocument {
+	document := searchDocument{}
+	for column, text := range searchColumns {
+		document[column] = searchTokens(text(commit))
+	}
+
+	return document
+}
+
+// Parsed full-text query, matching documents
+type searchMatcher func(document searchDocument) bool
+
+// Parses a full-text query in SQLite's syntax: terms, "quoted phrases", prefix* terms, AND, OR and
+// NOT (binding tighter in that order), parentheses and subject: or body: column filters. Terms not
+// separated by an operator must all match. This accepts some queries only one of FTS4 and FTS5
+// does, e.g. implicit AND next to parentheses or column filters on phrases.
+func parseSearchQuery(query string) (searchMatcher, error) {
+	tokens, err := lexSearchQuery(query)
+	if err != nil {
+		return nil, err
+	}
+
+	parser := &searchParser{tokens: tokens}
+	if len(parser.tokens) == 0 {
+		return nil, errors.New("empty search query")
+	}
+
+	matcher, err := parser.parseOr()
+	if err != nil {
+		return nil, err
+	} else if parser.position < len(parser.tokens) {
+		return nil, fmt.Errorf("unexpected %q in search query", parser.tokens[parser.position])
+	}
+
+	return matcher, nil
+}
+
+// Splits a query into parentheses, quoted phrases (kept with their quotes) and words
+func lexSearchQuery(query string) ([]string, error) {
+	tokens := []string{}
+	current := strings.Builder{}
+	inPhrase := false
+
+	flush := func() {
+		if current.Len() > 0 {
+			tokens = append(tokens, current.String())
+			current.Reset()
+		}
+	}
+
+	for _, r := range query {
+		switch {
+		case r == '"':
+			current.WriteRune(r)
+			if inPhrase {
+				flush()
+			}
+			inPhrase = !inPhrase
+		case inPhrase:
+			current.WriteRune(r)
+		case r == '(' || r == ')':
+			flush()
+			tokens = append(tokens, string(r))
+		case unicode.IsSpace(r):
+			flush()
+		default:
+			current.WriteRune(r)
+		}
+	}
+
+	if inPhrase {
+		return nil, errors.New("unterminated phrase in search query")
+	}
+
+	flush()
+	return tokens, nil
+}
+
+type searchParser struct {
+	tokens   []string
+	position int
+}
+
+func (parser *searchParser) peek() string {
+	if parser.position < len(parser.tokens) {
+		return parser.tokens[parser.position]
+	}
+
+	return ""
+}
+
+func (parser *searchParser) parseOr() (searchMatcher, error) {
+	left, err := parser.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	for parser.peek() == "OR" {
+		parser.position++
+		right, err := parser.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+
+		left = orMatcher(left, right)
+	}
+
+	return left, nil
+}
+
+func orMatcher(left searchMatcher, right searchMatcher) searchMatcher {
+	return func(document searchDocument) bool { return left(document) || right(document) }
+}
+
+func (parser *searchParser) parseAnd() (searchMatcher, error) {
+	left, err := parser.parseNot()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		next := parser.peek()
+		if next == "AND" {
+			parser.position++
+		} else if next == "" || next == "OR" || next == ")" {
+			return left, nil
+		}
+
+		right, err := parser.parseNot()
+		if err != nil {
+			return nil, err
+		}
+
+		left = andMatcher(left, right)
+	}
+}
+
+func andMatcher(left searchMatcher, right searchMatcher) searchMatcher {
+	return func(document searchDocument) bool { return left(document) && right(document) }
+}
+
+func (parser *searchParser) parseNot() (searchMatcher, error) {
+	left, err := parser.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+
+	for parser.peek() == "NOT" {
+		parser.position++
+		right, err := parser.parsePrimary()
+		if err != nil {
+			return nil, err
+		}
+
+		left = notMatcher(left, right)
+	}
+
+	return left, nil
+}
+
+func notMatcher(left searchMatcher, right searchMatcher) searchMatcher {
+	return func(document searchDocument) bool { return left(document) && !right(document) }
+}
+
+func (parser *searchParser) parsePrimary() (searchMatcher, error) {
+	token := parser.peek()
+	parser.position++
+
+	switch token {
+	case "":
+		return nil, errors.New("search query ends unexpectedly")
+	case ")", "AND", "OR", "NOT":
+		return nil, fmt.Errorf("unexpected %q in search query", token)
+	case "(":
+		matcher, err := parser.parseOr()
+		if err != nil {
+			return nil, err
+		} else if parser.peek() != ")" {
+			return nil, errors.New("unclosed parenthesis in search query")
+		}
+
+		parser.position++
+		return matcher, nil
+	}
+
+	// Column filters precede terms or phrases, e.g. subject:fix or subject:"fix crash"
+	columns := []string{}
+	if column, term, found := strings.Cut(token, ":"); found && !strings.HasPrefix(token, `"`) {
+		if _, ok := searchColumns[column]; !ok {
+			return nil, fmt.Errorf("unknown search column %q", column)
+		}
+
+		columns = append(columns, column)
+		token = term
+
+		if token == "" {
+			token = parser.peek()
+			parser.position++
+		}
+	} else {
+		for column := range searchColumns {
+			columns = append(columns, column)
+		}
+	}
+
+	return phraseMatcher(token, columns)
+}
+
+// Matches the words of the term or quoted phrase appearing in sequence in one of the columns. A
+// trailing * makes the last word match as a prefix.
+func phraseMatcher(phrase string, columns []string) (searchMatcher, error) {
+	phrase = strings.Trim(phrase, `"`)
+	prefix := strings.HasSuffix(phrase, "*")
+	words := searchTokens(phrase)
+
+	if len(words) == 0 {
+		return nil, fmt.Errorf("search term %q has no words", phrase)
+	}
+
+	return func(document searchDocument) bool {
+		for _, column := range columns {
+			if containsPhrase(document[column], words, prefix) {
+				return true
+			}
+		}
+
+		return false
+	}, nil
+}
+
+func containsPhrase(tokens []string, words []string, prefix bool) bool {
+	for start := 0; start+len(words) <= len(tokens); start++ {
+		matches := true
+
+		for i, word := range words {
+			token := tokens[start+i]
+			if token == word || (prefix && i == len(words)-1 && strings.HasPrefix(token, word)) {
+				continue
+			}
+
+			matches = false
+			break
+		}
+
+		if matches {
+			return true
+		}
+	}
+
+	return false
+}
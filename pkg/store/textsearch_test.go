@@ -0,0 +1,41 @@
+package store
+
+import (
+	"testing"
+
+	"github.com/claucambra/commit-analysis-tool/pkg/common"
+)
+
+func TestParseSearchQuery(t *testing.T) {
+	commit := &common.Commit{Subject: "Fix memory leak", Body: "Found by fuzzing the decoder."}
+	document := newSearchDocument(commit)
+
+	matches := map[string]bool{
+		"FIX":                        true,
+		"fix crash":                  false,
+		"fix OR crash":               true,
+		"crash OR leak NOT memory":   false,
+		"(crash OR leak) memory":     true,
+		`"memory leak"`:              true,
+		`"leak memory"`:              false,
+		`"memory le*"`:               true,
+		"fuzz*":                      true,
+		"subject:fuzzing":            false,
+		`body:"the decoder"`:         true,
+		"fix NOT (decoder OR crash)": false,
+	}
+	for query, expectedMatch := range matches {
+		matcher, err := parseSearchQuery(query)
+		if err != nil {
+			t.Fatalf("Error parsing %q: %s", query, err)
+		} else if matcher(document) != expectedMatch {
+			t.Fatalf("Query %q should match: %t", query, expectedMatch)
+		}
+	}
+
+	for _, query := range []string{"", "fix OR", "(fix", "fix)", `"fix`, "author:fix", "NOT fix"} {
+		if _, err := parseSearchQuery(query); err == nil {
+			t.Fatalf("Query %q should not parse", query)
+		}
+	}
+}